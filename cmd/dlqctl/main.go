@@ -0,0 +1,316 @@
+// cmd/dlqctl/main.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/worker"
+)
+
+// dlqctl
+// ------------------------------------------------------------
+// 로컬 DLQ(DLQ_DIR)를 조회/조작하는 운영 도구.
+// 서버와 같은 환경변수(config.Load)를 사용하므로,
+// ECS Exec 로 Fargate task 에 접속한 뒤 그대로 실행하면 된다.
+//
+// 사용법:
+//
+//	dlqctl [-dlq-dir DIR] <command> [flags] [file...]
+//
+//	list                          파일 목록 (age, size, events, valid, replay key)
+//	cat <file>                    압축 해제된 이벤트(JSONL) 출력
+//	replay [-dry-run] <file...>   즉시 재업로드 (-all: 전체)
+//	quarantine [-dry-run] [-dir DIR] <file...>
+//	                              재처리 대상에서 제외 (기본: DLQ_DIR/.quarantine)
+//	purge [-dry-run] -older-than DUR
+//	                              파일명 timestamp 기준 DUR 보다 오래된 파일 삭제
+//
+// 주의:
+//   - 서버가 실행 중인 상태에서도 사용할 수 있지만,
+//     서버의 자동 재처리와 같은 파일을 동시에 다루면 중복 업로드가 생길 수 있다.
+//   - 서버의 DLQ 용량 회계는 DLQ_RESCAN_INTERVAL 마다 디스크 기준으로 다시 맞춰지므로,
+//     dlqctl 로 정리한 결과는 그 주기 안에 /metrics 와 /health/ready 에 반영된다.
+//   - list / cat / -dry-run 은 DLQ 디렉토리를 변경하지 않는다.
+//   - 파일 인자는 파일명 또는 경로 모두 허용한다 (basename 만 사용).
+// ------------------------------------------------------------
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: dlqctl [-dlq-dir DIR] <command> [flags] [file...]
+
+commands:
+  list                                  list DLQ files (oldest first)
+  cat <file>                            print decoded events of a file
+  replay [-dry-run] [-all] <file...>    reupload files now
+  quarantine [-dry-run] [-dir DIR] <file...>
+                                        move files out of the replay queue
+  purge [-dry-run] -older-than DUR      delete files older than DUR
+`)
+}
+
+func main() {
+	dlqDir := flag.String("dlq-dir", "", "DLQ directory (default: $DLQ_DIR)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+	if *dlqDir != "" {
+		cfg.DLQDir = *dlqDir
+	}
+
+	// logger.Init 은 stdout 으로 로그를 보내므로 호출하지 않는다.
+	// (zerolog 기본 logger 는 stderr 로 출력 → 명령 결과와 섞이지 않음)
+	worker.ConfigurePartitioning(cfg)
+
+	// OpenDLQManager 는 디렉토리를 생성/정리하지 않는다 (list, cat, -dry-run 은 읽기 전용).
+	// Sink 도 실제 재업로드할 때만 만든다 (LocalSink 는 생성 시 디렉토리를 만든다).
+	m := metrics.New()
+	dlq := worker.OpenDLQManager(cfg, m, nil)
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+
+	var err error
+	switch cmd {
+	case "list", "ls":
+		err = runList(dlq)
+	case "cat":
+		err = runCat(dlq, args)
+	case "replay":
+		err = runReplay(cfg, m, args)
+	case "quarantine":
+		err = runQuarantine(dlq, args)
+	case "purge":
+		err = runPurge(dlq, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dlqctl %s: %v\n", cmd, err)
+		os.Exit(1)
+	}
+}
+
+// runList 는 DLQ 파일 목록을 표 형태로 출력한다.
+func runList(dlq *worker.DLQManager) error {
+	files, err := dlq.List()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tAGE\tSIZE\tEVENTS\tVALID\tREPLAY_KEY")
+
+	var totalSize, totalEvents int64
+	for _, f := range files {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%t\t%s\n",
+			f.Name, formatAge(now, f.Unix), f.Size, f.NumEvents, f.Valid, f.ReplayKey)
+		totalSize += f.Size
+		totalEvents += f.NumEvents
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d files, %d bytes, %d events\n", len(files), totalSize, totalEvents)
+	return nil
+}
+
+// runCat 은 파일 하나의 이벤트를 JSONL 로 출력한다.
+func runCat(dlq *worker.DLQManager, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one file")
+	}
+
+	rc, err := dlq.OpenEvents(filepath.Base(args[0]))
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	if _, err := io.Copy(w, rc); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// runReplay 는 지정한 파일(또는 -all 이면 전체)을 즉시 재업로드한다.
+func runReplay(cfg config.Config, m *metrics.Metrics, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print target keys without uploading")
+	all := fs.Bool("all", false, "replay every file in the DLQ")
+	_ = fs.Parse(args)
+
+	var sink worker.Sink
+	if !*dryRun {
+		sink = worker.NewSink(cfg, m)
+	}
+	dlq := worker.OpenDLQManager(cfg, m, sink)
+
+	names, err := selectFiles(dlq, fs.Args(), *all)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, name := range names {
+		f, err := dlq.Inspect(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skip %s: %v\n", name, err)
+			failed++
+			continue
+		}
+
+		if *dryRun {
+			fmt.Printf("[dry-run] replay %s -> %s (events=%d valid=%t)\n", f.Name, f.ReplayKey, f.NumEvents, f.Valid)
+			continue
+		}
+
+		if err := dlq.Replay(context.Background(), name); err != nil {
+			fmt.Fprintf(os.Stderr, "replay %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("replayed %s -> %s\n", f.Name, f.ReplayKey)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(names))
+	}
+	return nil
+}
+
+// runQuarantine 은 지정한 파일을 격리 디렉토리로 옮긴다.
+func runQuarantine(dlq *worker.DLQManager, args []string) error {
+	fs := flag.NewFlagSet("quarantine", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print files without moving them")
+	dir := fs.String("dir", "", "quarantine directory (default: <DLQ_DIR>/.quarantine)")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("no files given")
+	}
+
+	// 숨김 디렉토리는 서버의 pickOldest 와 List 에서 제외된다.
+	target := *dir
+	if target == "" {
+		target = filepath.Join(dlq.Dir(), ".quarantine")
+	}
+
+	failed := 0
+	for _, arg := range fs.Args() {
+		name := filepath.Base(arg)
+
+		if *dryRun {
+			fmt.Printf("[dry-run] quarantine %s -> %s\n", name, target)
+			continue
+		}
+
+		if err := dlq.Quarantine(name, target); err != nil {
+			fmt.Fprintf(os.Stderr, "quarantine %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("quarantined %s -> %s\n", name, target)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, fs.NArg())
+	}
+	return nil
+}
+
+// runPurge 는 파일명 timestamp 기준으로 오래된 파일을 삭제한다.
+func runPurge(dlq *worker.DLQManager, args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print files without deleting them")
+	olderThan := fs.Duration("older-than", 0, "delete files older than this duration (required)")
+	_ = fs.Parse(args)
+
+	if *olderThan <= 0 {
+		return fmt.Errorf("-older-than is required")
+	}
+
+	files, err := dlq.List()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-*olderThan).Unix()
+	purged, failed := 0, 0
+	for _, f := range files {
+		// timestamp 를 읽을 수 없는 파일은 나이를 판단할 수 없으므로 건드리지 않는다.
+		if f.Unix == 0 || f.Unix >= cutoff {
+			continue
+		}
+
+		if *dryRun {
+			fmt.Printf("[dry-run] purge %s (events=%d)\n", f.Name, f.NumEvents)
+			purged++
+			continue
+		}
+
+		if err := dlq.Purge(f.Name); err != nil {
+			fmt.Fprintf(os.Stderr, "purge %s: %v\n", f.Name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("purged %s (events=%d)\n", f.Name, f.NumEvents)
+		purged++
+	}
+
+	fmt.Printf("%d files purged\n", purged)
+	if failed > 0 {
+		return fmt.Errorf("%d files failed", failed)
+	}
+	return nil
+}
+
+// selectFiles 는 -all 이면 DLQ 전체, 아니면 인자의 basename 목록을 반환한다.
+func selectFiles(dlq *worker.DLQManager, args []string, all bool) ([]string, error) {
+	if all {
+		files, err := dlq.List()
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			names = append(names, f.Name)
+		}
+		return names, nil
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no files given (use -all for every file)")
+	}
+	names := make([]string, 0, len(args))
+	for _, a := range args {
+		names = append(names, filepath.Base(a))
+	}
+	return names, nil
+}
+
+// formatAge 는 파일명 timestamp 로부터의 경과 시간을 초 단위로 표시한다.
+func formatAge(now, sec int64) string {
+	if sec == 0 {
+		return "?"
+	}
+	return (time.Duration(now-sec) * time.Second).String()
+}
@@ -58,8 +58,8 @@ func main() {
 	// - Config: 환경변수 기반으로 로드 (region, bucket, prefix, batch size 등)
 	// - Metrics: /metrics 엔드포인트에서 반환하는 운영 지표 집합
 	//
-	// Metrics는 Prometheus 용이 아니라 운영자가 장애 원인 분석할 때
-	// 중요한 내부 카운터들이다 (S3 실패 횟수, DLQ 적재, body 크기 등).
+	// Metrics는 운영자가 장애 원인 분석할 때 중요한 내부 카운터/분포 지표들이다
+	// (S3 실패 횟수, DLQ 적재, body 크기 분포 등). Prometheus 로 scrape 가능하다.
 	// ====================================================================
 	cfg := config.Load()
 	m := metrics.New()
@@ -82,31 +82,71 @@ func main() {
 	// config.go 검토 결과 민감 정보(Secret/Key)가 없으므로 안전하게 출력한다.
 	log.Info().
 		Dict("config", zerolog.Dict().
+			Str("sink", cfg.SinkType).
 			Str("region", cfg.AWSRegion).
 			Str("bucket", cfg.RawBucket).
 			Str("prefix_raw", cfg.RawPrefix).
 			Str("prefix_dlq", cfg.DLQPrefix).
 			Str("addr", cfg.HTTPAddr).
+			Strs("trusted_proxies", cfg.TrustedProxyCIDRs).
+			Strs("client_ip_headers", cfg.ClientIPHeaders).
+			Str("ip_anon_mode", cfg.IPAnonMode).
+			Str("geoip_db", cfg.GeoIPDB).
+			Str("geoip_asn_db", cfg.GeoIPASNDB).
+			Str("ua_rules_file", cfg.UARulesFile).
+			Str("ua_bot_policy", cfg.UABotPolicy).
+			Str("body_format", cfg.BodyFormat).
+			Str("schema_mode", cfg.SchemaMode).
+			Str("tenants_file", cfg.TenantsFile).
+			Strs("tenant_sources", cfg.TenantSources).
+			Str("auth_collect", cfg.AuthCollect).
+			Str("auth_batch", cfg.AuthBatch).
+			Str("beacon_path", cfg.BeaconPath).
+			Str("rate_limit_key", cfg.RateLimitKey).
+			Float64("rate_limit", cfg.RateLimit).
+			Int("rate_limit_burst", cfg.RateLimitBurst).
+			Str("prefix_bot", cfg.BotPrefix).
 			Str("log_level", cfg.LogLevel).
 			Bool("log_pretty", cfg.LogPretty).
 			Int("log_sample_n", cfg.LogSampleN).
 			Int64("max_body", cfg.MaxBodySize).
+			Int64("max_decompressed_body", cfg.MaxDecompressedBodySize).
+			Int("max_batch_events", cfg.MaxBatchEvents).
+			Dur("admission_wait", cfg.AdmissionWait).
 			Int("batch_size", cfg.BatchSize).
+			Int64("batch_max_bytes", cfg.BatchMaxBytes).
+			Int("upload_workers", cfg.UploadWorkers).
 			Dur("flush_interval", cfg.FlushInterval).
 			Int("s3_retries", cfg.S3AppRetries).
-			Dur("s3_timeout", cfg.S3Timeout),
+			Dur("s3_timeout", cfg.S3Timeout).
+			Str("s3_endpoint", cfg.S3Endpoint).
+			Bool("s3_force_path_style", cfg.S3ForcePathStyle).
+			Bool("s3_static_credentials", cfg.S3AccessKeyID != "").
+			Bool("s3_breaker_enabled", cfg.S3BreakerEnabled).
+			Int("s3_breaker_failures", cfg.S3BreakerFailures).
+			Dur("s3_breaker_cooldown", cfg.S3BreakerCooldown).
+			Int64("s3_multipart_threshold", cfg.S3MultipartThreshold).
+			Int64("s3_part_size", cfg.S3PartSize).
+			Bool("wal_enabled", cfg.WALEnabled).
+			Str("wal_dir", cfg.WALDir).
+			Dur("wal_sync_interval", cfg.WALSyncInterval).
+			Float64("ready_queue_ratio", cfg.ReadyQueueRatio).
+			Float64("ready_dlq_ratio", cfg.ReadyDLQRatio).
+			Int("ready_max_s3_failures", cfg.ReadyMaxS3Failures).
+			Dur("shutdown_drain_delay", cfg.ShutdownDrainDelay),
 		).
 		Msg("server starting with configuration")
 
 	// ====================================================================
-	// Manager 생성 (S3Uploader + DLQManager + Encoder 포함)
+	// Manager 생성 (Sink + DLQManager + Encoder 포함)
 	// ====================================================================
 	//
 	// Manager는 ingest server의 핵심 비동기 처리 엔진.
 	//
 	// 구성 요소:
 	//  - Encoder: JSONL → gzip 변환 (고비용 CPU 작업)
-	//  - S3Uploader: AWS SDK retry 0 + app-level retry
+	//  - Sink: SINK_TYPE 에 따라 S3Uploader(AWS SDK retry 0 + app-level retry)
+	//          또는 LocalSink(로컬 디렉토리, 개발/CI 용)
 	//  - DLQManager: S3 업로드 실패 시 로컬에 저장 후 재업로드
 	//  - EventCh: /collect 요청 처리 후 이벤트 전달 (백프레셔 핵심)
 	//  - uploadCh: 배치 flush → 업로드 요청 전달
@@ -123,20 +163,20 @@ func main() {
 	//
 	// 엔드포인트:
 	//  - /collect : ingest 이벤트 수집 (핵심)
-	//  - /metrics : 운영 지표 확인 (텍스트 포맷)
-	//  - /health  : ALB Target Group Health check 용
+	//  - /collect/batch : NDJSON / JSON 배열 기반 다건 이벤트 수집
+	//  - /collect/{tenant}, /collect/{tenant}/batch : TENANTS_FILE 설정 시 path 로 tenant 지정
+	//  - BEACON_PATH (설정 시에만) : 브라우저 beacon 용 익명 /collect (AUTH_COLLECT 미적용)
+	//  - /metrics : 운영 지표 확인 (Prometheus 포맷, ?format=kv 는 레거시 텍스트)
+	//  - /health/live  : liveness (프로세스 생존 여부, 항상 200). /health 는 호환용 alias
+	//  - /health/ready : readiness (ALB Target Group Health check 용)
+	//                    큐/DLQ 사용률, 연속 S3 실패, shutdown 여부에 따라 503 + 사유(JSON)
 	//
 	// ALB가 5xx 또는 응답 지연을 감지하면 인스턴스를 교체하기 때문에
 	// Health Check 응답속도는 매우 중요하다.
 	// ====================================================================
 	h := server.NewHandler(cfg, m, mgr)
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/collect", h.HandleCollect)
-	mux.HandleFunc("/metrics", h.HandleMetrics)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		w.Write([]byte("ok"))
-	})
+	mux := h.Routes()
 
 	// ====================================================================
 	// HTTP 서버 설정 (Timeout 매우 중요)
@@ -168,6 +208,8 @@ func main() {
 	//   2) 이후 SIGKILL 강제 종료
 	//
 	// 우리는 SIGTERM 수신 시:
+	//   - /health/ready 를 즉시 503 으로 바꾸고 ShutdownDrainDelay 만큼 대기
+	//     (ALB 가 target 을 unhealthy 로 판정해 새 요청을 보내지 않게 됨)
 	//   - HTTP 서버 먼저 멈춰서 더 이상 요청 수신하지 않음
 	//   - Manager.Shutdown() 호출하여 내부 goroutine 안전 종료
 	//
@@ -184,6 +226,15 @@ func main() {
 			Str("signal", sig.String()).
 			Msg("shutdown signal received")
 
+		// 0) readiness 503 전환 → ALB drain 대기
+		h.BeginShutdown()
+		if cfg.ShutdownDrainDelay > 0 {
+			log.Info().
+				Dur("delay", cfg.ShutdownDrainDelay).
+				Msg("readiness set to not ready, waiting for ALB to drain")
+			time.Sleep(cfg.ShutdownDrainDelay)
+		}
+
 		// 1) HTTP 서버 종료 (새 요청 막기)
 		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		if err := srv.Shutdown(ctx); err != nil {
@@ -5,11 +5,55 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // distroless 이미지에도 IANA 타임존 DB 를 보장
+
+	"estat-ingest/internal/partition"
+	"estat-ingest/internal/tenant"
+)
+
+// TenantSourceNames 는 TENANT_SOURCES 에 사용할 수 있는 tenant 판정 방식이다.
+var TenantSourceNames = []string{"path", "header", "api_key"}
+
+// ClientIPHeaderNames 는 CLIENT_IP_HEADERS 에 사용할 수 있는 헤더 이름이다.
+var ClientIPHeaderNames = []string{"X-Forwarded-For", "Forwarded", "X-Real-IP", "CloudFront-Viewer-Address"}
+
+// 클라이언트 IP 추출 기본값 (VPC 내부 ALB 에 직접 붙는 배포 기준).
+//
+// ALB 는 X-Forwarded-For 에 peer 주소를 덧붙이므로, 내부망 대역만 신뢰하고 XFF 만 본다.
+// 클라이언트가 임의로 보낼 수 있는 단일 값 헤더(X-Real-IP, CloudFront-Viewer-Address)와
+// ALB 가 덮어쓰지 않는 Forwarded 는 기본값에서 제외한다.
+//
+// CloudFront → ALB 배포에서는 XFF 의 마지막 public hop 이 CloudFront edge 주소이므로
+// 기본값으로는 edge IP 가 클라이언트로 기록된다. 이 경우 다음 중 하나를 설정해야 한다.
+//   - CLIENT_IP_HEADERS=CloudFront-Viewer-Address,X-Forwarded-For
+//     (origin request policy 에 CloudFront-Viewer-Address 포함, ALB 는 CloudFront prefix list 에서만 접근 허용)
+//   - TRUSTED_PROXIES 에 CloudFront origin-facing 대역 추가
+var (
+	defaultTrustedProxies = []string{
+		"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", // IPv4 private (VPC)
+		"127.0.0.0/8", "::1/128", // loopback
+		"fc00::/7", // IPv6 ULA
+	}
+	defaultClientIPHeaders = []string{"X-Forwarded-For"}
 )
 
+// isClientIPHeader 는 h 가 ClientIPHeaderNames 중 하나인지 대소문자 구분 없이 확인한다.
+func isClientIPHeader(h string) bool {
+	for _, n := range ClientIPHeaderNames {
+		if strings.EqualFold(h, n) {
+			return true
+		}
+	}
+	return false
+}
+
 // Config
 //
 // 서비스 실행 시 필요한 모든 환경 변수 값을 보관하는 구조체.
@@ -17,15 +61,63 @@ import (
 // 이후에는 변경되지 않는 불변(read-only) 설정들이다.
 type Config struct {
 
+	// ---------------------------
+	// 저장소(Sink) 선택
+	// ---------------------------
+	// SinkType:
+	//   - "s3"    → AWS S3 업로드 (기본값, 운영 환경)
+	//   - "local" → LocalSinkDir 하위에 S3 key 와 동일한 경로로 파일 저장
+	//               (AWS 없이 개발 노트북/CI 에서 전체 파이프라인 실행용)
+	//
+	// "local" 인 경우 AWS_REGION / RAW_BUCKET 은 필수가 아니다.
+	// --------------------------------------------
+
+	SinkType     string // "s3" | "local"
+	LocalSinkDir string // SinkType=local 일 때 객체가 저장될 루트 디렉토리
+
 	// ---------------------------
 	// AWS / S3 기본 환경
 	// ---------------------------
 
 	AWSRegion string // AWS 리전 (예: ap-northeast-2)
 
-	RawBucket string // 수집 데이터가 저장될 S3 버킷 이름
-	RawPrefix string // RAW 데이터 저장 경로 prefix (예: raw/)
-	DLQPrefix string // DLQ 데이터 저장 경로 prefix (예: dlq/)
+	// S3 호환 스토리지 (MinIO, localstack, on-prem):
+	//   - S3Endpoint 가 비어 있으면 AWS 기본 endpoint 를 사용한다.
+	//   - 대부분의 S3 호환 스토리지는 virtual-hosted 방식(<bucket>.<host>)을 쓸 수 없으므로 S3ForcePathStyle 을 켠다.
+	//   - S3AccessKeyID 가 있으면 AWS 기본 credential chain(env / profile / IRSA / IMDS) 대신 정적 credential 을 사용한다.
+	//     secret 은 S3_SECRET_ACCESS_KEY 또는 S3_SECRET_ACCESS_KEY_FILE(docker / k8s secret) 중 하나로 준다.
+	//   - S3CABundle 은 사설 CA 로 서명된 endpoint 용 PEM 파일이다 (시스템 CA 대신 사용).
+	S3Endpoint            string // S3_ENDPOINT (예: http://minio:9000)
+	S3ForcePathStyle      bool   // S3_FORCE_PATH_STYLE (기본 false)
+	S3AccessKeyID         string // S3_ACCESS_KEY_ID
+	S3SecretAccessKey     string // S3_SECRET_ACCESS_KEY
+	S3SecretAccessKeyFile string // S3_SECRET_ACCESS_KEY_FILE (앞뒤 공백 제거)
+	S3SessionToken        string // S3_SESSION_TOKEN (선택)
+	S3CABundle            string // S3_CA_BUNDLE
+
+	RawBucket     string // 수집 데이터가 저장될 S3 버킷 이름
+	RawPrefix     string // RAW 데이터 저장 경로 prefix (예: raw/)
+	BotPrefix     string // UA_BOT_POLICY=route 일 때 봇 이벤트 저장 경로 prefix (BOT_PREFIX, 기본 bot)
+	InvalidPrefix string // SCHEMA_MODE=enforce 일 때 검증 실패 이벤트 저장 경로 prefix (INVALID_PREFIX, 기본 invalid)
+	DLQPrefix     string // DLQ 데이터 저장 경로 prefix (예: dlq/)
+
+	// ---------------------------
+	// S3 Key 파티셔닝
+	// ---------------------------
+	// PartitionTimezone:
+	//   - dt/hr 등 파티션 값을 계산할 IANA 타임존 (예: "Asia/Seoul", "UTC")
+	//   - 기본값 "Asia/Seoul" (기존 KST 고정 동작과 동일)
+	//
+	// S3KeyTemplate:
+	//   - object key 템플릿. 사용 가능한 placeholder 는 internal/partition 참고.
+	//   - 기본값 "{prefix}/dt={yyyy}-{mm}-{dd}/hr={HH}/{file}"
+	//
+	// 두 값 모두 Load 시점에 검증되며, 잘못되면 즉시 종료한다.
+	// 정상 업로드와 DLQ 재업로드에 동일하게 적용된다.
+	// --------------------------------------------
+
+	PartitionTimezone string // 파티션 계산 타임존 (IANA)
+	S3KeyTemplate     string // S3 object key 템플릿
 
 	// ---------------------------
 	// 서버 식별자 / 네트워크
@@ -35,6 +127,168 @@ type Config struct {
 	InstanceID  string // ingest 프로세스 고유 ID (호스트명 기반, 실패 시 랜덤 hex)
 	HTTPAddr    string // HTTP 서버 bind 주소 (예: ":8080")
 
+	// ---------------------------
+	// 클라이언트 IP 추출
+	// ---------------------------
+	// TrustedProxyCIDRs:
+	//   - 프록시 헤더를 신뢰할 peer(ALB, CloudFront, 내부망) CIDR 목록.
+	//   - RemoteAddr 가 여기에 속하지 않으면 헤더는 무시하고 RemoteAddr 를 사용한다.
+	//   - X-Forwarded-For / Forwarded 는 오른쪽부터 이 목록에 속하지 않는 첫 hop 을 클라이언트로 본다.
+	//   - 기본값: private / loopback 대역 (VPC 내부 ALB 를 신뢰)
+	//
+	// ClientIPHeaders:
+	//   - 확인할 헤더와 우선순위. ClientIPHeaderNames 중에서 고른다.
+	//   - 기본값: X-Forwarded-For (CloudFront 앞단 배포는 defaultClientIPHeaders 주석 참고)
+	//   - 신뢰 peer 인데 어떤 헤더에서도 IP 를 얻지 못하면 프록시 주소 대신 빈 값을 기록한다.
+	// --------------------------------------------
+
+	TrustedProxyCIDRs []string // 신뢰 프록시 CIDR (TRUSTED_PROXIES, 콤마 구분)
+	ClientIPHeaders   []string // IP 헤더 우선순위 (CLIENT_IP_HEADERS, 콤마 구분)
+
+	// ---------------------------
+	// IP 익명화
+	// ---------------------------
+	// IPAnonMode:
+	//   - none     : 원본 IP 저장 (기본값)
+	//   - truncate : IPv4 /24, IPv6 /48 로 절삭
+	//   - hash     : HMAC-SHA256(salt, ip). salt 는 IPHashSaltFile 에서 읽는다.
+	//   - drop     : IP 를 저장하지 않음
+	//
+	// IPHashSaltReload:
+	//   - salt 파일을 다시 읽는 주기. 파일 내용이 바뀌면 새 salt 로 교체한다 (rotation).
+	// --------------------------------------------
+
+	IPAnonMode       string        // IP 익명화 모드 (IP_ANON_MODE)
+	IPHashSaltFile   string        // hash 모드 salt 파일 경로 (IP_HASH_SALT_FILE)
+	IPHashSaltReload time.Duration // salt 파일 reload 주기 (IP_HASH_SALT_RELOAD, 기본 1m)
+
+	// ---------------------------
+	// GeoIP enrichment
+	// ---------------------------
+	// 로컬 MaxMind mmdb 에서 익명화 전 클라이언트 IP 를 조회해 국가/지역/도시/ASN 을 붙인다.
+	// 둘 다 비어있으면 비활성화된다. 파일은 GeoIPReload 주기로 변경(mtime/size)을 확인해 다시 연다.
+	// --------------------------------------------
+
+	GeoIPDB     string        // City/Country mmdb 경로 (GEOIP_DB)
+	GeoIPASNDB  string        // ASN mmdb 경로 (GEOIP_ASN_DB)
+	GeoIPReload time.Duration // mmdb 변경 확인 주기 (GEOIP_RELOAD, 기본 1m)
+
+	// ---------------------------
+	// User-Agent 파싱 / 봇 분류
+	// ---------------------------
+	// UARulesFile:
+	//   - browsers / os / devices / bots 정규식 규칙(JSON). 비어있으면 UA 파싱을 하지 않는다.
+	//
+	// UABotPolicy:
+	//   - tag   : is_bot 표시만 하고 RAW 에 함께 저장 (기본값)
+	//   - route : 봇 이벤트는 BotPrefix 로 분리 저장
+	//   - drop  : 봇 요청은 enqueue 하지 않고 204 (별도 카운터)
+	//   - route / drop 은 UARulesFile 이 필요하다.
+	// --------------------------------------------
+
+	UARulesFile string // UA 규칙 파일 경로 (UA_RULES_FILE)
+	UABotPolicy string // 봇 처리 정책 (UA_BOT_POLICY)
+	UACacheSize int    // UA 파싱 결과 캐시 항목 수 상한 (UA_CACHE_SIZE, 기본 10000)
+
+	// ---------------------------
+	// Body 구조화
+	// ---------------------------
+	// BodyFormat:
+	//   - raw        : body 문자열만 저장 (기본값, 기존 동작)
+	//   - structured : body 는 그대로 두고, 인코딩 시 아래 필드를 추가한다.
+	//       GET QueryString → params (key → 값, 같은 key 가 여러 번이면 값 배열)
+	//       POST 유효한 JSON → body_json (escape 된 문자열이 아닌 JSON 값)
+	//
+	// 한도를 넘거나 디코딩할 수 없는 body 는 body 문자열로만 남기고 별도 카운터로 센다.
+	// --------------------------------------------
+
+	BodyFormat         string // body 저장 형식 (BODY_FORMAT)
+	StructuredMaxKeys  int    // params 최대 key=value pair 수 (STRUCTURED_MAX_KEYS, 기본 100)
+	StructuredMaxBytes int    // 구조화할 body 최대 바이트 (STRUCTURED_MAX_BYTES, 기본 64KiB)
+
+	// ---------------------------
+	// JSON Schema 검증
+	// ---------------------------
+	// SchemaDir 의 <이벤트 타입>.json 파일을 schema 로 읽는다 (파일 이름이 곧 타입, [A-Za-z0-9_-]).
+	// 이벤트 타입은 body(JSON 또는 QueryString)의 SchemaTypeField 값이다.
+	//
+	// SchemaMode:
+	//   - off     : 검증하지 않음 (기본값)
+	//   - report  : 검증 결과만 schema 별로 센다. 저장 위치는 바뀌지 않는다.
+	//   - enforce : 검증 실패 이벤트는 validation_error 와 함께 InvalidPrefix 에 저장한다.
+	//   - schema 가 없는 타입은 검증하지 않고 RAW 에 저장한다 (schema_unmatched_total).
+	// --------------------------------------------
+
+	SchemaMode      string // 검증 모드 (SCHEMA_MODE)
+	SchemaDir       string // schema 디렉토리 (SCHEMA_DIR, report / enforce 에서 필수)
+	SchemaTypeField string // 이벤트 타입 필드 이름 (SCHEMA_TYPE_FIELD, 기본 e)
+
+	// ---------------------------
+	// Multi-tenant
+	// ---------------------------
+	// TenantsFile 이 비어있으면 single-tenant (RAW_BUCKET / RAW_PREFIX, 기존 동작)이다.
+	//
+	// TenantSources:
+	//   - 요청의 tenant 를 판정하는 방식과 우선순위. TenantSourceNames 중에서 고른다.
+	//   - path    : /collect/{tenant}, /collect/{tenant}/batch
+	//   - header  : TenantHeader 값
+	//   - api_key : APIKeyHeader 값을 tenant 테이블의 api_keys 에서 찾는다
+	//   - 어떤 방식으로도 판정하지 못하면 TenantDefault, 그것도 없으면 404.
+	//   - 판정된 tenant 가 테이블에 없거나 enabled=false 이면 404 (별도 카운터).
+	//
+	// Tenants 는 config.Load 가 TenantsFile 을 읽어 채운다 (생략 값은 RAW_BUCKET 등으로 채움).
+	// --------------------------------------------
+
+	TenantsFile   string        // tenant 테이블 파일 (TENANTS_FILE)
+	TenantSources []string      // tenant 판정 순서 (TENANT_SOURCES, 기본 path,header,api_key)
+	TenantHeader  string        // tenant id 헤더 (TENANT_HEADER, 기본 X-Tenant-ID)
+	TenantDefault string        // 판정 실패 시 사용할 tenant id (TENANT_DEFAULT, 비우면 404)
+	APIKeyHeader  string        // API key 헤더 (API_KEY_HEADER, 기본 X-API-Key)
+	Tenants       *tenant.Table // TenantsFile 미설정 시 nil
+
+	// ---------------------------
+	// 인증 (server-to-server)
+	// ---------------------------
+	// AuthCollect / AuthBatch 는 route 별 인증 방식이다 (none | api_key | hmac, 기본 none).
+	//   - api_key : APIKeyHeader 값이 AuthKeysFile 의 secret 중 하나와 같아야 한다.
+	//   - hmac    : AuthKeyIDHeader 의 key 로 HMAC-SHA256(secret, "<timestamp>.<body>") 를 계산해
+	//               AuthSignatureHeader(hex) 와 비교한다. timestamp(unix 초, AuthTimestampHeader)는
+	//               현재 시각과 AuthHMACWindow 이상 차이나면 거절한다 (재전송 공격 방지).
+	//               body 는 Content-Encoding 해제 후의 평문, GET 은 QueryString 이다.
+	//
+	// 인증 정보가 없으면 401, 있지만 틀리면 403.
+	// BeaconPath 는 브라우저 beacon 용 익명 경로이다 (항상 인증 없음).
+	// 인증 없이 raw 버킷에 쓸 수 있는 경로이므로 opt-in 이다: 설정하지 않으면 등록하지 않는다.
+	// --------------------------------------------
+
+	AuthKeysFile        string        // "<key id> <secret>" 한 줄에 하나 (AUTH_KEYS_FILE)
+	AuthCollect         string        // /collect 인증 방식 (AUTH_COLLECT)
+	AuthBatch           string        // /collect/batch 인증 방식 (AUTH_BATCH)
+	AuthKeyIDHeader     string        // hmac key id 헤더 (AUTH_KEY_ID_HEADER, 기본 X-Key-Id)
+	AuthTimestampHeader string        // hmac timestamp 헤더 (AUTH_TIMESTAMP_HEADER, 기본 X-Timestamp)
+	AuthSignatureHeader string        // hmac 서명 헤더 (AUTH_SIGNATURE_HEADER, 기본 X-Signature)
+	AuthHMACWindow      time.Duration // 허용 시각 차이 (AUTH_HMAC_WINDOW, 기본 5m)
+	BeaconPath          string        // 익명 beacon 경로 (BEACON_PATH, 기본 비활성, 예: /beacon)
+
+	// ---------------------------
+	// Client 별 rate limit
+	// ---------------------------
+	// RateLimitKey:
+	//   - off     : 사용하지 않음 (기본값, EventCh full 503 만 존재)
+	//   - ip      : 클라이언트 IP (익명화 전, TRUSTED_PROXIES / CLIENT_IP_HEADERS 기준)
+	//   - api_key : APIKeyHeader 값. 헤더가 없는 요청은 IP 로 센다
+	//   - tenant  : 판정된 tenant (TENANTS_FILE 필요)
+	//
+	// key 마다 초당 RateLimit 개, 최대 RateLimitBurst 개의 token bucket 을 둔다 (요청 하나 = token 하나).
+	// 메모리는 RateLimitMaxKeys 개 key 로 제한하며, 넘치면 가장 오래 쓰지 않은 key 부터 버린다.
+	// 초과 요청은 429 + Retry-After 이다.
+	// --------------------------------------------
+
+	RateLimitKey     string  // key 종류 (RATE_LIMIT_KEY)
+	RateLimit        float64 // key 당 초당 요청 수 (RATE_LIMIT, RATE_LIMIT_KEY 설정 시 필수)
+	RateLimitBurst   int     // key 당 burst (RATE_LIMIT_BURST, 기본 RATE_LIMIT 1초치)
+	RateLimitMaxKeys int     // 추적할 key 수 상한 (RATE_LIMIT_MAX_KEYS, 기본 100000)
+
 	// ---------------------------
 	// 로깅 설정
 	// ---------------------------
@@ -65,11 +319,17 @@ type Config struct {
 	// 요청 처리 파라미터
 	// ---------------------------
 
-	MaxBodySize   int64         // 단일 HTTP 요청 body 최대 크기 (바이트)
-	ChannelSize   int           // EventCh 버퍼 크기
-	UploadQueue   int           // uploadCh 버퍼 크기
-	BatchSize     int           // 배치 크기 (N개 모이면 S3로 업로드)
-	FlushInterval time.Duration // 배치 flush 주기 (시간 기반 flush)
+	MaxBodySize             int64         // 단일 HTTP 요청 body 최대 크기 (바이트, 압축 상태 기준)
+	MaxDecompressedBodySize int64         // Content-Encoding 해제 후 body 최대 크기 (바이트, zip bomb 방지)
+	MaxBatchEvents          int           // /collect/batch 단일 요청당 최대 이벤트 수
+	ChannelSize             int           // EventCh 버퍼 크기
+	AdmissionWait           time.Duration // EventCh full 시 빈 자리를 기다리는 최대 시간 (ADMISSION_WAIT, 기본 0 = 즉시 503)
+	QueueFullRetryAfter     time.Duration // EventCh full 503 의 Retry-After (QUEUE_FULL_RETRY_AFTER, 기본 1s)
+	UploadQueue             int           // uploadCh 버퍼 크기
+	UploadWorkers           int           // uploadCh 를 공유하는 업로드 worker 수 (기본 1)
+	BatchSize               int           // 배치 크기 (N개 모이면 S3로 업로드)
+	BatchMaxBytes           int64         // 배치의 압축 전 크기 상한 (BATCH_MAX_BYTES, 기본 8MB)
+	FlushInterval           time.Duration // 배치 flush 주기 (시간 기반 flush)
 
 	// ---------------------------
 	// S3 업로드 설정
@@ -86,6 +346,24 @@ type Config struct {
 	S3Timeout    time.Duration // 각 S3 PutObject 시도당 timeout
 	S3AppRetries int           // S3 업로드 재시도 횟수 (SDK retry는 항상 0)
 
+	// Multipart streaming:
+	//   - 배치의 압축 전 크기 추정치가 S3MultipartThreshold 이상이면, 전체 gzip 결과를 메모리에 만들지 않고
+	//     S3PartSize 단위로 잘라 multipart upload 한다 (in-flight 배치당 메모리 ≈ S3PartSize).
+	//   - 재시도는 part 단위(S3AppRetries)이며, 실패 시 multipart upload 를 abort 하고 DLQ 로 보낸다.
+	//   - S3 는 마지막 part 를 제외하고 5MB 이상을 요구한다.
+	//   - 배치는 BatchMaxBytes 에서 flush 되므로 임계값은 BatchMaxBytes 이하여야 한다.
+	S3MultipartThreshold int64 // S3_MULTIPART_THRESHOLD (기본 BATCH_MAX_BYTES)
+	S3PartSize           int64 // S3_PART_SIZE (기본 8MB, 최소 5MB)
+
+	// Circuit breaker (S3 장애 시 재시도 없이 바로 DLQ):
+	//   - 재시도까지 모두 실패한 업로드(배치 / DLQ 파일)가 연속 S3BreakerFailures 회이면 open 된다.
+	//     non-retryable 오류(AccessDenied, NoSuchBucket 등 tenant 별 문제)는 세지 않는다.
+	//   - open 동안 새 배치는 S3 호출 없이 DLQ 에 저장하고, DLQ 재업로드는 멈춘다.
+	//   - S3BreakerCooldown 이 지나면 half-open 이 되어 1건만 probe 로 보낸다. 성공하면 closed, 실패하면 다시 open.
+	S3BreakerEnabled  bool          // S3_BREAKER_ENABLED (기본 false)
+	S3BreakerFailures int           // S3_BREAKER_FAILURES (기본 5)
+	S3BreakerCooldown time.Duration // S3_BREAKER_COOLDOWN (기본 30s)
+
 	// ---------------------------
 	// 로컬 DLQ (Dead Letter Queue)
 	// ---------------------------
@@ -93,6 +371,49 @@ type Config struct {
 	DLQDir          string        // 로컬 DLQ 디렉토리 경로
 	DLQMaxAge       time.Duration // DLQ 파일 TTL (초과 시 삭제)
 	DLQMaxSizeBytes int64         // DLQ 전체 허용 용량 (바이트)
+
+	// DLQRescanInterval:
+	//   - DLQ 디렉토리를 다시 스캔하여 용량/파일 수 회계를 디스크 기준으로 맞추는 주기
+	//   - dlqctl 이 서버 밖에서 파일을 purge / quarantine / replay 한 결과를 반영한다.
+	DLQRescanInterval time.Duration // DLQ_RESCAN_INTERVAL (기본 30s)
+
+	// ---------------------------
+	// WAL (Write-Ahead Log)
+	// ---------------------------
+	// 활성화 시 /collect 는 이벤트를 WAL 세그먼트에 기록하고 fsync 가 끝난 뒤에 200 을 반환한다.
+	// EventCh / collectLoop 배치 / uploadCh 에 머물던 이벤트가 OOM-kill, SIGKILL 로 유실되지 않도록
+	// 재시작 시 NewManager 가 남아있는 세그먼트를 replay 한다.
+	//
+	// WALSyncInterval:
+	//   - group commit 주기. 이 주기 동안 들어온 append 를 fsync 한 번으로 묶는다.
+	//   - 요청 latency 가 최대 이 값만큼 늘어나는 대신 fsync 횟수가 주기당 1회로 고정된다.
+	// --------------------------------------------
+
+	WALEnabled      bool          // WAL 사용 여부 (기본 false)
+	WALDir          string        // WAL 세그먼트 디렉토리 (DLQDir 과 분리 권장)
+	WALSegmentSize  int64         // 세그먼트 rotate 기준 크기 (바이트)
+	WALSyncInterval time.Duration // group commit fsync 주기
+
+	// ---------------------------
+	// Readiness (/health/ready)
+	// ---------------------------
+	// /health/live 는 프로세스가 살아있으면 항상 200 이지만,
+	// /health/ready 는 아래 임계값 중 하나라도 넘으면 503 을 반환하여
+	// ALB 가 해당 target 으로 새 요청을 보내지 않도록 한다.
+	//
+	// ShutdownDrainDelay:
+	//   - SIGTERM 수신 후 /health/ready 를 503 으로 바꾼 뒤 srv.Shutdown 까지 기다리는 시간.
+	//   - ALB health check 가 target 을 unhealthy 로 판정할 만큼(interval × threshold) 잡아야 한다.
+	//   - 로컬 실행처럼 ALB 가 없으면 0 으로 끌 수 있다.
+	//
+	// ReadyMaxS3Failures:
+	//   - 재시도(S3_APP_RETRIES)까지 모두 실패한 업로드(배치 또는 DLQ 파일) 수 기준이다. 시도 횟수가 아니다.
+	// --------------------------------------------
+
+	ReadyQueueRatio    float64       // EventCh 사용률 임계값 (기본 0.9)
+	ReadyDLQRatio      float64       // DLQSizeBytes / DLQMaxSizeBytes 임계값 (기본 0.9)
+	ReadyMaxS3Failures int           // 연속 S3 업로드 실패 임계값, 재시도 소진 기준 (기본 5)
+	ShutdownDrainDelay time.Duration // SIGTERM → srv.Shutdown 사이 대기 시간 (기본 5s, 0 허용)
 }
 
 // Load
@@ -101,34 +422,318 @@ type Config struct {
 // 필수 env 가 비어있으면 즉시 프로세스를 종료(fail-fast).
 // 운영/배포 환경에서 반드시 설정해야 하는 값들이다.
 func Load() Config {
-	return Config{
-		AWSRegion: must("AWS_REGION"),
+	cfg := Config{
+		SinkType:     getenvDefault("SINK_TYPE", "s3"),
+		LocalSinkDir: getenvDefault("LOCAL_SINK_DIR", "/tmp/sink"),
 
-		RawBucket: must("RAW_BUCKET"),
 		RawPrefix: must("RAW_PREFIX"),
 		DLQPrefix: must("DLQ_PREFIX"),
 
+		PartitionTimezone: getenvDefault("PARTITION_TZ", partition.DefaultTimezone),
+		S3KeyTemplate:     getenvDefault("S3_KEY_TEMPLATE", partition.DefaultTemplate),
+
 		ServiceName: "estat-ingest",
 		InstanceID:  fallbackInstanceID(),
 		HTTPAddr:    must("HTTP_ADDR"),
 
+		TrustedProxyCIDRs: optList("TRUSTED_PROXIES", defaultTrustedProxies),
+		ClientIPHeaders:   optList("CLIENT_IP_HEADERS", defaultClientIPHeaders),
+
+		IPAnonMode:       getenvDefault("IP_ANON_MODE", "none"),
+		IPHashSaltFile:   os.Getenv("IP_HASH_SALT_FILE"),
+		IPHashSaltReload: optDur("IP_HASH_SALT_RELOAD", time.Minute),
+
+		GeoIPDB:     os.Getenv("GEOIP_DB"),
+		GeoIPASNDB:  os.Getenv("GEOIP_ASN_DB"),
+		GeoIPReload: optDur("GEOIP_RELOAD", time.Minute),
+
+		UARulesFile: os.Getenv("UA_RULES_FILE"),
+		UABotPolicy: getenvDefault("UA_BOT_POLICY", "tag"),
+		UACacheSize: optInt("UA_CACHE_SIZE", 10000),
+		BotPrefix:   getenvDefault("BOT_PREFIX", "bot"),
+
+		BodyFormat:         getenvDefault("BODY_FORMAT", "raw"),
+		StructuredMaxKeys:  optInt("STRUCTURED_MAX_KEYS", 100),
+		StructuredMaxBytes: optInt("STRUCTURED_MAX_BYTES", 64<<10),
+
+		SchemaMode:      getenvDefault("SCHEMA_MODE", "off"),
+		SchemaDir:       os.Getenv("SCHEMA_DIR"),
+		SchemaTypeField: getenvDefault("SCHEMA_TYPE_FIELD", "e"),
+		InvalidPrefix:   getenvDefault("INVALID_PREFIX", "invalid"),
+
+		TenantsFile:   os.Getenv("TENANTS_FILE"),
+		TenantSources: optList("TENANT_SOURCES", []string{"path", "header", "api_key"}),
+		TenantHeader:  getenvDefault("TENANT_HEADER", "X-Tenant-ID"),
+		TenantDefault: os.Getenv("TENANT_DEFAULT"),
+		APIKeyHeader:  getenvDefault("API_KEY_HEADER", "X-API-Key"),
+
+		AuthKeysFile:        os.Getenv("AUTH_KEYS_FILE"),
+		AuthCollect:         getenvDefault("AUTH_COLLECT", "none"),
+		AuthBatch:           getenvDefault("AUTH_BATCH", "none"),
+		AuthKeyIDHeader:     getenvDefault("AUTH_KEY_ID_HEADER", "X-Key-Id"),
+		AuthTimestampHeader: getenvDefault("AUTH_TIMESTAMP_HEADER", "X-Timestamp"),
+		AuthSignatureHeader: getenvDefault("AUTH_SIGNATURE_HEADER", "X-Signature"),
+		AuthHMACWindow:      optDur("AUTH_HMAC_WINDOW", 5*time.Minute),
+		BeaconPath:          os.Getenv("BEACON_PATH"),
+
+		RateLimitKey:     getenvDefault("RATE_LIMIT_KEY", "off"),
+		RateLimit:        optFloat("RATE_LIMIT", 0),
+		RateLimitBurst:   optInt("RATE_LIMIT_BURST", 0),
+		RateLimitMaxKeys: optInt("RATE_LIMIT_MAX_KEYS", 100000),
+
 		LogLevel:   getenvDefault("LOG_LEVEL", "info"),
 		LogPretty:  optBool("LOG_PRETTY", false),
 		LogSampleN: optInt("LOG_SAMPLE_N", 1),
 
-		MaxBodySize:   mustInt64("MAX_BODY_SIZE"),
-		ChannelSize:   mustInt("CHANNEL_SIZE"),
-		UploadQueue:   mustInt("UPLOAD_QUEUE"),
-		BatchSize:     mustInt("BATCH_SIZE"),
-		FlushInterval: mustDur("FLUSH_INTERVAL"),
+		MaxBodySize:             mustInt64("MAX_BODY_SIZE"),
+		MaxDecompressedBodySize: optInt64("MAX_DECOMPRESSED_BODY_SIZE", 1<<20),
+		MaxBatchEvents:          optInt("MAX_BATCH_EVENTS", 500),
+		ChannelSize:             mustInt("CHANNEL_SIZE"),
+		AdmissionWait:           optDurAllowZero("ADMISSION_WAIT", 0),
+		QueueFullRetryAfter:     optDur("QUEUE_FULL_RETRY_AFTER", time.Second),
+		UploadQueue:             mustInt("UPLOAD_QUEUE"),
+		UploadWorkers:           optInt("UPLOAD_WORKERS", 1),
+		BatchSize:               mustInt("BATCH_SIZE"),
+		BatchMaxBytes:           optInt64("BATCH_MAX_BYTES", 8<<20),
+		FlushInterval:           mustDur("FLUSH_INTERVAL"),
 
 		S3Timeout:    mustDur("S3_TIMEOUT"),
 		S3AppRetries: mustInt("S3_APP_RETRIES"),
 
+		S3Endpoint:            os.Getenv("S3_ENDPOINT"),
+		S3ForcePathStyle:      optBool("S3_FORCE_PATH_STYLE", false),
+		S3AccessKeyID:         os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey:     os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3SecretAccessKeyFile: os.Getenv("S3_SECRET_ACCESS_KEY_FILE"),
+		S3SessionToken:        os.Getenv("S3_SESSION_TOKEN"),
+		S3CABundle:            os.Getenv("S3_CA_BUNDLE"),
+
+		S3PartSize: optInt64("S3_PART_SIZE", 8<<20),
+
+		S3BreakerEnabled:  optBool("S3_BREAKER_ENABLED", false),
+		S3BreakerFailures: optInt("S3_BREAKER_FAILURES", 5),
+		S3BreakerCooldown: optDur("S3_BREAKER_COOLDOWN", 30*time.Second),
+
 		DLQDir:          must("DLQ_DIR"),
 		DLQMaxAge:       mustDur("DLQ_MAX_AGE"),
 		DLQMaxSizeBytes: mustInt64("DLQ_MAX_SIZE_BYTES"),
+
+		DLQRescanInterval: optDur("DLQ_RESCAN_INTERVAL", 30*time.Second),
+
+		WALEnabled:      optBool("WAL_ENABLED", false),
+		WALDir:          getenvDefault("WAL_DIR", "/tmp/wal"),
+		WALSegmentSize:  optInt64("WAL_SEGMENT_SIZE", 64<<20),
+		WALSyncInterval: optDur("WAL_SYNC_INTERVAL", 10*time.Millisecond),
+
+		ReadyQueueRatio:    optFloat("READY_QUEUE_RATIO", 0.9),
+		ReadyDLQRatio:      optFloat("READY_DLQ_RATIO", 0.9),
+		ReadyMaxS3Failures: optInt("READY_MAX_S3_FAILURES", 5),
+		ShutdownDrainDelay: optDurAllowZero("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+	}
+
+	// 파티션 설정 검증 (fail-fast)
+	if _, err := time.LoadLocation(cfg.PartitionTimezone); err != nil {
+		log.Fatalf("invalid env PARTITION_TZ=%q: %v", cfg.PartitionTimezone, err)
+	}
+	keyTemplate, err := partition.Parse(cfg.S3KeyTemplate)
+	if err != nil {
+		log.Fatalf("invalid env S3_KEY_TEMPLATE: %v", err)
+	}
+
+	// 클라이언트 IP 설정 검증 (fail-fast)
+	for _, c := range cfg.TrustedProxyCIDRs {
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			log.Fatalf("invalid env TRUSTED_PROXIES entry %q: %v", c, err)
+		}
+	}
+	for _, h := range cfg.ClientIPHeaders {
+		if !isClientIPHeader(h) {
+			log.Fatalf("invalid env CLIENT_IP_HEADERS entry %q (expected one of %v)", h, ClientIPHeaderNames)
+		}
+	}
+
+	// IP 익명화 설정 검증 (fail-fast)
+	switch cfg.IPAnonMode {
+	case "none", "truncate", "drop":
+	case "hash":
+		if cfg.IPHashSaltFile == "" {
+			log.Fatalf("missing required env: IP_HASH_SALT_FILE (IP_ANON_MODE=hash)")
+		}
+		b, err := os.ReadFile(cfg.IPHashSaltFile)
+		if err != nil {
+			log.Fatalf("invalid env IP_HASH_SALT_FILE=%q: %v", cfg.IPHashSaltFile, err)
+		}
+		if len(strings.TrimSpace(string(b))) == 0 {
+			log.Fatalf("invalid env IP_HASH_SALT_FILE=%q: empty salt", cfg.IPHashSaltFile)
+		}
+	default:
+		log.Fatalf("invalid env IP_ANON_MODE=%q (expected none|truncate|hash|drop)", cfg.IPAnonMode)
+	}
+
+	// GeoIP DB 검증 (fail-fast, 형식 검증은 서버 시작 시 maxminddb.Open 이 수행)
+	for key, path := range map[string]string{"GEOIP_DB": cfg.GeoIPDB, "GEOIP_ASN_DB": cfg.GeoIPASNDB} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			log.Fatalf("invalid env %s=%q: %v", key, path, err)
+		}
+	}
+
+	// UA 설정 검증 (fail-fast, 규칙 형식 검증은 서버 시작 시 수행)
+	if cfg.UARulesFile != "" {
+		if _, err := os.Stat(cfg.UARulesFile); err != nil {
+			log.Fatalf("invalid env UA_RULES_FILE=%q: %v", cfg.UARulesFile, err)
+		}
+	}
+	switch cfg.UABotPolicy {
+	case "tag":
+	case "route", "drop":
+		if cfg.UARulesFile == "" {
+			log.Fatalf("missing required env: UA_RULES_FILE (UA_BOT_POLICY=%s)", cfg.UABotPolicy)
+		}
+	default:
+		log.Fatalf("invalid env UA_BOT_POLICY=%q (expected tag|route|drop)", cfg.UABotPolicy)
+	}
+
+	if cfg.BodyFormat != "raw" && cfg.BodyFormat != "structured" {
+		log.Fatalf("invalid env BODY_FORMAT=%q (expected raw|structured)", cfg.BodyFormat)
+	}
+
+	// Schema 설정 검증 (fail-fast, schema 형식 검증은 서버 시작 시 수행)
+	switch cfg.SchemaMode {
+	case "off":
+	case "report", "enforce":
+		if cfg.SchemaDir == "" {
+			log.Fatalf("missing required env: SCHEMA_DIR (SCHEMA_MODE=%s)", cfg.SchemaMode)
+		}
+		if st, err := os.Stat(cfg.SchemaDir); err != nil || !st.IsDir() {
+			log.Fatalf("invalid env SCHEMA_DIR=%q: not a directory (%v)", cfg.SchemaDir, err)
+		}
+	default:
+		log.Fatalf("invalid env SCHEMA_MODE=%q (expected off|report|enforce)", cfg.SchemaMode)
+	}
+
+	// 인증 설정 검증 (fail-fast, key 파일 형식 검증은 서버 시작 시 수행)
+	for _, e := range []struct{ key, mode string }{
+		{"AUTH_COLLECT", cfg.AuthCollect},
+		{"AUTH_BATCH", cfg.AuthBatch},
+	} {
+		switch e.mode {
+		case "none":
+		case "api_key", "hmac":
+			if cfg.AuthKeysFile == "" {
+				log.Fatalf("missing required env: AUTH_KEYS_FILE (%s=%s)", e.key, e.mode)
+			}
+		default:
+			log.Fatalf("invalid env %s=%q (expected none|api_key|hmac)", e.key, e.mode)
+		}
+	}
+	if cfg.AuthKeysFile != "" {
+		if _, err := os.Stat(cfg.AuthKeysFile); err != nil {
+			log.Fatalf("invalid env AUTH_KEYS_FILE=%q: %v", cfg.AuthKeysFile, err)
+		}
+	}
+	if cfg.BeaconPath != "" && !strings.HasPrefix(cfg.BeaconPath, "/") {
+		log.Fatalf("invalid env BEACON_PATH=%q (must start with /)", cfg.BeaconPath)
 	}
+
+	// S3 호환 스토리지 설정 검증
+	if cfg.S3Endpoint != "" {
+		u, err := url.Parse(cfg.S3Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalf("invalid env S3_ENDPOINT=%q (expected http(s)://host[:port])", cfg.S3Endpoint)
+		}
+	}
+	if cfg.S3SecretAccessKey != "" && cfg.S3SecretAccessKeyFile != "" {
+		log.Fatalf("invalid env S3_SECRET_ACCESS_KEY_FILE=%q (S3_SECRET_ACCESS_KEY is also set)", cfg.S3SecretAccessKeyFile)
+	}
+	hasSecret := cfg.S3SecretAccessKey != "" || cfg.S3SecretAccessKeyFile != ""
+	if cfg.S3AccessKeyID != "" && !hasSecret {
+		log.Fatalf("missing required env: S3_SECRET_ACCESS_KEY or S3_SECRET_ACCESS_KEY_FILE (S3_ACCESS_KEY_ID=%s)", cfg.S3AccessKeyID)
+	}
+	if cfg.S3AccessKeyID == "" && (hasSecret || cfg.S3SessionToken != "") {
+		log.Fatalf("missing required env: S3_ACCESS_KEY_ID (static S3 credentials)")
+	}
+	for _, e := range []struct{ key, path string }{
+		{"S3_SECRET_ACCESS_KEY_FILE", cfg.S3SecretAccessKeyFile},
+		{"S3_CA_BUNDLE", cfg.S3CABundle},
+	} {
+		if e.path == "" {
+			continue
+		}
+		if _, err := os.Stat(e.path); err != nil {
+			log.Fatalf("invalid env %s=%q: %v", e.key, e.path, err)
+		}
+	}
+
+	if cfg.S3PartSize < 5<<20 {
+		log.Fatalf("invalid env S3_PART_SIZE=%d (must be >= %d)", cfg.S3PartSize, 5<<20)
+	}
+
+	// Multipart 임계값: 배치는 BATCH_MAX_BYTES 에서 flush 되므로 그보다 큰 임계값에는 도달하지 않는다.
+	// 지정하지 않으면 BATCH_MAX_BYTES 를 쓴다 (크기 상한으로 flush 된 배치는 streaming 업로드).
+	cfg.S3MultipartThreshold = optInt64("S3_MULTIPART_THRESHOLD", cfg.BatchMaxBytes)
+	if cfg.S3MultipartThreshold > cfg.BatchMaxBytes {
+		log.Fatalf("invalid env S3_MULTIPART_THRESHOLD=%d (must be <= BATCH_MAX_BYTES=%d)", cfg.S3MultipartThreshold, cfg.BatchMaxBytes)
+	}
+
+	// Rate limit 설정 검증
+	switch cfg.RateLimitKey {
+	case "off":
+	case "ip", "api_key", "tenant":
+		if cfg.RateLimit == 0 {
+			log.Fatalf("missing required env: RATE_LIMIT (RATE_LIMIT_KEY=%s)", cfg.RateLimitKey)
+		}
+		if cfg.RateLimitBurst == 0 {
+			cfg.RateLimitBurst = max(int(cfg.RateLimit), 1)
+		}
+	default:
+		log.Fatalf("invalid env RATE_LIMIT_KEY=%q (expected off|ip|api_key|tenant)", cfg.RateLimitKey)
+	}
+
+	// Sink 종류에 따라 필수 env 가 달라진다.
+	switch cfg.SinkType {
+	case "s3":
+		cfg.AWSRegion = must("AWS_REGION")
+		cfg.RawBucket = must("RAW_BUCKET")
+	case "local":
+		cfg.AWSRegion = os.Getenv("AWS_REGION")
+		cfg.RawBucket = os.Getenv("RAW_BUCKET")
+	default:
+		log.Fatalf("invalid env SINK_TYPE=%q (expected s3|local)", cfg.SinkType)
+	}
+
+	// Tenant 테이블 로드 (fail-fast). 생략된 bucket / prefix / max_body_size 는 전역 값으로 채운다.
+	for _, src := range cfg.TenantSources {
+		if !slices.Contains(TenantSourceNames, src) {
+			log.Fatalf("invalid env TENANT_SOURCES entry %q (expected one of %v)", src, TenantSourceNames)
+		}
+	}
+	if cfg.TenantsFile != "" {
+		t, err := tenant.Load(cfg.TenantsFile, tenant.Defaults{
+			Bucket:      cfg.RawBucket,
+			Prefix:      cfg.RawPrefix,
+			MaxBodySize: cfg.MaxBodySize,
+		})
+		if err != nil {
+			log.Fatalf("invalid env TENANTS_FILE=%q: %v", cfg.TenantsFile, err)
+		}
+		if cfg.TenantDefault != "" {
+			if _, ok := t.Get(cfg.TenantDefault); !ok {
+				log.Fatalf("invalid env TENANT_DEFAULT=%q: not in TENANTS_FILE", cfg.TenantDefault)
+			}
+		}
+		cfg.Tenants = t
+	}
+	if cfg.RateLimitKey == "tenant" && cfg.Tenants == nil {
+		log.Fatalf("missing required env: TENANTS_FILE (RATE_LIMIT_KEY=tenant)")
+	}
+	if keyTemplate.HasTenant() && cfg.Tenants == nil {
+		log.Fatalf("missing required env: TENANTS_FILE (S3_KEY_TEMPLATE=%s uses {tenant})", cfg.S3KeyTemplate)
+	}
+
+	return cfg
 }
 
 // must / mustInt / mustInt64 / mustDur
@@ -219,6 +824,90 @@ func optInt(key string, def int) int {
 	return n
 }
 
+func optInt64(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Printf("invalid int64 env %s=%q: %v (fallback=%d)", key, v, err, def)
+		return def
+	}
+	if n <= 0 {
+		log.Printf("non-positive int64 env %s=%q: fallback=%d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// optList 는 콤마로 구분된 목록을 읽는다. (공백 제거, 빈 항목 무시)
+func optList(key string, def []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func optFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("invalid float env %s=%q: %v (fallback=%g)", key, v, err, def)
+		return def
+	}
+	if f <= 0 {
+		log.Printf("non-positive float env %s=%q: fallback=%g", key, v, def)
+		return def
+	}
+	return f
+}
+
+func optDur(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("invalid duration env %s=%q: %v (fallback=%s)", key, v, err, def)
+		return def
+	}
+	if d <= 0 {
+		log.Printf("non-positive duration env %s=%q: fallback=%s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// optDurAllowZero 는 optDur 과 같지만 0 을 "비활성화" 값으로 허용한다 (음수만 fallback).
+func optDurAllowZero(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("invalid duration env %s=%q: %v (fallback=%s)", key, v, err, def)
+		return def
+	}
+	if d < 0 {
+		log.Printf("negative duration env %s=%q: fallback=%s", key, v, def)
+		return def
+	}
+	return d
+}
+
 // fallbackInstanceID
 //
 // 이 ingest 서버 인스턴스를 식별하는 고유 값.
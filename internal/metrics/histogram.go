@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+// Histogram 은 고정 bucket 기반의 lock-free 분포 지표이다.
+//
+// Observe 는 hot path(요청 처리, 업로드)에서 호출되므로
+// mutex 없이 atomic 연산만 사용한다.
+//   - bucket 카운트 : bucket 별 atomic 증가 (누적 합은 출력 시 계산)
+//   - sum          : float64 bit 를 uint64 로 CAS 갱신
+//
+// 출력 시점에 bucket 간 값이 완벽히 일치하지 않을 수 있으나(스냅샷이 아님),
+// 모니터링 용도로는 충분하다.
+type Histogram struct {
+	bounds  []float64 // 오름차순 upper bound (le)
+	counts  []int64   // len(bounds)+1, 마지막은 +Inf bucket
+	count   int64
+	sumBits uint64
+}
+
+// NewHistogram 은 주어진 upper bound 들로 Histogram 을 생성한다.
+// bounds 는 오름차순이어야 한다.
+func NewHistogram(bounds ...float64) *Histogram {
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// Observe 는 값 v 를 기록한다.
+func (h *Histogram) Observe(v float64) {
+	// bounds[i] >= v 를 만족하는 첫 번째 bucket (le 의미)
+	i := sort.SearchFloat64s(h.bounds, v)
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.count, 1)
+
+	for {
+		old := atomic.LoadUint64(&h.sumBits)
+		sum := math.Float64frombits(old) + v
+		if atomic.CompareAndSwapUint64(&h.sumBits, old, math.Float64bits(sum)) {
+			return
+		}
+	}
+}
+
+// Count 는 관측 횟수를 반환한다.
+func (h *Histogram) Count() int64 {
+	return atomic.LoadInt64(&h.count)
+}
+
+// Sum 은 관측값 합계를 반환한다.
+func (h *Histogram) Sum() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&h.sumBits))
+}
@@ -42,6 +42,171 @@ type Metrics struct {
     // - 비율: 이 값 / HTTPRequestsTotal → "시스템 과부하로 인한 드랍 비율".
     HTTPRequestsRejectedQueueFullTotal int64
 
+    // HTTPRequestsDecompressErrorsTotal
+    // - Content-Encoding 해제에 실패한 요청 수.
+    //   (지원하지 않는 인코딩 → 415, 손상된 압축 스트림 → 400)
+    // - 클라이언트 SDK 의 압축 구현 버그나 잘못된 헤더 설정을 감지하는 용도.
+    HTTPRequestsDecompressErrorsTotal int64
+
+    // HTTPRequestsRejectedDecompressedTooLargeTotal
+    // - 압축 해제 후 크기가 MaxDecompressedBodySize 를 초과해 413 을 반환한 요청 수.
+    // - 압축 상태 크기 초과는 HTTPRequestsRejectedBodyTooLargeTotal 로 센다.
+    // - 갑자기 증가하면 zip bomb 형태의 공격 여부를 확인한다.
+    HTTPRequestsRejectedDecompressedTooLargeTotal int64
+
+    // ======================
+    // 배치 수집(/collect/batch) 지표
+    // ======================
+
+    // HTTPBatchRequestsTotal
+    // - /collect/batch 엔드포인트로 들어온 요청 중 인증 / tenant / rate limit / 서명 검증을 통과한 요청 수 (요청 단위).
+    // - 이 단계에서 거절된 요청은 각 거절 사유 카운터로 센다.
+    // - 한 요청에 여러 이벤트가 담기므로, 이벤트 수는 아래 Events 카운터로 본다.
+    HTTPBatchRequestsTotal int64
+
+    // HTTPBatchEventsAcceptedTotal
+    // - 배치 요청에서 분리된 이벤트 중 EventCh 에 정상 enqueue 된 이벤트 수.
+    HTTPBatchEventsAcceptedTotal int64
+
+    // HTTPBatchEventsRejectedQueueFullTotal
+    // - 배치 처리 도중 EventCh 가 가득 차서 거절된 "나머지(tail)" 이벤트 수.
+    // - 응답 body 의 rejected 값 합계와 같으며, 클라이언트는 이 tail 만 재전송한다.
+    HTTPBatchEventsRejectedQueueFullTotal int64
+
+    // HTTPRequestsAdmissionCanceledTotal
+    // - ADMISSION_WAIT 로 EventCh 빈 자리를 기다리는 중 클라이언트가 연결을 끊은 요청 수.
+    // - queue full 503 카운터에도 함께 포함된다.
+    HTTPRequestsAdmissionCanceledTotal int64
+
+    // HTTPRequestsRejectedTooManyEventsTotal
+    // - 배치 요청 하나에 MaxBatchEvents 를 초과하는 이벤트가 담겨 413 을 반환한 요청 수.
+    HTTPRequestsRejectedTooManyEventsTotal int64
+
+    // HTTPRequestsRejectedInvalidBodyTotal
+    // - 배치 body 가 JSON 배열로 파싱되지 않거나 이벤트가 하나도 없어 400 을 반환한 요청 수.
+    HTTPRequestsRejectedInvalidBodyTotal int64
+
+    // ======================
+    // 인증 지표 (AUTH_COLLECT / AUTH_BATCH)
+    // ======================
+
+    // HTTPRequestsRejectedUnauthorizedTotal
+    // - 인증 헤더(API key 또는 key id / timestamp / 서명)가 없어 401 을 반환한 요청 수.
+    // - 브라우저 beacon 이 인증 route 로 잘못 보내지고 있지 않은지 확인한다.
+    HTTPRequestsRejectedUnauthorizedTotal int64
+
+    // HTTPRequestsRejectedForbiddenTotal
+    // - 알 수 없는 key, 서명 불일치, AUTH_HMAC_WINDOW 를 벗어난 timestamp 로 403 을 반환한 요청 수.
+    // - 급증하면 key 유출 / 시계 오차 / 서명 구현 오류를 의심한다.
+    HTTPRequestsRejectedForbiddenTotal int64
+
+    // ======================
+    // Client 별 rate limit 지표 (RATE_LIMIT_KEY)
+    // ======================
+
+    // HTTPRequestsRejectedRateLimitedTotal
+    // - client(IP / API key / tenant) 별 RATE_LIMIT 초과로 429 를 반환한 요청 수.
+    // - http_requests_rejected_queue_full_total(전체 용량 부족 503)과 구분된다.
+    //   이 값만 증가하면 소수 client 의 과다 요청, queue full 이 증가하면 전체 처리량 부족이다.
+    HTTPRequestsRejectedRateLimitedTotal int64
+
+    // RateLimitKeysEvictedTotal
+    // - RATE_LIMIT_MAX_KEYS 초과로 버려진 key 수.
+    // - 계속 증가하면 버려진 key 는 burst 부터 다시 시작하므로 상한을 늘린다.
+    RateLimitKeysEvictedTotal int64
+
+    // ======================
+    // 클라이언트 IP 출처 지표
+    // ======================
+    // clientIP 가 Event.IP 를 어디에서 얻었는지 요청 단위로 센다.
+    // - RemoteAddr 비율이 갑자기 늘면 TRUSTED_PROXIES 가 실제 프록시(ALB 서브넷 등)와 맞지 않는 것이다.
+    // - XFF/Forwarded 가 0 인데 트래픽이 ALB 를 거친다면 CLIENT_IP_HEADERS 설정을 확인한다.
+
+    ClientIPFromRemoteAddrTotal int64 // RemoteAddr 사용 (신뢰하지 않는 peer)
+    ClientIPFromXFFTotal        int64 // X-Forwarded-For
+    ClientIPFromForwardedTotal  int64 // Forwarded (RFC 7239)
+    ClientIPFromXRealIPTotal    int64 // X-Real-IP
+    ClientIPFromCloudFrontTotal int64 // CloudFront-Viewer-Address
+    ClientIPUnknownTotal        int64 // 어떤 출처에서도 IP 를 얻지 못함 (신뢰 peer 인데 헤더 없음 등, 빈 값 저장)
+
+    // ======================
+    // GeoIP 지표
+    // ======================
+    // GEOIP_DB / GEOIP_ASN_DB 가 설정된 경우에만 증가한다 (IP 가 비어있는 요청은 조회하지 않음).
+    // - miss 비율이 갑자기 늘면 DB 가 오래되었거나 IP 추출(TRUSTED_PROXIES) 설정이 틀려
+    //   내부망 주소로 조회하고 있다는 신호.
+
+    GeoIPLookupHitsTotal   int64 // 하나 이상의 DB 에서 레코드를 찾은 조회 수
+    GeoIPLookupMissesTotal int64 // 어느 DB 에서도 레코드를 찾지 못한 조회 수
+
+    // ======================
+    // User-Agent / 봇 지표
+    // ======================
+    // UA_RULES_FILE 이 설정된 경우에만 증가한다.
+
+    // UABotRequestsTotal
+    // - bots 규칙에 일치한 요청 수 (정책과 무관하게 판정 시점에 센다).
+    UABotRequestsTotal int64
+
+    // UABotRequestsDroppedTotal
+    // - UA_BOT_POLICY=drop 으로 enqueue 하지 않고 204 를 반환한 봇 요청 수.
+    // - 봇 트래픽은 HTTPRequestsAcceptedTotal 에 포함되지 않으므로 이 값으로 규모를 본다.
+    UABotRequestsDroppedTotal int64
+
+    // UABotEventsRoutedTotal
+    // - UA_BOT_POLICY=route 로 BOT_PREFIX 객체에 분리된 이벤트 수 (업로드 시도 기준).
+    UABotEventsRoutedTotal int64
+
+    // ======================
+    // Multi-tenant 지표
+    // ======================
+    // TENANTS_FILE 이 설정된 경우에만 증가한다.
+
+    // TenantUnknownTotal
+    // - tenant 를 판정하지 못했거나 테이블에 없는 tenant 라서 404 를 반환한 요청 수.
+    // - 증가하면 SDK 의 tenant id / API key 설정 오류 또는 TENANTS_FILE 배포 누락을 의심한다.
+    TenantUnknownTotal int64
+
+    // TenantDisabledTotal
+    // - enabled=false 인 tenant 라서 404 를 반환한 요청 수.
+    TenantDisabledTotal int64
+
+    // TenantStats
+    // - tenant 별 카운터. 목록은 InitTenants 로 프로세스 시작 시 TENANTS_FILE 순서로 고정된다.
+    TenantStats []TenantStat
+
+    // ======================
+    // Body 구조화 지표
+    // ======================
+    // BODY_FORMAT=structured 인 경우에만 증가한다 (업로드 worker 의 인코딩 시점 기준).
+    // 구조화하지 못한 이벤트도 body 문자열은 그대로 저장되므로 데이터 유실은 없다.
+
+    BodyParamsTotal             int64 // params 객체를 기록한 GET 이벤트 수
+    BodyParamsInvalidTotal      int64 // percent-encoding 오류 또는 UTF-8 이 아닌 값으로 params 를 생략한 이벤트 수
+    BodyParamsTooManyKeysTotal  int64 // STRUCTURED_MAX_KEYS 초과로 params 를 생략한 이벤트 수
+    BodyJSONTotal               int64 // body_json 으로 JSON 을 그대로 기록한 POST 이벤트 수
+    BodyJSONInvalidTotal        int64 // 유효한 JSON 이 아니어서 body_json 을 생략한 POST 이벤트 수
+    BodyStructuredTooLargeTotal int64 // STRUCTURED_MAX_BYTES 초과로 구조화하지 않은 이벤트 수
+
+    // ======================
+    // JSON Schema 검증 지표
+    // ======================
+    // SCHEMA_MODE=report|enforce 인 경우에만 증가한다.
+
+    // SchemaResults
+    // - schema(이벤트 타입)별 검증 결과 카운터.
+    // - 목록은 InitSchemas 로 프로세스 시작 시 SCHEMA_DIR 의 파일 목록으로 고정된다.
+    SchemaResults []SchemaResult
+
+    // SchemaUnmatchedTotal
+    // - 이벤트 타입을 읽지 못했거나(JSON/QueryString 이 아님, 타입 필드 없음) 해당 schema 가 없는 이벤트 수.
+    // - 검증하지 않고 RAW 에 저장된다. 새 이벤트 타입이 배포되었는데 schema 가 빠진 경우 증가한다.
+    SchemaUnmatchedTotal int64
+
+    // SchemaEventsInvalidStoredTotal
+    // - SCHEMA_MODE=enforce 로 RAW 대신 INVALID_PREFIX 에 저장을 시도한 이벤트 수.
+    SchemaEventsInvalidStoredTotal int64
+
     // ======================
     // S3 레벨 지표
     // ======================
@@ -50,7 +215,8 @@ type Metrics struct {
     // - 최종적으로 S3에 "성공 저장된 이벤트 개수"를 나타낸다.
     // - 단위는 "이벤트 수"이며, "배치 수"가 아니다.
     //   예: 100개 이벤트로 이루어진 배치 1개 업로드 성공 → +100.
-    // - RAW prefix 로 정상 저장된 이벤트만 센다 (RAW_DLQ 는 별도).
+    // - RAW prefix(및 UA_BOT_POLICY=route 의 BOT_PREFIX, SCHEMA_MODE=enforce 의 INVALID_PREFIX)로
+    //   정상 저장된 이벤트만 센다 (RAW_DLQ 는 별도).
     // - 이 값이 계속 증가하는지 / 멈춰있는지로, 수집 파이프라인이 실제로 S3에 데이터를 쌓고 있는지 판단할 수 있다.
     S3EventsStoredTotal int64
 
@@ -64,6 +230,37 @@ type Metrics struct {
     //   - 갑자기 이 값이 튀면 S3/API 실패가 증가했다는 의미.
     S3PutErrorsTotal int64
 
+    // S3 오류 분류 (S3PutErrorsTotal 의 시도를 원인별로 나눈다)
+    // - retryable     : throttling(SlowDown), 5xx, timeout, connection reset → 재시도
+    // - non_retryable : 권한, 버킷 없음, 잘못된 key 등 → 재시도 없이 바로 DLQ. 증가하면 설정을 확인한다.
+    // - unknown       : 위에 해당하지 않는 오류 → 안전하게 재시도
+    // - throttled     : retryable 중 throttling (jitter backoff 적용)
+    S3ErrorsRetryableTotal    int64
+    S3ErrorsNonRetryableTotal int64
+    S3ErrorsUnknownTotal      int64
+    S3ErrorsThrottledTotal    int64
+
+    // S3ConsecutiveFailures
+    // - 마지막 성공 이후 연속으로 실패한 S3 업로드 수 (gauge).
+    // - 재시도(S3_APP_RETRIES)까지 모두 실패한 배치/DLQ 파일 1건을 1 로 센다 (시도 횟수가 아님).
+    // - shutdown 으로 취소된 업로드는 세지 않으며, 성공 1회로 0 이 된다.
+    // - READY_MAX_S3_FAILURES 이상이면 /health/ready 가 503 을 반환한다.
+    S3ConsecutiveFailures int64
+
+    // S3 circuit breaker (S3_BREAKER_*)
+    // - S3BreakerState : 0=closed, 1=open, 2=half_open (gauge). open 이면 /health/ready 가 503 이다.
+    // - rejected 는 open 상태에서 S3 호출 없이 거절한 업로드 수이다 (배치는 DLQ 로 간다).
+    S3BreakerState         int64
+    S3BreakerOpenedTotal   int64 // closed / half-open → open 전환 수
+    S3BreakerRejectedTotal int64 // open 으로 거절한 업로드 수
+
+    // S3 multipart streaming (S3_MULTIPART_THRESHOLD 이상 배치)
+    // - aborts 가 증가하면 multipart 배치가 DLQ 로 가고 있다는 뜻이다 (part 재시도까지 실패).
+    // - part 업로드 실패 시도는 s3_put_errors_total 에도 포함된다.
+    S3MultipartUploadsTotal int64 // 완료된 multipart upload 수
+    S3MultipartPartsTotal   int64 // 업로드된 part 수
+    S3MultipartAbortsTotal  int64 // 실패로 abort 한 multipart upload 수
+
     // ======================
     // DLQ (Dead Letter Queue) 지표
     // ======================
@@ -92,6 +289,7 @@ type Metrics struct {
     // - DLQ 가 용량 제한(DLQMaxSizeBytes)에 걸려서 "새로 들어올 이벤트를 버린" 횟수.
     // - Save 시점에 ensureCapacity 가 실패해서 더 이상 파일을 추가할 수 없을 때 증가한다.
     // - 이 값이 0이 아닌 것은 이미 DLQ 용량 정책을 초과해 **데이터를 영구적으로 잃기 시작했다**는 강한 신호.
+    //   (WAL_ENABLED 인 경우에는 해당 배치가 WAL 에 남아 다음 기동 시 replay 된다)
     // - 비율 예: DLQEventsDroppedTotal / DLQEventsEnqueuedTotal → 
     //   DLQ 자체에서도 감당 못 하고 버리는 비율.
     DLQEventsDroppedTotal int64
@@ -119,30 +317,264 @@ type Metrics struct {
     // - DLQSizeBytes 가 Max 에 근접한 상태에서 DLQEventsDroppedTotal 이 증가하기 시작하면,
     //   DLQ 용량을 늘리거나, DLQ 처리 속도를 높이거나, 근본적인 실패 원인을 줄이는 대응이 필요하다.
     DLQSizeBytes int64
+
+    // ======================
+    // 업로드 worker 지표
+    // ======================
+
+    // UploadWorkerBusy
+    // - uploadLoop worker 별 상태 gauge (1 = 배치 인코딩/업로드 중, 0 = idle).
+    // - 인덱스는 worker 번호이며, 길이는 InitUploadWorkers 로 프로세스 시작 시 고정된다.
+    // - 모든 worker 가 계속 1 이면 UPLOAD_WORKERS 가 부족하거나 S3 PUT 이 느려진 상태이다.
+    //   (이때 collectLoop 는 uploadCh 전송에서 block 되고, EventCh 가 차오르기 시작한다)
+    UploadWorkerBusy []int64
+
+    // ======================
+    // WAL (Write-Ahead Log) 지표
+    // ======================
+
+    // WALAppendErrorsTotal
+    // - WAL 기록(write/fsync)에 실패해 503 으로 거절한 이벤트 수.
+    // - 0 이 아니면 WAL 디스크 용량/IO 문제를 의심해야 한다.
+    WALAppendErrorsTotal int64
+
+    // WALEventsReplayedTotal
+    // - 프로세스 시작 시 이전 WAL 세그먼트에서 복구하여 파이프라인에 다시 넣은 이벤트 수.
+    // - 비정상 종료(OOM-kill, SIGKILL) 직후에만 증가한다.
+    WALEventsReplayedTotal int64
+
+    // WALSegmentsCurrent
+    // - 현재 디스크에 남아있는 WAL 세그먼트 파일 수 (gauge).
+    // - 정상 상태에서는 1~2 개 수준이며, 계속 증가하면 업로드/DLQ 저장이 지연되고 있다는 뜻.
+    WALSegmentsCurrent int64
+
+    // ======================
+    // 배치 flush 원인 지표 (collectLoop)
+    // ======================
+    // flush 된 배치 수를 원인별로 센다. BATCH_SIZE / BATCH_MAX_BYTES / FLUSH_INTERVAL 튜닝용.
+    //   - count 가 대부분이면 BATCH_SIZE 가 작다 (객체 수 증가).
+    //   - bytes 가 대부분이면 body 가 크다. BATCH_SIZE 대신 BATCH_MAX_BYTES 가 객체 크기를 정한다.
+    //   - age 가 대부분이면 트래픽이 적다 (FLUSH_INTERVAL 이 객체 크기를 정한다).
+
+    BatchFlushCountTotal    int64 // 이벤트 수가 BATCH_SIZE 에 도달
+    BatchFlushBytesTotal    int64 // 압축 전 크기가 BATCH_MAX_BYTES 에 도달
+    BatchFlushAgeTotal      int64 // FLUSH_INTERVAL 경과
+    BatchFlushShutdownTotal int64 // 종료 시 남은 배치
+
+    // ======================
+    // 분포(Histogram) 지표
+    // ======================
+    // 평균만으로는 보이지 않는 "꼬리(tail)" 를 보기 위한 지표들.
+    // Prometheus 포맷(/metrics 기본)에서만 노출되며, 레거시 key=value 포맷에는 포함되지 않는다.
+
+    // HTTPRequestBodyBytes
+    // - 수집 요청 body 크기 분포 (Content-Encoding 해제 후, GET 은 RawQuery 길이).
+    // - MAX_BODY_SIZE 를 현실적인 값으로 조정할 때 근거로 사용한다.
+    HTTPRequestBodyBytes *Histogram
+
+    // BatchEvents
+    // - uploadLoop 가 처리한 배치 1개당 이벤트 수 분포.
+    // - 대부분 BATCH_SIZE 에 붙어 있으면 count 기준 flush, 작으면 FlushInterval 기준 flush.
+    BatchEvents *Histogram
+
+    // BatchEncodedBytes
+    // - JSONL + gzip 인코딩 결과(업로드 객체) 크기 분포.
+    // - pool.MaxBufferCap(1MB)을 넘는 비율이 높으면 BufferPool 재사용이 거의 안 되고 있다는 뜻.
+    BatchEncodedBytes *Histogram
+
+    // EncodeDurationSeconds
+    // - 배치 1개의 JSONL + gzip 인코딩 소요 시간 분포 (CPU 병목 판단용).
+    EncodeDurationSeconds *Histogram
+
+    // S3PutDurationSeconds
+    // - S3 PutObject 1회 시도의 소요 시간 분포 (성공/실패 모두 포함).
+    // - S3_TIMEOUT 을 정할 때 p99 를 기준으로 삼는다.
+    S3PutDurationSeconds *Histogram
+
+    // AdmissionWaitSeconds
+    // - ADMISSION_WAIT > 0 일 때, EventCh 가 가득 차서 빈 자리를 기다린 시간 분포 (수락/거절 모두 포함).
+    // - 즉시 들어간 요청은 기록하지 않는다. 대부분 ADMISSION_WAIT 에 붙어 있으면 기다려도 소용없다는 뜻이다.
+    AdmissionWaitSeconds *Histogram
 }
 
 func New() *Metrics {
-	return &Metrics{}
+	return &Metrics{
+		HTTPRequestBodyBytes:  NewHistogram(256, 1<<10, 4<<10, 16<<10, 64<<10, 256<<10, 1<<20),
+		BatchEvents:           NewHistogram(1, 10, 50, 100, 500, 1000, 5000, 10000),
+		BatchEncodedBytes:     NewHistogram(1<<10, 4<<10, 16<<10, 64<<10, 256<<10, 1<<20, 4<<20, 16<<20, 64<<20),
+		EncodeDurationSeconds: NewHistogram(.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5),
+		S3PutDurationSeconds:  NewHistogram(.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10),
+		AdmissionWaitSeconds:  NewHistogram(.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1),
+	}
 }
 
+// InitUploadWorkers 는 worker 별 gauge 슬롯을 n 개 할당한다.
+// HTTP 서버가 시작되기 전(NewManager)에 한 번만 호출되어야 한다.
+func (m *Metrics) InitUploadWorkers(n int) {
+	m.UploadWorkerBusy = make([]int64, n)
+}
+
+// TenantStat 은 tenant 하나의 카운터이다.
+type TenantStat struct {
+	Name        string
+	Accepted    int64 // EventCh 에 enqueue 된 이벤트 수 (배치 요청은 이벤트 단위)
+	RateLimited int64 // rate_limit 초과로 429 를 반환한 요청 수
+}
+
+// InitTenants 는 tenant 별 카운터를 names 순서대로 할당하고 반환한다.
+// HTTP 서버가 시작되기 전(NewHandler)에 한 번만 호출되어야 한다.
+func (m *Metrics) InitTenants(names []string) []TenantStat {
+	m.TenantStats = make([]TenantStat, len(names))
+	for i, name := range names {
+		m.TenantStats[i].Name = name
+	}
+	return m.TenantStats
+}
+
+// SchemaResult 는 schema 하나의 검증 결과 카운터이다.
+type SchemaResult struct {
+	Name    string
+	Valid   int64
+	Invalid int64
+}
+
+// InitSchemas 는 schema 별 카운터를 names 순서대로 할당하고 반환한다.
+// HTTP 서버가 시작되기 전(NewManager)에 한 번만 호출되어야 한다.
+// 반환된 slice 의 원소는 m.SchemaResults 와 같은 메모리를 가리킨다.
+func (m *Metrics) InitSchemas(names []string) []SchemaResult {
+	m.SchemaResults = make([]SchemaResult, len(names))
+	for i, name := range names {
+		m.SchemaResults[i].Name = name
+	}
+	return m.SchemaResults
+}
+
+// 지표 타입 (Prometheus TYPE)
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+)
+
+// scalar 는 단일 int64 지표의 노출 정보이다.
+// String()(레거시 key=value)과 WritePrometheus 가 같은 목록을 공유한다.
+type scalar struct {
+	name string
+	typ  string
+	help string
+	v    *int64
+}
+
+// scalars 는 노출할 int64 지표 목록을 출력 순서대로 반환한다.
+// 새 지표를 추가할 때는 여기에 한 줄만 추가하면 두 포맷에 모두 노출된다.
+func (m *Metrics) scalars() []scalar {
+	return []scalar{
+		{"http_requests_total", typeCounter, "/collect 수신 요청 수", &m.HTTPRequestsTotal},
+		{"http_requests_accepted_total", typeCounter, "EventCh 에 enqueue 된 요청 수", &m.HTTPRequestsAcceptedTotal},
+		{"http_requests_rejected_body_too_large_total", typeCounter, "body 크기 초과로 413 을 반환한 요청 수", &m.HTTPRequestsRejectedBodyTooLargeTotal},
+		{"http_requests_rejected_queue_full_total", typeCounter, "EventCh full 로 503 을 반환한 요청 수", &m.HTTPRequestsRejectedQueueFullTotal},
+
+		{"http_requests_decompress_errors_total", typeCounter, "Content-Encoding 해제 실패 요청 수", &m.HTTPRequestsDecompressErrorsTotal},
+		{"http_requests_rejected_decompressed_too_large_total", typeCounter, "해제 후 크기 초과로 413 을 반환한 요청 수", &m.HTTPRequestsRejectedDecompressedTooLargeTotal},
+
+		{"http_batch_requests_total", typeCounter, "/collect/batch 수신 요청 수 (인증 / admission 통과)", &m.HTTPBatchRequestsTotal},
+		{"http_batch_events_accepted_total", typeCounter, "배치 요청에서 enqueue 된 이벤트 수", &m.HTTPBatchEventsAcceptedTotal},
+		{"http_batch_events_rejected_queue_full_total", typeCounter, "배치 요청에서 EventCh full 로 거절된 tail 이벤트 수", &m.HTTPBatchEventsRejectedQueueFullTotal},
+		{"batch_flush_count_total", typeCounter, "BATCH_SIZE 도달로 flush 된 배치 수", &m.BatchFlushCountTotal},
+		{"batch_flush_bytes_total", typeCounter, "BATCH_MAX_BYTES 도달로 flush 된 배치 수", &m.BatchFlushBytesTotal},
+		{"batch_flush_age_total", typeCounter, "FLUSH_INTERVAL 경과로 flush 된 배치 수", &m.BatchFlushAgeTotal},
+		{"batch_flush_shutdown_total", typeCounter, "종료 시 flush 된 배치 수", &m.BatchFlushShutdownTotal},
+		{"http_requests_admission_canceled_total", typeCounter, "EventCh 대기 중 클라이언트가 연결을 끊은 요청 수", &m.HTTPRequestsAdmissionCanceledTotal},
+		{"http_requests_rejected_too_many_events_total", typeCounter, "MaxBatchEvents 초과로 413 을 반환한 배치 요청 수", &m.HTTPRequestsRejectedTooManyEventsTotal},
+		{"http_requests_rejected_invalid_body_total", typeCounter, "배치 body 형식 오류로 400 을 반환한 요청 수", &m.HTTPRequestsRejectedInvalidBodyTotal},
+		{"http_requests_rejected_unauthorized_total", typeCounter, "인증 정보가 없어 401 을 반환한 요청 수", &m.HTTPRequestsRejectedUnauthorizedTotal},
+		{"http_requests_rejected_forbidden_total", typeCounter, "인증 정보가 틀려 403 을 반환한 요청 수", &m.HTTPRequestsRejectedForbiddenTotal},
+		{"http_requests_rejected_rate_limited_total", typeCounter, "client 별 RATE_LIMIT 초과로 429 를 반환한 요청 수", &m.HTTPRequestsRejectedRateLimitedTotal},
+		{"rate_limit_keys_evicted_total", typeCounter, "RATE_LIMIT_MAX_KEYS 초과로 버려진 rate limit key 수", &m.RateLimitKeysEvictedTotal},
+
+		{"client_ip_source_remote_addr_total", typeCounter, "RemoteAddr 에서 클라이언트 IP 를 얻은 요청 수", &m.ClientIPFromRemoteAddrTotal},
+		{"client_ip_source_x_forwarded_for_total", typeCounter, "X-Forwarded-For 에서 클라이언트 IP 를 얻은 요청 수", &m.ClientIPFromXFFTotal},
+		{"client_ip_source_forwarded_total", typeCounter, "Forwarded 에서 클라이언트 IP 를 얻은 요청 수", &m.ClientIPFromForwardedTotal},
+		{"client_ip_source_x_real_ip_total", typeCounter, "X-Real-IP 에서 클라이언트 IP 를 얻은 요청 수", &m.ClientIPFromXRealIPTotal},
+		{"client_ip_source_cloudfront_total", typeCounter, "CloudFront-Viewer-Address 에서 클라이언트 IP 를 얻은 요청 수", &m.ClientIPFromCloudFrontTotal},
+		{"client_ip_unknown_total", typeCounter, "클라이언트 IP 를 얻지 못한 요청 수", &m.ClientIPUnknownTotal},
+
+		{"geoip_lookup_hits_total", typeCounter, "GeoIP 레코드를 찾은 조회 수", &m.GeoIPLookupHitsTotal},
+		{"geoip_lookup_misses_total", typeCounter, "GeoIP 레코드를 찾지 못한 조회 수", &m.GeoIPLookupMissesTotal},
+
+		{"ua_bot_requests_total", typeCounter, "bots 규칙에 일치한 요청 수", &m.UABotRequestsTotal},
+		{"ua_bot_requests_dropped_total", typeCounter, "UA_BOT_POLICY=drop 으로 버려진 봇 요청 수", &m.UABotRequestsDroppedTotal},
+		{"ua_bot_events_routed_total", typeCounter, "UA_BOT_POLICY=route 로 BOT_PREFIX 에 분리된 이벤트 수", &m.UABotEventsRoutedTotal},
+
+		{"tenant_unknown_total", typeCounter, "알 수 없는 tenant 로 404 를 반환한 요청 수", &m.TenantUnknownTotal},
+		{"tenant_disabled_total", typeCounter, "비활성화된 tenant 로 404 를 반환한 요청 수", &m.TenantDisabledTotal},
+
+		{"body_params_total", typeCounter, "params 객체를 기록한 이벤트 수", &m.BodyParamsTotal},
+		{"body_params_invalid_total", typeCounter, "인코딩 오류로 params 를 생략한 이벤트 수", &m.BodyParamsInvalidTotal},
+		{"body_params_too_many_keys_total", typeCounter, "STRUCTURED_MAX_KEYS 초과로 params 를 생략한 이벤트 수", &m.BodyParamsTooManyKeysTotal},
+		{"body_json_total", typeCounter, "body_json 을 기록한 이벤트 수", &m.BodyJSONTotal},
+		{"body_json_invalid_total", typeCounter, "유효한 JSON 이 아니어서 body_json 을 생략한 이벤트 수", &m.BodyJSONInvalidTotal},
+		{"body_structured_too_large_total", typeCounter, "STRUCTURED_MAX_BYTES 초과로 구조화하지 않은 이벤트 수", &m.BodyStructuredTooLargeTotal},
+
+		{"schema_unmatched_total", typeCounter, "schema 가 없어 검증하지 않은 이벤트 수", &m.SchemaUnmatchedTotal},
+		{"schema_events_invalid_stored_total", typeCounter, "SCHEMA_MODE=enforce 로 INVALID_PREFIX 에 저장을 시도한 이벤트 수", &m.SchemaEventsInvalidStoredTotal},
+
+		{"s3_events_stored_total", typeCounter, "S3 RAW 에 저장된 이벤트 수", &m.S3EventsStoredTotal},
+		{"s3_put_errors_total", typeCounter, "S3 PutObject 실패 시도 수", &m.S3PutErrorsTotal},
+		{"s3_errors_retryable_total", typeCounter, "재시도 가능한 S3 오류 수 (throttling / 5xx / timeout / 연결 오류)", &m.S3ErrorsRetryableTotal},
+		{"s3_errors_non_retryable_total", typeCounter, "재시도하지 않는 S3 오류 수 (권한 / 버킷 없음 / 잘못된 요청)", &m.S3ErrorsNonRetryableTotal},
+		{"s3_errors_unknown_total", typeCounter, "분류되지 않은 S3 오류 수 (재시도함)", &m.S3ErrorsUnknownTotal},
+		{"s3_errors_throttled_total", typeCounter, "throttling(SlowDown 등) S3 오류 수 (retryable 에 포함)", &m.S3ErrorsThrottledTotal},
+		{"s3_multipart_uploads_total", typeCounter, "완료된 S3 multipart upload 수", &m.S3MultipartUploadsTotal},
+		{"s3_multipart_parts_total", typeCounter, "업로드된 S3 multipart part 수", &m.S3MultipartPartsTotal},
+		{"s3_multipart_aborts_total", typeCounter, "실패로 abort 한 S3 multipart upload 수", &m.S3MultipartAbortsTotal},
+		{"s3_breaker_state", typeGauge, "S3 circuit breaker 상태 (0=closed, 1=open, 2=half_open)", &m.S3BreakerState},
+		{"s3_breaker_opened_total", typeCounter, "S3 circuit breaker 가 open 된 횟수", &m.S3BreakerOpenedTotal},
+		{"s3_breaker_rejected_total", typeCounter, "S3 circuit breaker open 으로 S3 호출 없이 거절한 업로드 수", &m.S3BreakerRejectedTotal},
+		{"s3_consecutive_failures", typeGauge, "마지막 성공 이후 재시도까지 모두 실패한 연속 S3 업로드 수", &m.S3ConsecutiveFailures},
+
+		{"dlq_events_enqueued_total", typeCounter, "로컬 DLQ 에 저장된 이벤트 수", &m.DLQEventsEnqueuedTotal},
+		{"dlq_events_reuploaded_total", typeCounter, "DLQ 에서 재업로드된 이벤트 수", &m.DLQEventsReuploadedTotal},
+		{"dlq_events_dropped_total", typeCounter, "DLQ 용량 초과로 버려진 이벤트 수", &m.DLQEventsDroppedTotal},
+		{"dlq_files_expired_total", typeCounter, "TTL/용량 정책으로 삭제된 DLQ 파일 수", &m.DLQFilesExpiredTotal},
+		{"dlq_files_current", typeGauge, "현재 로컬 DLQ 파일 수", &m.DLQFilesCurrent},
+		{"dlq_size_bytes", typeGauge, "현재 로컬 DLQ 전체 크기(bytes)", &m.DLQSizeBytes},
+
+		{"wal_append_errors_total", typeCounter, "WAL 기록 실패로 거절된 이벤트 수", &m.WALAppendErrorsTotal},
+		{"wal_events_replayed_total", typeCounter, "시작 시 WAL 에서 복구된 이벤트 수", &m.WALEventsReplayedTotal},
+		{"wal_segments_current", typeGauge, "현재 디스크의 WAL 세그먼트 수", &m.WALSegmentsCurrent},
+	}
+}
+
+// String 은 레거시 key=value 포맷을 반환한다.
+// (/metrics?format=kv — 기존 운영 스크립트 호환용)
 func (m *Metrics) String() string {
 	var sb strings.Builder
-	sb.Grow(256)
+	sb.Grow(2048)
+
+	for _, s := range m.scalars() {
+		fmt.Fprintf(&sb, "%s=%d\n", s.name, atomic.LoadInt64(s.v))
+	}
 
-	fmt.Fprintf(&sb, "http_requests_total=%d\n", atomic.LoadInt64(&m.HTTPRequestsTotal))
-	fmt.Fprintf(&sb, "http_requests_accepted_total=%d\n", atomic.LoadInt64(&m.HTTPRequestsAcceptedTotal))
-	fmt.Fprintf(&sb, "http_requests_rejected_body_too_large_total=%d\n", atomic.LoadInt64(&m.HTTPRequestsRejectedBodyTooLargeTotal))
-	fmt.Fprintf(&sb, "http_requests_rejected_queue_full_total=%d\n", atomic.LoadInt64(&m.HTTPRequestsRejectedQueueFullTotal))
+	var busy int64
+	for i := range m.UploadWorkerBusy {
+		v := atomic.LoadInt64(&m.UploadWorkerBusy[i])
+		busy += v
+		fmt.Fprintf(&sb, "upload_worker_busy_%d=%d\n", i, v)
+	}
+	fmt.Fprintf(&sb, "upload_workers_busy=%d\n", busy)
+	fmt.Fprintf(&sb, "upload_workers_total=%d\n", len(m.UploadWorkerBusy))
 
-	fmt.Fprintf(&sb, "s3_events_stored_total=%d\n", atomic.LoadInt64(&m.S3EventsStoredTotal))
-	fmt.Fprintf(&sb, "s3_put_errors_total=%d\n", atomic.LoadInt64(&m.S3PutErrorsTotal))
+	for i := range m.TenantStats {
+		t := &m.TenantStats[i]
+		fmt.Fprintf(&sb, "tenant_events_accepted_%s=%d\n", t.Name, atomic.LoadInt64(&t.Accepted))
+		fmt.Fprintf(&sb, "tenant_requests_rate_limited_%s=%d\n", t.Name, atomic.LoadInt64(&t.RateLimited))
+	}
 
-	fmt.Fprintf(&sb, "dlq_events_enqueued_total=%d\n", atomic.LoadInt64(&m.DLQEventsEnqueuedTotal))
-	fmt.Fprintf(&sb, "dlq_events_reuploaded_total=%d\n", atomic.LoadInt64(&m.DLQEventsReuploadedTotal))
-	fmt.Fprintf(&sb, "dlq_events_dropped_total=%d\n", atomic.LoadInt64(&m.DLQEventsDroppedTotal))
-	fmt.Fprintf(&sb, "dlq_files_expired_total=%d\n", atomic.LoadInt64(&m.DLQFilesExpiredTotal))
-	fmt.Fprintf(&sb, "dlq_files_current=%d\n", atomic.LoadInt64(&m.DLQFilesCurrent))
-	fmt.Fprintf(&sb, "dlq_size_bytes=%d\n", atomic.LoadInt64(&m.DLQSizeBytes))
+	for i := range m.SchemaResults {
+		r := &m.SchemaResults[i]
+		fmt.Fprintf(&sb, "schema_valid_%s=%d\n", r.Name, atomic.LoadInt64(&r.Valid))
+		fmt.Fprintf(&sb, "schema_invalid_%s=%d\n", r.Name, atomic.LoadInt64(&r.Invalid))
+	}
 
 	return sb.String()
-}
\ No newline at end of file
+}
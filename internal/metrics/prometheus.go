@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"sync/atomic"
+)
+
+// PrometheusContentType 은 Prometheus text exposition format(0.0.4)의 Content-Type 이다.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// histogramDesc 는 Histogram 지표의 노출 정보이다.
+type histogramDesc struct {
+	name string
+	help string
+	h    *Histogram
+}
+
+func (m *Metrics) histograms() []histogramDesc {
+	return []histogramDesc{
+		{"http_request_body_bytes", "수집 요청 body 크기 분포(bytes, 압축 해제 후)", m.HTTPRequestBodyBytes},
+		{"batch_events", "업로드 배치 1개당 이벤트 수 분포", m.BatchEvents},
+		{"batch_encoded_bytes", "JSONL+gzip 인코딩 결과 크기 분포(bytes)", m.BatchEncodedBytes},
+		{"encode_duration_seconds", "배치 인코딩 소요 시간 분포(seconds)", m.EncodeDurationSeconds},
+		{"s3_put_duration_seconds", "S3 PutObject 1회 시도 소요 시간 분포(seconds)", m.S3PutDurationSeconds},
+		{"admission_wait_seconds", "EventCh full 시 빈 자리를 기다린 시간 분포(seconds)", m.AdmissionWaitSeconds},
+	}
+}
+
+// WritePrometheus 는 모든 지표를 Prometheus text exposition format 으로 출력한다.
+//
+//   - counter/gauge : scalars() 목록 (TYPE 은 목록에 정의된 값)
+//   - worker gauge  : upload_worker_busy{worker="N"}
+//   - tenant 별     : tenant_events_accepted_total{tenant="..."} 등
+//   - schema 결과   : schema_validation_total{schema="...",result="valid|invalid"}
+//   - histogram     : _bucket{le=...} / _sum / _count
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	for _, s := range m.scalars() {
+		writeHeader(bw, s.name, s.typ, s.help)
+		bw.WriteString(s.name)
+		bw.WriteByte(' ')
+		bw.WriteString(strconv.FormatInt(atomic.LoadInt64(s.v), 10))
+		bw.WriteByte('\n')
+	}
+
+	writeHeader(bw, "upload_worker_busy", typeGauge, "업로드 worker 상태 (1=busy, 0=idle)")
+	for i := range m.UploadWorkerBusy {
+		bw.WriteString(`upload_worker_busy{worker="`)
+		bw.WriteString(strconv.Itoa(i))
+		bw.WriteString(`"} `)
+		bw.WriteString(strconv.FormatInt(atomic.LoadInt64(&m.UploadWorkerBusy[i]), 10))
+		bw.WriteByte('\n')
+	}
+
+	if len(m.TenantStats) > 0 {
+		writeHeader(bw, "tenant_events_accepted_total", typeCounter, "tenant 별 enqueue 된 이벤트 수")
+		for i := range m.TenantStats {
+			writeLabeled(bw, "tenant_events_accepted_total", "tenant", m.TenantStats[i].Name, atomic.LoadInt64(&m.TenantStats[i].Accepted))
+		}
+		writeHeader(bw, "tenant_requests_rate_limited_total", typeCounter, "tenant 별 rate_limit 초과로 429 를 반환한 요청 수")
+		for i := range m.TenantStats {
+			writeLabeled(bw, "tenant_requests_rate_limited_total", "tenant", m.TenantStats[i].Name, atomic.LoadInt64(&m.TenantStats[i].RateLimited))
+		}
+	}
+
+	if len(m.SchemaResults) > 0 {
+		writeHeader(bw, "schema_validation_total", typeCounter, "schema 별 JSON Schema 검증 결과 수")
+		for i := range m.SchemaResults {
+			r := &m.SchemaResults[i]
+			writeSchemaResult(bw, r.Name, "valid", atomic.LoadInt64(&r.Valid))
+			writeSchemaResult(bw, r.Name, "invalid", atomic.LoadInt64(&r.Invalid))
+		}
+	}
+
+	for _, d := range m.histograms() {
+		writeHistogram(bw, d)
+	}
+
+	return bw.Flush()
+}
+
+func writeLabeled(bw *bufio.Writer, name, label, value string, v int64) {
+	bw.WriteString(name)
+	bw.WriteByte('{')
+	bw.WriteString(label)
+	bw.WriteString(`="`)
+	bw.WriteString(value)
+	bw.WriteString(`"} `)
+	bw.WriteString(strconv.FormatInt(v, 10))
+	bw.WriteByte('\n')
+}
+
+func writeSchemaResult(bw *bufio.Writer, schema, result string, v int64) {
+	bw.WriteString(`schema_validation_total{schema="`)
+	bw.WriteString(schema)
+	bw.WriteString(`",result="`)
+	bw.WriteString(result)
+	bw.WriteString(`"} `)
+	bw.WriteString(strconv.FormatInt(v, 10))
+	bw.WriteByte('\n')
+}
+
+func writeHeader(bw *bufio.Writer, name, typ, help string) {
+	bw.WriteString("# HELP ")
+	bw.WriteString(name)
+	bw.WriteByte(' ')
+	bw.WriteString(help)
+	bw.WriteString("\n# TYPE ")
+	bw.WriteString(name)
+	bw.WriteByte(' ')
+	bw.WriteString(typ)
+	bw.WriteByte('\n')
+}
+
+func writeHistogram(bw *bufio.Writer, d histogramDesc) {
+	writeHeader(bw, d.name, "histogram", d.help)
+
+	var cum int64
+	for i, le := range d.h.bounds {
+		cum += atomic.LoadInt64(&d.h.counts[i])
+		writeBucket(bw, d.name, strconv.FormatFloat(le, 'g', -1, 64), cum)
+	}
+	cum += atomic.LoadInt64(&d.h.counts[len(d.h.bounds)])
+	writeBucket(bw, d.name, "+Inf", cum)
+
+	bw.WriteString(d.name)
+	bw.WriteString("_sum ")
+	bw.WriteString(strconv.FormatFloat(d.h.Sum(), 'g', -1, 64))
+	bw.WriteByte('\n')
+
+	// _count 는 +Inf bucket 과 일치해야 하므로 누적 값을 그대로 사용한다.
+	bw.WriteString(d.name)
+	bw.WriteString("_count ")
+	bw.WriteString(strconv.FormatInt(cum, 10))
+	bw.WriteByte('\n')
+}
+
+func writeBucket(bw *bufio.Writer, name, le string, v int64) {
+	bw.WriteString(name)
+	bw.WriteString(`_bucket{le="`)
+	bw.WriteString(le)
+	bw.WriteString(`"} `)
+	bw.WriteString(strconv.FormatInt(v, 10))
+	bw.WriteByte('\n')
+}
@@ -1,6 +1,8 @@
 // internal/model/event.go
 package model
 
+import "encoding/json"
+
 // Event
 // ------------------------------------------------------------
 // 클라이언트로부터 수집된 단일 로그 이벤트 구조체.
@@ -11,11 +13,42 @@ package model
 // 쿠키/유저에이전트 등 함께 수집한 부가 정보는
 // downstream ETL 단계에서 분리·정제하게 된다.
 type Event struct {
-	Ts        int64  `json:"ts"`         // Event 수집 시각 (UTC epoch seconds) — timecache.Unix() 기반
-	IP        string `json:"ip"`         // 실 사용자 IP (ALB/XFF/CF 헤더 기반 추출)
-	UserAgent string `json:"user_agent"` // User-Agent 문자열
-	Cookie    string `json:"cookie"`     // Cookie header raw string
-	Body      string `json:"body"`       // GET: RawQuery / POST: Body text
+	Ts               int64  `json:"ts"`                           // Event 수집 시각 (UTC epoch seconds) — timecache.Unix() 기반
+	Tenant           string `json:"tenant,omitempty"`             // tenant id (TENANTS_FILE 사용 시)
+	IP               string `json:"ip"`                           // 실 사용자 IP (ALB/XFF/CF 헤더 기반 추출, IP_ANON_MODE 적용 후)
+	IPMode           string `json:"ip_mode"`                      // IP 표현 방식: raw | truncated | hmac_sha256 | dropped
+	IPSaltID         string `json:"ip_salt_id,omitempty"`         // hmac_sha256 일 때 salt ID (같은 ID 의 hash 끼리만 비교 가능)
+	GeoCountry       string `json:"geo_country,omitempty"`        // GeoIP 국가 ISO 코드 (GEOIP_DB 사용 시, 익명화 전 IP 기준)
+	GeoRegion        string `json:"geo_region,omitempty"`         // GeoIP 첫 번째 subdivision ISO 코드
+	GeoCity          string `json:"geo_city,omitempty"`           // GeoIP 도시 이름 (en)
+	GeoASN           uint32 `json:"geo_asn,omitempty"`            // GeoIP AS 번호 (GEOIP_ASN_DB 사용 시)
+	UserAgent        string `json:"user_agent"`                   // User-Agent 문자열
+	UABrowser        string `json:"ua_browser,omitempty"`         // UA_RULES_FILE browsers 규칙 이름
+	UABrowserVersion string `json:"ua_browser_version,omitempty"` // browsers 규칙 첫 번째 capture group
+	UAOS             string `json:"ua_os,omitempty"`              // os 규칙 이름
+	UAOSVersion      string `json:"ua_os_version,omitempty"`      // os 규칙 첫 번째 capture group
+	UADevice         string `json:"ua_device,omitempty"`          // devices 규칙 이름 | desktop | bot
+	UABot            string `json:"ua_bot,omitempty"`             // 일치한 bots 규칙 이름
+	IsBot            bool   `json:"is_bot,omitempty"`             // bots 규칙에 일치 (UA_BOT_POLICY=route 면 BOT_PREFIX 로 저장)
+	Cookie           string `json:"cookie"`                       // Cookie header raw string
+	Body             string `json:"body"`                         // GET: RawQuery / POST: Body text
+
+	// BODY_FORMAT=structured 일 때만 채워진다.
+	// BodyType 은 handler 가 기록하고, Params / BodyJSON 은 업로드 worker 가 인코딩 직전에 Body 에서 만든다.
+	BodyType string          `json:"body_type,omitempty"` // query (GET) | post (POST, 배치 원소 포함)
+	Params   json.RawMessage `json:"params,omitempty"`    // query: {"key": "value" | ["v1", "v2"]}
+	BodyJSON json.RawMessage `json:"body_json,omitempty"` // post: 유효한 JSON body 를 escape 없이 그대로
+
+	// ValidationError 는 SCHEMA_MODE=enforce 에서 검증에 실패해 INVALID_PREFIX 에 저장되는 이벤트에만 채워진다.
+	ValidationError string `json:"validation_error,omitempty"` // "<schema>: <instance 위치>: <오류>; ..."
+
+	// WALSeg 는 이 이벤트가 기록된 WAL 세그먼트 ID 이다 (0 = WAL 미사용).
+	// 배치가 S3 또는 로컬 DLQ 에 저장되면 WAL.Ack 가 이 값으로 세그먼트를 정리한다.
+	WALSeg uint64 `json:"-"`
+
+	// WALOff 는 세그먼트 안에서 이 이벤트 레코드의 시작 오프셋이다.
+	// Ack 시 세그먼트의 ack 파일에 기록되어, replay 때 이미 저장된 레코드를 건너뛰는 데 쓰인다.
+	WALOff int64 `json:"-"`
 }
 
 // UploadJob
@@ -23,5 +56,6 @@ type Event struct {
 // 이벤트 배치 단위로 업로드할 때 Manager 내부에서 사용되는 구조체.
 // Encoder → gzip JSONL → S3Uploader 로 전달된다.
 type UploadJob struct {
+	Tenant string   // 배치의 tenant id (모든 Events 가 같은 tenant, single-tenant 면 빈 값)
 	Events []*Event // 한 번에 처리되는 N개의 이벤트
 }
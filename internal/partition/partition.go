@@ -0,0 +1,197 @@
+// internal/partition/partition.go
+package partition
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------
+// S3 Key Partitioning
+//
+// S3 object key 는 Athena / Glue 파티션 구조를 그대로 반영해야 한다.
+// 버킷마다 요구하는 구조가 다르므로 (dt/hr, year/month/day/hour, min 추가 등)
+// key 는 placeholder 기반 템플릿으로 정의한다.
+//
+// 지원 placeholder:
+//
+//	{prefix}  RAW/DLQ prefix (예: raw, raw_dlq)
+//	{tenant}  tenant id (multi-tenant 전용, TENANTS_FILE 필요)
+//	{yyyy}    연도 4자리
+//	{mm}      월 2자리
+//	{dd}      일 2자리
+//	{HH}      시 2자리 (24h)
+//	{min}     분 2자리
+//	{file}    파일명 (<unix>_<instance>_<counter>.jsonl.gz) — 필수
+//
+// 예:
+//
+//	{prefix}/dt={yyyy}-{mm}-{dd}/hr={HH}/{file}                  (기본값)
+//	{prefix}/year={yyyy}/month={mm}/day={dd}/hour={HH}/{file}
+//	{prefix}/tenant={tenant}/dt={yyyy}-{mm}-{dd}/{file}
+//
+// 템플릿은 config.Load 시점에 Parse 로 검증되므로,
+// 잘못된 템플릿으로 서버가 기동되는 일은 없다 (fail-fast).
+// ------------------------------------------------------------
+
+// DefaultTemplate 은 기존 "<prefix>/dt=YYYY-MM-DD/hr=HH/<file>" 구조와 동일한 템플릿이다.
+const DefaultTemplate = "{prefix}/dt={yyyy}-{mm}-{dd}/hr={HH}/{file}"
+
+// DefaultTimezone 은 파티션 계산 기본 타임존이다.
+const DefaultTimezone = "Asia/Seoul"
+
+// Parts 는 특정 시각을 파티션 타임존 기준으로 미리 포맷한 값이다.
+// timecache 가 매초 갱신하여 캐싱하므로 hot path 에서 time.Format 을 호출하지 않는다.
+type Parts struct {
+	Year   string // "2006"
+	Month  string // "01"
+	Day    string // "02"
+	Hour   string // "15"
+	Minute string // "04"
+}
+
+// PartsOf 는 t 를 loc 기준으로 포맷한 Parts 를 반환한다.
+func PartsOf(t time.Time, loc *time.Location) Parts {
+	t = t.In(loc)
+	return Parts{
+		Year:   t.Format("2006"),
+		Month:  t.Format("01"),
+		Day:    t.Format("02"),
+		Hour:   t.Format("15"),
+		Minute: t.Format("04"),
+	}
+}
+
+type field int
+
+const (
+	fieldLiteral field = iota
+	fieldPrefix
+	fieldTenant
+	fieldYear
+	fieldMonth
+	fieldDay
+	fieldHour
+	fieldMinute
+	fieldFile
+)
+
+var placeholders = map[string]field{
+	"prefix": fieldPrefix,
+	"tenant": fieldTenant,
+	"yyyy":   fieldYear,
+	"mm":     fieldMonth,
+	"dd":     fieldDay,
+	"HH":     fieldHour,
+	"min":    fieldMinute,
+	"file":   fieldFile,
+}
+
+type segment struct {
+	f   field
+	lit string
+}
+
+// Template 은 Parse 로 컴파일된 key 템플릿이다. 생성 이후 불변이며 동시 사용에 안전하다.
+type Template struct {
+	raw       string
+	segs      []segment
+	hasTenant bool
+}
+
+// Parse 는 key 템플릿 문자열을 검증하고 컴파일한다.
+func Parse(s string) (*Template, error) {
+	if s == "" {
+		return nil, errors.New("empty key template")
+	}
+
+	t := &Template{raw: s}
+	hasFile := false
+
+	for rest := s; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			t.segs = append(t.segs, segment{lit: rest})
+			break
+		}
+		if open > 0 {
+			t.segs = append(t.segs, segment{lit: rest[:open]})
+		}
+
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in key template %q", s)
+		}
+		name := rest[open+1 : open+end]
+
+		f, ok := placeholders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s} in key template %q", name, s)
+		}
+		switch f {
+		case fieldFile:
+			hasFile = true
+		case fieldTenant:
+			t.hasTenant = true
+		}
+		t.segs = append(t.segs, segment{f: f})
+		rest = rest[open+end+1:]
+	}
+
+	if !hasFile {
+		return nil, fmt.Errorf("key template %q must contain {file}", s)
+	}
+	return t, nil
+}
+
+// MustParse 는 Parse 실패 시 panic 한다.
+// config.Load 에서 이미 검증된 템플릿에만 사용한다.
+func MustParse(s string) *Template {
+	t, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// HasTenant 는 템플릿이 {tenant} 를 사용하는지 반환한다.
+func (t *Template) HasTenant() bool {
+	return t.hasTenant
+}
+
+// Render 는 템플릿에 값을 채워 S3 key 를 만든다. tenant 는 single-tenant 이면 빈 값이다.
+func (t *Template) Render(prefix, tenant, file string, p Parts) string {
+	var sb strings.Builder
+	sb.Grow(len(t.raw) + len(prefix) + len(tenant) + len(file))
+
+	for _, seg := range t.segs {
+		switch seg.f {
+		case fieldLiteral:
+			sb.WriteString(seg.lit)
+		case fieldPrefix:
+			sb.WriteString(prefix)
+		case fieldTenant:
+			sb.WriteString(tenant)
+		case fieldYear:
+			sb.WriteString(p.Year)
+		case fieldMonth:
+			sb.WriteString(p.Month)
+		case fieldDay:
+			sb.WriteString(p.Day)
+		case fieldHour:
+			sb.WriteString(p.Hour)
+		case fieldMinute:
+			sb.WriteString(p.Minute)
+		case fieldFile:
+			sb.WriteString(file)
+		}
+	}
+	return sb.String()
+}
+
+// String 은 원본 템플릿 문자열을 반환한다.
+func (t *Template) String() string {
+	return t.raw
+}
@@ -0,0 +1,72 @@
+package partition
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		want string // 오류 메시지에 포함되어야 하는 문자열
+	}{
+		{"", "empty key template"},
+		{"{prefix}/dt={yyyy}-{mm}-{dd}", "must contain {file}"},
+		{"{prefix}/{file", "unclosed placeholder"},
+		{"{prefix}/{region}/{file}", "unknown placeholder {region}"},
+		{"{prefix}/{Tenant}/{file}", "unknown placeholder {Tenant}"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.tmpl)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.tmpl, err, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	p := Parts{Year: "2024", Month: "03", Day: "09", Hour: "07", Minute: "05"}
+	tests := []struct {
+		tmpl   string
+		tenant string
+		want   string
+	}{
+		{DefaultTemplate, "", "raw/dt=2024-03-09/hr=07/f.jsonl.gz"},
+		{"{prefix}/year={yyyy}/month={mm}/day={dd}/hour={HH}/min={min}/{file}", "", "raw/year=2024/month=03/day=09/hour=07/min=05/f.jsonl.gz"},
+		{"{prefix}/tenant={tenant}/dt={yyyy}-{mm}-{dd}/{file}", "shop", "raw/tenant=shop/dt=2024-03-09/f.jsonl.gz"},
+		{"{tenant}/{prefix}/{file}", "shop", "shop/raw/f.jsonl.gz"},
+	}
+	for _, tt := range tests {
+		tpl := MustParse(tt.tmpl)
+		if got := tpl.Render("raw", tt.tenant, "f.jsonl.gz", p); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+		if got, want := tpl.HasTenant(), strings.Contains(tt.tmpl, "{tenant}"); got != want {
+			t.Errorf("HasTenant(%q) = %v, want %v", tt.tmpl, got, want)
+		}
+		if tpl.String() != tt.tmpl {
+			t.Errorf("String() = %q, want %q", tpl.String(), tt.tmpl)
+		}
+	}
+}
+
+// 파티션 날짜는 PARTITION_TZ 기준이다. UTC 로는 다음 날인 시각이 America/Los_Angeles 에서는 전날 파티션이 된다.
+func TestPartsOfDateBoundary(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+
+	ts := time.Date(2024, 1, 1, 3, 30, 0, 0, time.UTC) // LA: 2023-12-31 19:30 (PST, UTC-8)
+	got := PartsOf(ts, la)
+	want := Parts{Year: "2023", Month: "12", Day: "31", Hour: "19", Minute: "30"}
+	if got != want {
+		t.Fatalf("PartsOf(%v, LA) = %+v, want %+v", ts, got, want)
+	}
+
+	key := MustParse(DefaultTemplate).Render("raw", "", "f.jsonl.gz", got)
+	if key != "raw/dt=2023-12-31/hr=19/f.jsonl.gz" {
+		t.Fatalf("key = %q", key)
+	}
+}
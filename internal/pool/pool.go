@@ -7,6 +7,7 @@ import (
 	"estat-ingest/internal/model"
 
 	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
 )
 
 // ---------------------------------------------------------------
@@ -56,6 +57,28 @@ var (
 			return w
 		},
 	}
+
+	// GzipReaderPool:
+	//   - Content-Encoding: gzip 요청 body 해제용 gzip.Reader 재사용
+	//   - zero value 를 Reset(r) 으로 초기화해서 사용한다.
+	GzipReaderPool = sync.Pool{
+		New: func() any { return new(gzip.Reader) },
+	}
+
+	// ZstdReaderPool:
+	//   - Content-Encoding: zstd 요청 body 해제용 zstd.Decoder 재사용
+	//   - Concurrency=1 → 내부 goroutine 없이 동기 디코딩 (풀 보관 시 누수 없음)
+	//   - Lowmem + MaxWindow 8MB: 0.5GB 메모리 제한 환경에서 디코더 메모리 상한 고정
+	ZstdReaderPool = sync.Pool{
+		New: func() any {
+			d, _ := zstd.NewReader(nil,
+				zstd.WithDecoderConcurrency(1),
+				zstd.WithDecoderLowmem(true),
+				zstd.WithDecoderMaxWindow(8<<20),
+			)
+			return d
+		},
+	}
 )
 
 // Pool에 되돌려줄 최대 gzip 버퍼 용량
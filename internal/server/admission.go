@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"estat-ingest/internal/model"
+)
+
+// ------------------------------------------------------------
+// EventCh admission (ADMISSION_WAIT)
+//
+// 기본(ADMISSION_WAIT=0)은 non-blocking select 로, EventCh 가 가득 차면 즉시 503 이다.
+// ADMISSION_WAIT > 0 이면 collectLoop 가 자리를 비울 때까지 최대 그 시간만큼 기다린 뒤 거절한다.
+//
+//   - 대기 시간은 요청 단위 예산이다. 배치 요청의 이벤트들은 같은 예산을 나눠 쓴다.
+//   - 클라이언트가 연결을 끊으면(request context 취소) 즉시 대기를 멈춘다.
+//   - 실제로 기다린 시간만 admission_wait_seconds 에 기록한다 (즉시 들어간 경우 제외).
+//
+// 기다리는 동안 요청 goroutine 과 body 메모리가 유지되므로,
+// ADMISSION_WAIT 는 ALB idle timeout / 클라이언트 timeout 보다 충분히 짧게 둔다.
+// ------------------------------------------------------------
+
+// admission 은 요청 하나의 EventCh 대기 상태이다.
+type admission struct {
+	h     *Handler
+	ctx   context.Context
+	timer *time.Timer // 처음 기다릴 때 만든다 (대부분의 요청은 만들지 않음)
+	spent bool        // 대기 예산을 모두 썼거나 취소됨
+}
+
+func (h *Handler) newAdmission(ctx context.Context) *admission {
+	return &admission{h: h, ctx: ctx}
+}
+
+// enqueue 는 ev 를 EventCh 에 넣는다. 빈 자리가 없으면 남은 대기 예산만큼 기다린다.
+func (a *admission) enqueue(ev *model.Event) bool {
+	select {
+	case a.h.worker.EventCh <- ev:
+		return true
+	default:
+	}
+
+	wait := a.h.cfg.AdmissionWait
+	if wait <= 0 || a.spent {
+		return false
+	}
+	if a.timer == nil {
+		a.timer = time.NewTimer(wait)
+	}
+
+	start := time.Now()
+	defer func() { a.h.metrics.AdmissionWaitSeconds.Observe(time.Since(start).Seconds()) }()
+
+	select {
+	case a.h.worker.EventCh <- ev:
+		return true
+	case <-a.timer.C:
+		a.spent = true
+		return false
+	case <-a.ctx.Done():
+		a.spent = true
+		atomic.AddInt64(&a.h.metrics.HTTPRequestsAdmissionCanceledTotal, 1)
+		return false
+	}
+}
+
+// stop 은 대기용 timer 를 정리한다.
+func (a *admission) stop() {
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+}
+
+// setQueueFullRetryAfter 는 EventCh full 503 응답에 Retry-After(초, 올림)를 붙인다.
+func (h *Handler) setQueueFullRetryAfter(w http.ResponseWriter) {
+	secs := max(int64(math.Ceil(h.cfg.QueueFullRetryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
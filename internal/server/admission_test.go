@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+	"estat-ingest/internal/worker"
+)
+
+func newAdmissionTestHandler(wait time.Duration) *Handler {
+	cfg := config.Config{MaxBodySize: 1024, AdmissionWait: wait, QueueFullRetryAfter: 1500 * time.Millisecond}
+	w := &worker.Manager{EventCh: make(chan *model.Event, 1)}
+	return &Handler{cfg: cfg, metrics: metrics.New(), worker: w, ips: newIPResolver(cfg), anon: newIPAnonymizer(cfg)}
+}
+
+func TestAdmissionImmediateReject(t *testing.T) {
+	h := newAdmissionTestHandler(0)
+	h.worker.EventCh <- &model.Event{}
+
+	rec := httptest.NewRecorder()
+	h.HandleCollect(rec, httptest.NewRequest(http.MethodGet, "/collect?e=pv", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "2" {
+		t.Fatalf("status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if h.metrics.AdmissionWaitSeconds.Count() != 0 {
+		t.Fatal("wait recorded without ADMISSION_WAIT")
+	}
+}
+
+// collectLoop 가 대기 중에 자리를 비우면 수락된다.
+func TestAdmissionWaitsForSpace(t *testing.T) {
+	h := newAdmissionTestHandler(time.Second)
+	h.worker.EventCh <- &model.Event{}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-h.worker.EventCh
+	}()
+
+	rec := httptest.NewRecorder()
+	h.HandleCollect(rec, httptest.NewRequest(http.MethodGet, "/collect?e=pv", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if n := h.metrics.AdmissionWaitSeconds.Count(); n != 1 {
+		t.Fatalf("wait observations = %d", n)
+	}
+}
+
+// 배치는 요청 단위 대기 예산을 나눠 쓴다: 예산이 끝나면 tail 은 더 기다리지 않는다.
+func TestAdmissionBatchBudget(t *testing.T) {
+	h := newAdmissionTestHandler(30 * time.Millisecond)
+	h.cfg.MaxBatchEvents = 10
+
+	r := httptest.NewRequest(http.MethodPost, "/collect/batch", strings.NewReader("{\"a\":1}\n{\"a\":2}\n{\"a\":3}\n"))
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.HandleCollectBatch(rec, r)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"accepted":1,"rejected":2}` {
+		t.Fatalf("status %d body %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") != "" {
+		t.Fatal("Retry-After set on partial success")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("batch waited %v", d)
+	}
+	if n := h.metrics.AdmissionWaitSeconds.Count(); n != 1 {
+		t.Fatalf("wait observations = %d, want 1", n)
+	}
+}
+
+func TestAdmissionCanceled(t *testing.T) {
+	h := newAdmissionTestHandler(time.Minute)
+	h.worker.EventCh <- &model.Event{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	rec := httptest.NewRecorder()
+	h.HandleCollect(rec, httptest.NewRequest(http.MethodGet, "/collect?e=pv", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if h.metrics.HTTPRequestsAdmissionCanceledTotal != 1 || h.metrics.HTTPRequestsRejectedQueueFullTotal != 1 {
+		t.Fatalf("canceled=%d queue_full=%d", h.metrics.HTTPRequestsAdmissionCanceledTotal, h.metrics.HTTPRequestsRejectedQueueFullTotal)
+	}
+}
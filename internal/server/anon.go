@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"estat-ingest/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ------------------------------------------------------------
+// IP Anonymization
+//
+// Event.IP 는 S3 에 그대로 저장되므로 개인정보(GDPR/PIPA) 관점에서
+// 큐에 넣기 전(WAL 기록 전) 단계에서 익명화한다. 원본 IP 는 디스크에 남지 않는다.
+//
+// 모드 (IP_ANON_MODE):
+//
+//	none     : 원본 그대로                              → ip_mode "raw"
+//	truncate : IPv4 /24, IPv6 /48 로 절삭 (203.0.113.0) → ip_mode "truncated"
+//	hash     : HMAC-SHA256(salt, ip) hex                → ip_mode "hmac_sha256"
+//	drop     : 빈 문자열                                → ip_mode "dropped"
+//
+// 어떤 표현인지 downstream 이 알 수 있도록 Event.IPMode 에 ip_mode 값을 함께 기록한다.
+//
+// hash 모드의 salt 는 IP_HASH_SALT_FILE 에서 읽으며,
+// IP_HASH_SALT_RELOAD 주기로 파일 내용을 다시 읽어 바뀌었으면 교체한다 (salt rotation).
+// salt 가 바뀌면 같은 IP 의 hash 도 바뀌므로, salt 에서 유도한 짧은 ID 를
+// Event.IPSaltID(ip_salt_id)에 함께 기록한다. ID 가 같은 hash 끼리만 비교할 수 있다.
+// ------------------------------------------------------------
+
+var errEmptySalt = errors.New("empty IP hash salt")
+
+// saltIDLabel 은 salt ID 유도용 HMAC 메시지이다 (hash 값과 같은 공간이 되지 않도록 IP 가 아닌 고정 문자열).
+const saltIDLabel = "estat-ingest/ip-salt-id"
+
+// Event.IPMode 에 기록되는 값
+const (
+	ipModeRaw       = "raw"
+	ipModeTruncated = "truncated"
+	ipModeHMAC      = "hmac_sha256"
+	ipModeDropped   = "dropped"
+)
+
+// ipSalt 는 hash 모드의 salt 와 그 ID 이다. 교체 시 둘을 함께 바꾸기 위해 하나의 값으로 저장한다.
+type ipSalt struct {
+	key []byte
+	id  string // HMAC-SHA256(key, saltIDLabel) 앞 8자리 hex
+}
+
+func newIPSalt(key []byte) *ipSalt {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(saltIDLabel))
+	return &ipSalt{key: key, id: hex.EncodeToString(mac.Sum(nil))[:8]}
+}
+
+// anonIP 는 익명화된 IP 와 그 표현 정보이다 (Event.IP / IPMode / IPSaltID).
+type anonIP struct {
+	ip     string
+	mode   string
+	saltID string // hash 모드에서만 설정
+}
+
+// ipAnonymizer 는 IP_ANON_MODE 에 따라 IP 를 변환한다.
+type ipAnonymizer struct {
+	cfgMode string // config 값 (none/truncate/hash/drop)
+	outMode string // Event.IPMode 값
+
+	salt atomic.Pointer[ipSalt] // hash 모드에서만 사용
+}
+
+// newIPAnonymizer 는 ipAnonymizer 를 만들고, hash 모드면 salt 를 읽은 뒤 reload goroutine 을 시작한다.
+// salt 파일은 config.Load 에서 이미 검증되었다.
+func newIPAnonymizer(cfg config.Config) *ipAnonymizer {
+	a := &ipAnonymizer{cfgMode: cfg.IPAnonMode}
+
+	switch cfg.IPAnonMode {
+	case "truncate":
+		a.outMode = ipModeTruncated
+	case "hash":
+		a.outMode = ipModeHMAC
+		salt, err := readSalt(cfg.IPHashSaltFile)
+		if err != nil {
+			log.Fatal().Err(err).Str("path", cfg.IPHashSaltFile).Msg("failed to read IP hash salt")
+		}
+		a.salt.Store(newIPSalt(salt))
+		log.Info().
+			Str("ip_salt_id", a.salt.Load().id).
+			Msg("IP hash salt loaded")
+		go a.reloadLoop(cfg.IPHashSaltFile, cfg.IPHashSaltReload)
+	case "drop":
+		a.outMode = ipModeDropped
+	default:
+		a.outMode = ipModeRaw
+	}
+
+	return a
+}
+
+// Apply 는 ip 를 현재 모드에 따라 변환한다. 빈 값은 그대로 빈 값이다.
+// hash 모드에서는 hash 에 사용한 salt 의 ID 를 함께 반환한다 (교체 중에도 둘이 어긋나지 않는다).
+func (a *ipAnonymizer) Apply(ip string) anonIP {
+	out := anonIP{mode: a.outMode}
+	if ip == "" {
+		return out
+	}
+
+	switch a.cfgMode {
+	case "truncate":
+		out.ip = truncateIP(ip)
+	case "hash":
+		salt := a.salt.Load()
+		mac := hmac.New(sha256.New, salt.key)
+		mac.Write([]byte(ip))
+		out.ip = hex.EncodeToString(mac.Sum(nil))
+		out.saltID = salt.id
+	case "drop":
+	default:
+		out.ip = ip
+	}
+	return out
+}
+
+// truncateIP 는 IPv4 는 /24, IPv6 는 /48 로 하위 비트를 0 으로 만든다.
+// 파싱할 수 없는 값은 원본을 남기지 않도록 빈 문자열을 반환한다.
+func truncateIP(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// reloadLoop 는 interval 마다 salt 파일을 다시 읽어 내용이 바뀌었으면 교체한다.
+// 읽기 실패 시 기존 salt 를 유지한다 (익명화가 멈추거나 원본이 저장되는 일은 없다).
+func (a *ipAnonymizer) reloadLoop(path string, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for range t.C {
+		salt, err := readSalt(path)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("path", path).
+				Msg("IP hash salt reload failed, keeping previous salt")
+			continue
+		}
+		if bytes.Equal(salt, a.salt.Load().key) {
+			continue
+		}
+
+		next := newIPSalt(salt)
+		a.salt.Store(next)
+		log.Info().
+			Str("path", path).
+			Str("ip_salt_id", next.id).
+			Msg("IP hash salt rotated")
+	}
+}
+
+// readSalt 는 salt 파일을 읽고 앞뒤 공백(개행)을 제거한다.
+func readSalt(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, errEmptySalt
+	}
+	return b, nil
+}
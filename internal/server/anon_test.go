@@ -0,0 +1,54 @@
+package server
+
+import "testing"
+
+func TestIPAnonymizerApply(t *testing.T) {
+	tests := []struct {
+		cfgMode string
+		in      string
+		want    anonIP
+	}{
+		{cfgMode: "none", in: "203.0.113.77", want: anonIP{ip: "203.0.113.77", mode: ipModeRaw}},
+		{cfgMode: "truncate", in: "203.0.113.77", want: anonIP{ip: "203.0.113.0", mode: ipModeTruncated}},
+		{cfgMode: "truncate", in: "2001:db8:cafe:1::17", want: anonIP{ip: "2001:db8:cafe::", mode: ipModeTruncated}},
+		{cfgMode: "truncate", in: "not-an-ip", want: anonIP{ip: "", mode: ipModeTruncated}},
+		{cfgMode: "drop", in: "203.0.113.77", want: anonIP{ip: "", mode: ipModeDropped}},
+		{cfgMode: "none", in: "", want: anonIP{ip: "", mode: ipModeRaw}},
+	}
+
+	for _, tt := range tests {
+		a := &ipAnonymizer{cfgMode: tt.cfgMode}
+		switch tt.cfgMode {
+		case "truncate":
+			a.outMode = ipModeTruncated
+		case "drop":
+			a.outMode = ipModeDropped
+		default:
+			a.outMode = ipModeRaw
+		}
+
+		if got := a.Apply(tt.in); got != tt.want {
+			t.Errorf("%s(%q) = %+v, want %+v", tt.cfgMode, tt.in, got, tt.want)
+		}
+	}
+}
+
+// salt 가 바뀌면 hash 와 salt ID 가 함께 바뀐다.
+func TestIPAnonymizerHashSaltID(t *testing.T) {
+	a := &ipAnonymizer{cfgMode: "hash", outMode: ipModeHMAC}
+	a.salt.Store(newIPSalt([]byte("salt-1")))
+
+	first := a.Apply("203.0.113.77")
+	if first.mode != ipModeHMAC || len(first.ip) != 64 || len(first.saltID) != 8 {
+		t.Fatalf("Apply = %+v", first)
+	}
+	if again := a.Apply("203.0.113.77"); again != first {
+		t.Fatalf("hash not stable: %+v vs %+v", again, first)
+	}
+
+	a.salt.Store(newIPSalt([]byte("salt-2")))
+	rotated := a.Apply("203.0.113.77")
+	if rotated.ip == first.ip || rotated.saltID == first.saltID {
+		t.Fatalf("rotation did not change hash/salt id: %+v vs %+v", rotated, first)
+	}
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"estat-ingest/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ------------------------------------------------------------
+// Server-to-server 인증 (AUTH_COLLECT / AUTH_BATCH)
+//
+// AUTH_KEYS_FILE 은 한 줄에 "<key id> <secret>" 하나씩 적는다 (빈 줄, # 주석 허용).
+//
+//	api_key : API_KEY_HEADER 값이 secret 중 하나와 같아야 한다.
+//	hmac    : X-Key-Id, X-Timestamp(unix 초), X-Signature 헤더를 보낸다.
+//	          X-Signature = hex(HMAC-SHA256(secret, "<X-Timestamp>.<body>"))
+//	          body 는 Content-Encoding 해제 후의 평문, GET 은 QueryString 이다.
+//	          timestamp 가 현재 시각과 AUTH_HMAC_WINDOW 이상 차이나면 거절한다.
+//
+// 응답:
+//   - 인증 헤더 없음                          → 401 (http_requests_rejected_unauthorized_total)
+//   - 알 수 없는 key / 서명 불일치 / 오래된 요청 → 403 (http_requests_rejected_forbidden_total)
+//
+// api_key 와 hmac 헤더 검사는 body 를 읽기 전에, hmac 서명 검증은 body 를 읽은 뒤에 한다.
+// 브라우저 beacon 은 secret 을 숨길 수 없으므로 BEACON_PATH 로 인증 없이 보낸다.
+// key 파일은 시작 시 한 번만 읽는다 (변경 시 재배포).
+// ------------------------------------------------------------
+
+// authenticator 는 AUTH_KEYS_FILE 의 key 목록이다. AUTH_KEYS_FILE 미설정 시 nil 이다.
+type authenticator struct {
+	secrets map[string][]byte   // key id → secret (hmac)
+	keys    map[[32]byte]string // sha256(secret) → key id (api_key)
+
+	apiKeyHeader string
+	keyIDHeader  string
+	tsHeader     string
+	sigHeader    string
+	window       time.Duration
+
+	now func() time.Time
+}
+
+// pendingSignature 는 body 를 읽은 뒤 검증할 hmac 서명이다.
+type pendingSignature struct {
+	secret []byte
+	ts     string
+	sig    []byte
+}
+
+// newAuthenticator 는 AUTH_KEYS_FILE 을 읽는다. 형식 오류는 즉시 종료한다.
+func newAuthenticator(cfg config.Config) *authenticator {
+	if cfg.AuthKeysFile == "" {
+		return nil
+	}
+
+	secrets, err := loadAuthKeys(cfg.AuthKeysFile)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", cfg.AuthKeysFile).Msg("failed to load auth keys")
+	}
+
+	a := &authenticator{
+		secrets:      secrets,
+		keys:         make(map[[32]byte]string, len(secrets)),
+		apiKeyHeader: cfg.APIKeyHeader,
+		keyIDHeader:  cfg.AuthKeyIDHeader,
+		tsHeader:     cfg.AuthTimestampHeader,
+		sigHeader:    cfg.AuthSignatureHeader,
+		window:       cfg.AuthHMACWindow,
+		now:          time.Now,
+	}
+	for id, secret := range secrets {
+		a.keys[sha256.Sum256(secret)] = id
+	}
+
+	log.Info().
+		Str("path", cfg.AuthKeysFile).
+		Int("keys", len(secrets)).
+		Str("collect", cfg.AuthCollect).
+		Str("batch", cfg.AuthBatch).
+		Msg("auth keys loaded")
+	return a
+}
+
+// loadAuthKeys 는 "<key id> <secret>" 형식의 파일을 읽는다.
+func loadAuthKeys(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	secrets := make(map[string][]byte)
+	seen := make(map[string]string) // secret → key id
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<key id> <secret>\"", n)
+		}
+		id, secret := fields[0], fields[1]
+		if _, ok := secrets[id]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key id %q", n, id)
+		}
+		if other, ok := seen[secret]; ok {
+			return nil, fmt.Errorf("line %d: key %q reuses the secret of %q", n, id, other)
+		}
+		secrets[id] = []byte(secret)
+		seen[secret] = id
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("no keys")
+	}
+	return secrets, nil
+}
+
+// authorize 는 route 의 인증 방식(mode)으로 요청 헤더를 검사한다.
+// 거절한 경우 응답을 쓰고 false 를 반환한다.
+// hmac 이면 body 를 읽은 뒤 verifySignature 로 검증할 서명을 함께 반환한다.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, mode string) (*pendingSignature, bool) {
+	a := h.auth
+	switch mode {
+	case "api_key":
+		key := r.Header.Get(a.apiKeyHeader)
+		if key == "" {
+			h.rejectAuth(w, http.StatusUnauthorized)
+			return nil, false
+		}
+		// secret 을 직접 비교하지 않고 hash 로 찾는다 (문자열 비교 시간으로 secret 이 새지 않도록).
+		if _, ok := a.keys[sha256.Sum256([]byte(key))]; !ok {
+			h.rejectAuth(w, http.StatusForbidden)
+			return nil, false
+		}
+		return nil, true
+
+	case "hmac":
+		id := r.Header.Get(a.keyIDHeader)
+		ts := r.Header.Get(a.tsHeader)
+		sigHex := r.Header.Get(a.sigHeader)
+		if id == "" || ts == "" || sigHex == "" {
+			h.rejectAuth(w, http.StatusUnauthorized)
+			return nil, false
+		}
+		secret, ok := a.secrets[id]
+		sig, err := hex.DecodeString(sigHex)
+		if !ok || err != nil || !a.fresh(ts) {
+			h.rejectAuth(w, http.StatusForbidden)
+			return nil, false
+		}
+		return &pendingSignature{secret: secret, ts: ts, sig: sig}, true
+
+	default: // none
+		return nil, true
+	}
+}
+
+// fresh 는 timestamp(unix 초)가 현재 시각 ± window 안인지 확인한다.
+func (a *authenticator) fresh(ts string) bool {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	d := a.now().Sub(time.Unix(sec, 0))
+	return d <= a.window && d >= -a.window
+}
+
+// verifySignature 는 body 의 hmac 서명을 검증한다. 서명이 없는 route(p == nil)는 항상 통과한다.
+func (h *Handler) verifySignature(w http.ResponseWriter, p *pendingSignature, body string) bool {
+	if p == nil {
+		return true
+	}
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(p.ts))
+	mac.Write([]byte{'.'})
+	mac.Write([]byte(body))
+	if !hmac.Equal(mac.Sum(nil), p.sig) {
+		h.rejectAuth(w, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// rejectAuth 는 401 / 403 으로 응답하고 카운터를 증가시킨다.
+func (h *Handler) rejectAuth(w http.ResponseWriter, status int) {
+	if status == http.StatusUnauthorized {
+		atomic.AddInt64(&h.metrics.HTTPRequestsRejectedUnauthorizedTotal, 1)
+	} else {
+		atomic.AddInt64(&h.metrics.HTTPRequestsRejectedForbiddenTotal, 1)
+	}
+	w.WriteHeader(status)
+}
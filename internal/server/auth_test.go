@@ -0,0 +1,173 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+	"estat-ingest/internal/worker"
+)
+
+func newAuthTestHandler(t *testing.T, collect, batch string) *Handler {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte("# server-to-server keys\nbackend s3cr3t\n\netl other-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{
+		MaxBodySize:         1024,
+		MaxBatchEvents:      10,
+		AuthKeysFile:        path,
+		AuthCollect:         collect,
+		AuthBatch:           batch,
+		APIKeyHeader:        "X-API-Key",
+		AuthKeyIDHeader:     "X-Key-Id",
+		AuthTimestampHeader: "X-Timestamp",
+		AuthSignatureHeader: "X-Signature",
+		AuthHMACWindow:      5 * time.Minute,
+	}
+	w := &worker.Manager{EventCh: make(chan *model.Event, 10)}
+	h := &Handler{cfg: cfg, metrics: metrics.New(), worker: w, ips: newIPResolver(cfg), anon: newIPAnonymizer(cfg), auth: newAuthenticator(cfg)}
+	h.auth.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return h
+}
+
+func sign(secret, ts, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "." + body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHandleCollectHMAC(t *testing.T) {
+	h := newAuthTestHandler(t, "hmac", "none")
+	now := strconv.FormatInt(1700000000, 10)
+	stale := strconv.FormatInt(1700000000-301, 10)
+
+	tests := []struct {
+		name        string
+		id, ts, sig string
+		body        string
+		want        int
+	}{
+		{"valid", "backend", now, sign("s3cr3t", now, "a=1"), "a=1", http.StatusOK},
+		{"missing headers", "", "", "", "a=1", http.StatusUnauthorized},
+		{"missing signature", "backend", now, "", "a=1", http.StatusUnauthorized},
+		{"unknown key", "nobody", now, sign("s3cr3t", now, "a=1"), "a=1", http.StatusForbidden},
+		{"wrong secret", "etl", now, sign("s3cr3t", now, "a=1"), "a=1", http.StatusForbidden},
+		{"tampered body", "backend", now, sign("s3cr3t", now, "a=1"), "a=2", http.StatusForbidden},
+		{"stale timestamp", "backend", stale, sign("s3cr3t", stale, "a=1"), "a=1", http.StatusForbidden},
+		{"bad hex", "backend", now, "zz", "a=1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/collect", strings.NewReader(tt.body))
+		for k, v := range map[string]string{"X-Key-Id": tt.id, "X-Timestamp": tt.ts, "X-Signature": tt.sig} {
+			if v != "" {
+				r.Header.Set(k, v)
+			}
+		}
+		rec := httptest.NewRecorder()
+		h.HandleCollect(rec, r)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	if h.metrics.HTTPRequestsRejectedUnauthorizedTotal != 2 || h.metrics.HTTPRequestsRejectedForbiddenTotal != 5 {
+		t.Fatalf("unauthorized=%d forbidden=%d", h.metrics.HTTPRequestsRejectedUnauthorizedTotal, h.metrics.HTTPRequestsRejectedForbiddenTotal)
+	}
+
+	// beacon 은 인증 없이 수집한다
+	rec := httptest.NewRecorder()
+	h.HandleBeacon(rec, httptest.NewRequest(http.MethodGet, "/beacon?e=pv", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("beacon: status %d", rec.Code)
+	}
+}
+
+func TestHandleCollectBatchAPIKey(t *testing.T) {
+	h := newAuthTestHandler(t, "none", "api_key")
+
+	do := func(key string) int {
+		r := httptest.NewRequest(http.MethodPost, "/collect/batch", strings.NewReader("{\"e\":1}\n{\"e\":2}\n"))
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.HandleCollectBatch(rec, r)
+		return rec.Code
+	}
+
+	if got := do("other-secret"); got != http.StatusOK {
+		t.Fatalf("valid key: status %d", got)
+	}
+	if got := do(""); got != http.StatusUnauthorized {
+		t.Fatalf("no key: status %d", got)
+	}
+	if got := do("backend"); got != http.StatusForbidden { // key id 는 secret 이 아니다
+		t.Fatalf("wrong key: status %d", got)
+	}
+
+	// 인증에 실패한 요청은 http_batch_requests_total 에 세지 않는다
+	if n := h.metrics.HTTPBatchRequestsTotal; n != 1 {
+		t.Fatalf("HTTPBatchRequestsTotal = %d, want 1", n)
+	}
+
+	// /collect 는 AUTH_COLLECT=none
+	rec := httptest.NewRecorder()
+	h.HandleCollect(rec, httptest.NewRequest(http.MethodGet, "/collect?e=pv", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("collect: status %d", rec.Code)
+	}
+}
+
+func TestLoadAuthKeysInvalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"empty":        "# nothing\n",
+		"one field":    "backend\n",
+		"duplicate id": "a x\na y\n",
+		"shared":       "a x\nb x\n",
+	} {
+		path := filepath.Join(t.TempDir(), "keys")
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadAuthKeys(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// BEACON_PATH 를 설정하지 않으면 beacon 경로는 등록되지 않는다 (인증 우회 경로가 생기지 않는다).
+func TestRoutesBeaconOptIn(t *testing.T) {
+	h := newAuthTestHandler(t, "api_key", "api_key")
+	mux := h.Routes()
+
+	for path, want := range map[string]int{
+		"/beacon?e=pv":  http.StatusNotFound,
+		"/collect?e=pv": http.StatusUnauthorized,
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("%s: status %d, want %d", path, rec.Code, want)
+		}
+	}
+
+	h.cfg.BeaconPath = "/beacon"
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/beacon?e=pv", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("configured beacon: status %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,300 @@
+package server
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"estat-ingest/internal/model"
+	"estat-ingest/internal/pool"
+
+	json "github.com/goccy/go-json"
+)
+
+// ------------------------------------------------------------
+// Batch Ingestion (/collect/batch)
+//
+// 모바일 SDK 처럼 디바이스에서 이벤트를 모아 보내는 클라이언트를 위해
+// 한 번의 POST 에 여러 이벤트를 담을 수 있는 엔드포인트.
+//
+// 지원 포맷:
+//   - application/x-ndjson : 한 줄당 이벤트 1개
+//   - application/json     : JSON 배열, 원소 1개당 이벤트 1개
+//
+// 분리된 이벤트들은 요청의 IP/UA/Cookie 를 공유하며,
+// 각 원소의 raw 텍스트가 그대로 Event.Body 가 된다 (파싱/재직렬화 없음).
+// ------------------------------------------------------------
+
+var (
+	errBatchTooManyEvents = errors.New("batch: too many events")
+	errBatchInvalidBody   = errors.New("batch: invalid body")
+)
+
+// batchResult 는 /collect/batch 응답 body 이다.
+//
+// EventCh 가 배치 도중 가득 차면 앞쪽 Accepted 개는 이미 enqueue 된 상태이고,
+// 뒤쪽 Rejected 개는 drop 된 상태이다.
+// 클라이언트는 원래 순서 기준 마지막 Rejected 개만 재전송하면 된다.
+type batchResult struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+}
+
+// HandleCollectBatch
+//
+// 여러 이벤트를 담은 POST 요청을 처리하는 엔드포인트.
+//
+// 동작:
+//  1. MaxBodySize 제한 하에 body 를 BodyPool 버퍼로 읽는다 (Content-Encoding 해제 포함).
+//  2. Content-Type 에 따라 NDJSON / JSON 배열로 분리한다.
+//     (MaxBatchEvents 초과 시 아무것도 enqueue 하지 않고 413)
+//  3. 이벤트를 순서대로 EventCh 에 push 하고,
+//     큐가 가득 차는 순간(ADMISSION_WAIT 대기 후) 나머지 tail 은 모두 거절한다.
+//
+// 응답 코드:
+//   - 200 : 1개 이상 수락 (부분 수락 포함, body 의 rejected 로 판단)
+//   - 503 : 큐가 가득 차서 1개도 수락하지 못함
+//   - 400 / 413 : body 형식 오류 / 크기 또는 개수 초과
+func (h *Handler) HandleCollectBatch(w http.ResponseWriter, r *http.Request) {
+
+	// OPTIONS 요청은 CORS preflight 로 가정 → 즉시 204
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 인증 (AUTH_BATCH). hmac 서명은 body 를 읽은 뒤 검증한다.
+	sig, ok := h.authorize(w, r, h.cfg.AuthBatch)
+	if !ok {
+		return
+	}
+
+	// tenant 판정 (TENANTS_FILE 설정 시). 배치 요청도 rate limit 은 요청 단위이다.
+	ts, ok := h.admitTenant(w, r)
+	if !ok {
+		return
+	}
+
+	// 같은 요청의 이벤트는 IP/UA 를 공유하므로 한 번만 계산(및 익명화)한다.
+	meta := h.requestMeta(r)
+	if !h.limitClient(w, r, meta, ts) {
+		return
+	}
+	if h.dropBot(w, meta) {
+		return
+	}
+
+	maxBody := h.maxBodySize(ts)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	defer r.Body.Close()
+
+	buf := pool.BodyPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer pool.PutBody(buf, h.bodyPoolCap(maxBody))
+
+	if err := h.readBody(buf, r, maxBody); err != nil {
+		h.rejectBody(w, err)
+		return
+	}
+
+	h.metrics.HTTPRequestBodyBytes.Observe(float64(buf.Len()))
+
+	// 버퍼는 풀로 반환되므로 한 번만 string 으로 복사하고,
+	// 개별 이벤트 body 는 이 문자열의 substring 으로 공유한다.
+	body := buf.String()
+	if !h.verifySignature(w, sig, body) {
+		return
+	}
+
+	// 인증 / tenant / rate limit / 서명 검증을 통과한 요청만 센다 (/collect 의 http_requests_total 과 같은 기준).
+	atomic.AddInt64(&h.metrics.HTTPBatchRequestsTotal, 1)
+
+	bodies, err := splitBatch(body, r.Header.Get("Content-Type"), h.cfg.MaxBatchEvents)
+	switch {
+	case errors.Is(err, errBatchTooManyEvents):
+		atomic.AddInt64(&h.metrics.HTTPRequestsRejectedTooManyEventsTotal, 1)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	case err != nil:
+		atomic.AddInt64(&h.metrics.HTTPRequestsRejectedInvalidBodyTotal, 1)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	evs := make([]*model.Event, len(bodies))
+	for i, body := range bodies {
+		evs[i] = newEvent(r, meta, body)
+		evs[i].BodyType = h.bodyType(r)
+		evs[i].Tenant = ts.tenantID()
+	}
+
+	// WAL 기록: 배치 전체를 한 번의 group commit 으로 묶는다.
+	if err := h.worker.WAL.Append(evs...); err != nil {
+		recycleEvents(evs)
+		writeBatchResult(w, http.StatusServiceUnavailable, batchResult{Rejected: len(evs)})
+		return
+	}
+
+	adm := h.newAdmission(r.Context())
+	defer adm.stop()
+
+	var res batchResult
+	for i, ev := range evs {
+		if adm.enqueue(ev) {
+			res.Accepted++
+			continue
+		}
+
+		// Queue Full → 현재 이벤트 포함 나머지 tail 전체 거절
+		tail := evs[i:]
+		h.worker.WAL.Ack(tail...)
+		recycleEvents(tail)
+		res.Rejected = len(tail)
+		break
+	}
+
+	atomic.AddInt64(&h.metrics.HTTPBatchEventsAcceptedTotal, int64(res.Accepted))
+	ts.countAccepted(res.Accepted)
+
+	status := http.StatusOK
+	if res.Rejected > 0 {
+		atomic.AddInt64(&h.metrics.HTTPBatchEventsRejectedQueueFullTotal, int64(res.Rejected))
+		if res.Accepted == 0 {
+			status = http.StatusServiceUnavailable
+			h.setQueueFullRetryAfter(w)
+		}
+	}
+
+	writeBatchResult(w, status, res)
+}
+
+// recycleEvents 는 EventCh 에 넣지 못한 이벤트들을 풀로 반환한다.
+func recycleEvents(evs []*model.Event) {
+	for _, ev := range evs {
+		pool.ResetEvent(ev)
+		pool.EventPool.Put(ev)
+	}
+}
+
+// writeBatchResult 는 batchResult 를 JSON 으로 응답한다.
+func writeBatchResult(w http.ResponseWriter, status int, res batchResult) {
+	b, _ := json.Marshal(res)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
+
+// splitBatch
+//
+// 배치 body 를 이벤트 단위 문자열로 분리한다.
+// 반환되는 문자열은 모두 s 의 substring 이므로 추가 할당이 없다.
+//
+// 포맷 판단:
+//   - Content-Type 이 application/json 이면 JSON 배열
+//   - application/x-ndjson (및 jsonl 별칭) 이면 NDJSON
+//   - 그 외/미지정이면 첫 번째 non-space 문자가 '[' 인지로 판단
+func splitBatch(s, contentType string, limit int) ([]string, error) {
+	mt, _, _ := mime.ParseMediaType(contentType)
+
+	var bodies []string
+	var err error
+
+	switch mt {
+	case "application/json":
+		bodies, err = splitJSONArray(s, limit)
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		bodies, err = splitNDJSON(s, limit)
+	default:
+		if strings.HasPrefix(strings.TrimSpace(s), "[") {
+			bodies, err = splitJSONArray(s, limit)
+		} else {
+			bodies, err = splitNDJSON(s, limit)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if len(bodies) == 0 {
+		return nil, errBatchInvalidBody
+	}
+	return bodies, nil
+}
+
+// splitNDJSON 은 줄 단위로 이벤트를 분리한다.
+// 빈 줄은 무시하며, 각 줄의 JSON 유효성은 검사하지 않는다 (단건 /collect 와 동일 정책).
+func splitNDJSON(s string, limit int) ([]string, error) {
+	var bodies []string
+
+	for len(s) > 0 {
+		line := s
+		if i := strings.IndexByte(s, '\n'); i >= 0 {
+			line, s = s[:i], s[i+1:]
+		} else {
+			s = ""
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(bodies) >= limit {
+			return nil, errBatchTooManyEvents
+		}
+		bodies = append(bodies, line)
+	}
+
+	return bodies, nil
+}
+
+// skipValue 는 JSON 값을 검증만 하고 복사하지 않기 위한 타입이다.
+// (RawMessage 는 원소마다 복사본을 만든다)
+type skipValue struct{}
+
+func (skipValue) UnmarshalJSON([]byte) error { return nil }
+
+// splitJSONArray 는 최상위 JSON 배열의 각 원소를 raw 텍스트로 분리한다.
+// Decoder.InputOffset 으로 원소 경계만 계산하여 s 를 직접 slicing 한다.
+func splitJSONArray(s string, limit int) ([]string, error) {
+	dec := stdjson.NewDecoder(strings.NewReader(s))
+
+	if tok, err := dec.Token(); err != nil || tok != stdjson.Delim('[') {
+		return nil, errBatchInvalidBody
+	}
+
+	var bodies []string
+	for dec.More() {
+		start := dec.InputOffset()
+
+		var v skipValue
+		if err := dec.Decode(&v); err != nil {
+			return nil, errBatchInvalidBody
+		}
+		if len(bodies) >= limit {
+			return nil, errBatchTooManyEvents
+		}
+
+		// start 는 이전 원소 직후를 가리키므로 구분자(',')와 공백을 제거한다.
+		elem := strings.TrimLeft(s[start:dec.InputOffset()], " \t\r\n,")
+		bodies = append(bodies, elem)
+	}
+
+	if tok, err := dec.Token(); err != nil || tok != stdjson.Delim(']') {
+		return nil, errBatchInvalidBody
+	}
+	// 배열 뒤에는 공백 외에 아무것도 없어야 한다 (다른 값이나 깨진 토큰 모두 거부).
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errBatchInvalidBody
+	}
+
+	return bodies, nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		limit       int
+		want        []string
+		wantErr     error
+	}{
+		{
+			name:        "json array",
+			body:        `[{"a":1}, {"b":2}]`,
+			contentType: "application/json",
+			limit:       10,
+			want:        []string{`{"a":1}`, `{"b":2}`},
+		},
+		{
+			name:  "json array detected without content-type",
+			body:  "  [{\"a\":1},\n{\"b\":[1,2]}]\n",
+			limit: 10,
+			want:  []string{`{"a":1}`, `{"b":[1,2]}`},
+		},
+		{
+			name:        "ndjson skips blank lines",
+			body:        "{\"a\":1}\n\n{\"b\":2}\r\n",
+			contentType: "application/x-ndjson",
+			limit:       10,
+			want:        []string{`{"a":1}`, `{"b":2}`},
+		},
+		{
+			name:        "trailing garbage after array",
+			body:        `[{"a":1}] garbage`,
+			contentType: "application/json",
+			limit:       10,
+			wantErr:     errBatchInvalidBody,
+		},
+		{
+			name:        "trailing value after array",
+			body:        `[{"a":1}]{}`,
+			contentType: "application/json",
+			limit:       10,
+			wantErr:     errBatchInvalidBody,
+		},
+		{
+			name:        "unterminated array",
+			body:        `[{"a":1}`,
+			contentType: "application/json",
+			limit:       10,
+			wantErr:     errBatchInvalidBody,
+		},
+		{
+			name:        "empty array",
+			body:        `[]`,
+			contentType: "application/json",
+			limit:       10,
+			wantErr:     errBatchInvalidBody,
+		},
+		{
+			name:        "too many events",
+			body:        `[1,2,3]`,
+			contentType: "application/json",
+			limit:       2,
+			wantErr:     errBatchTooManyEvents,
+		},
+		{
+			name:    "too many ndjson lines",
+			body:    "1\n2\n3\n",
+			limit:   2,
+			wantErr: errBatchTooManyEvents,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitBatch(tt.body, tt.contentType, tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("bodies = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"estat-ingest/internal/pool"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ------------------------------------------------------------
+// Compressed Request Body
+//
+// 디바이스에서 이벤트를 모아 보내는 클라이언트는 payload 를 압축해서 보낸다.
+// Content-Encoding(gzip / deflate / zstd)에 따라 body 를 해제하여
+// 평문을 BodyPool 버퍼에 담는다 (Event.Body 에는 항상 평문이 저장된다).
+//
+// 크기 제한은 두 단계로 적용된다:
+//   - MaxBodySize             : 네트워크로 받은 압축 body 크기 (MaxBytesReader)
+//   - MaxDecompressedBodySize : 해제 후 평문 크기 (zip bomb 방지)
+// ------------------------------------------------------------
+
+var (
+	errUnsupportedEncoding  = errors.New("unsupported content-encoding")
+	errDecompressFailed     = errors.New("decompress failed")
+	errDecompressedTooLarge = errors.New("decompressed body too large")
+)
+
+// decompressInto 는 압축된 src 를 encoding 에 맞게 해제하여 dst 에 쓴다.
+// 해제 결과가 limit 를 초과하면 errDecompressedTooLarge 를 반환한다.
+func decompressInto(dst *bytes.Buffer, src []byte, encoding string, limit int64) error {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		gz := pool.GzipReaderPool.Get().(*gzip.Reader)
+		defer pool.GzipReaderPool.Put(gz)
+
+		if err := gz.Reset(bytes.NewReader(src)); err != nil {
+			return errDecompressFailed
+		}
+		defer gz.Close()
+		return copyLimited(dst, gz, limit)
+
+	case "deflate":
+		// HTTP 표준의 deflate 는 zlib(RFC 1950) 포맷이지만,
+		// raw deflate(RFC 1951)를 보내는 클라이언트도 많으므로 헤더로 구분한다.
+		var rc io.ReadCloser
+		if isZlibHeader(src) {
+			zr, err := zlib.NewReader(bytes.NewReader(src))
+			if err != nil {
+				return errDecompressFailed
+			}
+			rc = zr
+		} else {
+			rc = flate.NewReader(bytes.NewReader(src))
+		}
+		defer rc.Close()
+		return copyLimited(dst, rc, limit)
+
+	case "zstd":
+		zd := pool.ZstdReaderPool.Get().(*zstd.Decoder)
+		defer pool.ZstdReaderPool.Put(zd)
+
+		if err := zd.Reset(bytes.NewReader(src)); err != nil {
+			return errDecompressFailed
+		}
+		// 풀에 보관되는 동안 src 를 붙잡고 있지 않도록 참조 해제
+		defer zd.Reset(nil)
+		return copyLimited(dst, zd, limit)
+
+	default:
+		return errUnsupportedEncoding
+	}
+}
+
+// copyLimited 는 limit+1 바이트까지만 읽어서 초과 여부를 판단한다.
+// 해제 스트림을 끝까지 읽지 않으므로 zip bomb 에도 CPU/메모리 사용량이 제한된다.
+func copyLimited(dst *bytes.Buffer, r io.Reader, limit int64) error {
+	n, err := io.Copy(dst, io.LimitReader(r, limit+1))
+	if err != nil {
+		return errDecompressFailed
+	}
+	if n > limit {
+		return errDecompressedTooLarge
+	}
+	return nil
+}
+
+// isZlibHeader 는 RFC 1950 헤더(CMF/FLG) 여부를 검사한다.
+//   - CM(하위 4비트) == 8 (deflate)
+//   - (CMF*256 + FLG) % 31 == 0
+func isZlibHeader(b []byte) bool {
+	if len(b) < 2 {
+		return false
+	}
+	cmf, flg := b[0], b[1]
+	return cmf&0x0f == 8 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/rs/zerolog/log"
+)
+
+// ------------------------------------------------------------
+// GeoIP Enrichment
+//
+// 로컬 MaxMind mmdb 파일에서 클라이언트 IP 를 조회하여
+// Event 에 geo_country / geo_region / geo_city / geo_asn 을 붙인다.
+//
+//   - GEOIP_DB     : City/Country DB (GeoIP2-City, GeoLite2-City 등) → country, region, city
+//   - GEOIP_ASN_DB : ASN DB (GeoLite2-ASN 등)                        → asn
+//
+// 조회는 익명화(IP_ANON_MODE) 전의 원본 IP 로 수행한다.
+// 따라서 hash / drop 모드에서도 지역 정보는 남고, 원본 IP 는 남지 않는다.
+//
+// 동작 방식:
+//   - mmdb 는 maxminddb.Open 으로 mmap 된다 (heap 에 올리지 않는다).
+//   - 조회는 atomic.Pointer 로 현재 Reader 를 읽어 사용한다 (hot path 에 lock 없음).
+//   - GEOIP_RELOAD 주기로 파일의 mtime / size 를 확인해 바뀌었으면 새 Reader 로 교체한다.
+//     Reader 는 참조 수를 세며, 교체된 Reader 는 진행 중인 조회가 모두 끝난 뒤 Close(munmap) 한다.
+//     (시간 기준으로 닫으면 GC pause 등으로 늦어진 조회가 unmap 된 메모리를 읽을 수 있다)
+//
+// 주의:
+//   - DB 파일은 rename 으로 교체해야 한다 (geoipupdate 기본 동작).
+//     같은 파일을 제자리에서 덮어쓰면 mmap 된 내용이 조회 도중 바뀐다.
+// ------------------------------------------------------------
+
+// geoInfo 는 Event 에 붙일 GeoIP 조회 결과이다.
+type geoInfo struct {
+	country string // ISO 3166-1 alpha-2 (예: KR)
+	region  string // 첫 번째 subdivision ISO 코드 (예: 11)
+	city    string // 도시 이름 (영문)
+	asn     uint32 // AS 번호
+}
+
+// geoRecord 는 mmdb 레코드 중 사용하는 필드만 디코딩하기 위한 구조체이다.
+// City DB 와 ASN DB 가 같은 구조체를 쓰며, 각 DB 에 없는 필드는 zero value 로 남는다.
+// (names 는 map 대신 struct 로 받아 언어별 map 할당을 피한다)
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names struct {
+			EN string `maxminddb:"en"`
+		} `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN uint32 `maxminddb:"autonomous_system_number"`
+}
+
+// geoReader 는 참조 수를 세는 Reader 이다.
+// refs 는 geoDB 가 현재 Reader 로 들고 있는 참조 1 과 진행 중인 조회 수의 합이며, 0 이 되면 Close 한다.
+type geoReader struct {
+	*maxminddb.Reader
+	refs atomic.Int64
+}
+
+func newGeoReader(r *maxminddb.Reader) *geoReader {
+	gr := &geoReader{Reader: r}
+	gr.refs.Store(1)
+	return gr
+}
+
+// acquire 는 조회용 참조를 얻는다. 이미 닫혔거나 닫히는 중이면 false 이다.
+func (r *geoReader) acquire() bool {
+	for {
+		n := r.refs.Load()
+		if n == 0 {
+			return false
+		}
+		if r.refs.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// release 는 참조를 반환하고, 마지막 참조였으면 Close(munmap) 한다.
+func (r *geoReader) release() {
+	if r.refs.Add(-1) == 0 {
+		_ = r.Close()
+	}
+}
+
+// geoDB 는 하나의 mmdb 파일과 현재 Reader 이다.
+type geoDB struct {
+	path   string
+	reader atomic.Pointer[geoReader]
+
+	// 마지막으로 연 파일의 상태 (reloadLoop 전용)
+	modTime time.Time
+	size    int64
+}
+
+// geoIP 는 설정된 mmdb 들을 묶는다. GeoIP 를 사용하지 않으면 nil 이며, Lookup 은 빈 값을 반환한다.
+type geoIP struct {
+	dbs     []*geoDB
+	metrics *metrics.Metrics
+}
+
+// newGeoIP 는 GEOIP_DB / GEOIP_ASN_DB 를 열고 reload goroutine 을 시작한다.
+// 둘 다 비어있으면 nil 을 반환한다. 파일은 config.Load 에서 존재 여부가 검증되었다.
+func newGeoIP(cfg config.Config, m *metrics.Metrics) *geoIP {
+	g := &geoIP{metrics: m}
+
+	for _, path := range []string{cfg.GeoIPDB, cfg.GeoIPASNDB} {
+		if path == "" {
+			continue
+		}
+		db := &geoDB{path: path}
+		if err := db.open(); err != nil {
+			log.Fatal().Err(err).Str("path", path).Msg("failed to open GeoIP database")
+		}
+		log.Info().
+			Str("path", path).
+			Str("type", db.reader.Load().Metadata.DatabaseType).
+			Msg("GeoIP database loaded")
+
+		go db.reloadLoop(cfg.GeoIPReload)
+		g.dbs = append(g.dbs, db)
+	}
+
+	if len(g.dbs) == 0 {
+		return nil
+	}
+	return g
+}
+
+// Lookup 은 ip(익명화 전)의 지역 정보를 조회한다.
+// 하나 이상의 DB 에서 레코드를 찾으면 hit, 아니면 miss 로 센다. 빈 ip 는 조회하지 않는다.
+func (g *geoIP) Lookup(ip string) geoInfo {
+	if g == nil || ip == "" {
+		return geoInfo{}
+	}
+
+	var (
+		rec   geoRecord
+		found bool
+	)
+	if parsed := net.ParseIP(ip); parsed != nil {
+		for _, db := range g.dbs {
+			r := db.acquire()
+			_, ok, err := r.LookupNetwork(parsed, &rec)
+			r.release()
+			if err == nil && ok {
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		atomic.AddInt64(&g.metrics.GeoIPLookupMissesTotal, 1)
+		return geoInfo{}
+	}
+	atomic.AddInt64(&g.metrics.GeoIPLookupHitsTotal, 1)
+
+	info := geoInfo{
+		country: rec.Country.ISOCode,
+		city:    rec.City.Names.EN,
+		asn:     rec.ASN,
+	}
+	if len(rec.Subdivisions) > 0 {
+		info.region = rec.Subdivisions[0].ISOCode
+	}
+	return info
+}
+
+// acquire 는 현재 Reader 의 참조를 얻는다. 사용 후 release 해야 한다.
+// 교체 직후 이전 Reader 가 닫혀 acquire 에 실패하면, 이미 교체된 새 Reader 를 다시 읽는다.
+func (db *geoDB) acquire() *geoReader {
+	for {
+		if r := db.reader.Load(); r.acquire() {
+			return r
+		}
+	}
+}
+
+// open 은 파일을 mmap 으로 열어 현재 Reader 로 교체하고, 이전 Reader 는 진행 중인 조회가 끝나면 닫는다.
+func (db *geoDB) open() error {
+	st, err := os.Stat(db.path)
+	if err != nil {
+		return err
+	}
+	r, err := maxminddb.Open(db.path)
+	if err != nil {
+		return err
+	}
+
+	db.modTime, db.size = st.ModTime(), st.Size()
+	if old := db.reader.Swap(newGeoReader(r)); old != nil {
+		old.release()
+	}
+	return nil
+}
+
+// reloadLoop 는 interval 마다 파일 상태를 확인해 바뀌었으면 다시 연다.
+// 실패 시 기존 Reader 를 유지한다 (조회가 멈추지 않는다).
+func (db *geoDB) reloadLoop(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for range t.C {
+		reloaded, err := db.reloadIfChanged()
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("path", db.path).
+				Msg("GeoIP database reload failed, keeping previous database")
+			continue
+		}
+		if reloaded {
+			log.Info().
+				Str("path", db.path).
+				Uint("build_epoch", db.reader.Load().Metadata.BuildEpoch).
+				Msg("GeoIP database reloaded")
+		}
+	}
+}
+
+// reloadIfChanged 는 파일의 mtime / size 가 마지막으로 연 상태와 다르면 다시 연다.
+func (db *geoDB) reloadIfChanged() (bool, error) {
+	st, err := os.Stat(db.path)
+	if err != nil {
+		return false, err
+	}
+	if st.ModTime().Equal(db.modTime) && st.Size() == db.size {
+		return false, nil
+	}
+	return true, db.open()
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+)
+
+// writeTestMMDB 는 IPv4 전용, record size 24 의 최소 mmdb 파일을 만든다.
+// records 의 key 는 CIDR, value 는 mmdbEncode 가 지원하는 값(map/slice/string/uint*)이다.
+func writeTestMMDB(t *testing.T, path string, records map[string]any) {
+	t.Helper()
+
+	const (
+		empty = iota
+		child
+		leaf
+	)
+	type ref struct {
+		kind int
+		v    uint32 // child: node index, leaf: data offset
+	}
+
+	var data bytes.Buffer
+	nodes := make([][2]ref, 1)
+
+	for cidr, rec := range records {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		off := uint32(data.Len())
+		mmdbEncode(&data, rec)
+
+		ip := n.IP.To4()
+		bits, _ := n.Mask.Size()
+		node := 0
+		for i := 0; i < bits; i++ {
+			bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+			if i == bits-1 {
+				nodes[node][bit] = ref{leaf, off}
+				break
+			}
+			if nodes[node][bit].kind != child {
+				nodes = append(nodes, [2]ref{})
+				nodes[node][bit] = ref{child, uint32(len(nodes) - 1)}
+			}
+			node = int(nodes[node][bit].v)
+		}
+	}
+
+	var out bytes.Buffer
+	nodeCount := uint32(len(nodes))
+	for _, n := range nodes {
+		for _, r := range n {
+			v := nodeCount
+			switch r.kind {
+			case child:
+				v = r.v
+			case leaf:
+				v = nodeCount + 16 + r.v
+			}
+			out.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	mmdbEncode(&out, map[string]any{
+		"node_count":                  nodeCount,
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(4),
+		"database_type":               "Test",
+		"languages":                   []any{"en"},
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"description":                 map[string]any{"en": "test"},
+	})
+
+	// 교체는 rename 으로 (mmap 된 기존 파일을 덮어쓰지 않는다)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mmdbEncode 는 MaxMind DB data section 포맷으로 v 를 기록한다. (size < 29 만 지원)
+func mmdbEncode(buf *bytes.Buffer, v any) {
+	ctrl := func(typ, size int) {
+		if typ <= 7 {
+			buf.WriteByte(byte(typ<<5 | size))
+			return
+		}
+		buf.WriteByte(byte(size))
+		buf.WriteByte(byte(typ - 7))
+	}
+	putUint := func(typ int, n uint64, width int) {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, n)
+		b = bytes.TrimLeft(b[8-width:], "\x00")
+		ctrl(typ, len(b))
+		buf.Write(b)
+	}
+
+	switch v := v.(type) {
+	case string:
+		ctrl(2, len(v))
+		buf.WriteString(v)
+	case uint16:
+		putUint(5, uint64(v), 2)
+	case uint32:
+		putUint(6, uint64(v), 4)
+	case uint64:
+		putUint(9, v, 8)
+	case map[string]any:
+		ctrl(7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			mmdbEncode(buf, k)
+			mmdbEncode(buf, v[k])
+		}
+	case []any:
+		ctrl(11, len(v))
+		for _, e := range v {
+			mmdbEncode(buf, e)
+		}
+	default:
+		panic("unsupported mmdb value")
+	}
+}
+
+func cityRecord(country, region, city string) map[string]any {
+	return map[string]any{
+		"country":      map[string]any{"iso_code": country},
+		"subdivisions": []any{map[string]any{"iso_code": region}},
+		"city":         map[string]any{"names": map[string]any{"en": city, "ko": "도시"}},
+	}
+}
+
+func TestGeoIPLookup(t *testing.T) {
+	dir := t.TempDir()
+	cityDB := filepath.Join(dir, "city.mmdb")
+	asnDB := filepath.Join(dir, "asn.mmdb")
+	writeTestMMDB(t, cityDB, map[string]any{"203.0.113.0/24": cityRecord("KR", "11", "Seoul")})
+	writeTestMMDB(t, asnDB, map[string]any{
+		"203.0.113.0/24": map[string]any{"autonomous_system_number": uint32(64500)},
+		"192.0.2.0/24":   map[string]any{"autonomous_system_number": uint32(64501)},
+	})
+
+	m := metrics.New()
+	g := newGeoIP(config.Config{GeoIPDB: cityDB, GeoIPASNDB: asnDB, GeoIPReload: time.Hour}, m)
+
+	tests := []struct {
+		ip   string
+		want geoInfo
+	}{
+		{"203.0.113.7", geoInfo{country: "KR", region: "11", city: "Seoul", asn: 64500}},
+		{"192.0.2.1", geoInfo{asn: 64501}},
+		{"198.51.100.1", geoInfo{}},
+		{"not-an-ip", geoInfo{}},
+		{"", geoInfo{}},
+	}
+	for _, tt := range tests {
+		if got := g.Lookup(tt.ip); got != tt.want {
+			t.Errorf("Lookup(%q) = %+v, want %+v", tt.ip, got, tt.want)
+		}
+	}
+
+	// 빈 IP 는 조회하지 않는다
+	if m.GeoIPLookupHitsTotal != 2 || m.GeoIPLookupMissesTotal != 2 {
+		t.Fatalf("hits=%d misses=%d, want 2/2", m.GeoIPLookupHitsTotal, m.GeoIPLookupMissesTotal)
+	}
+}
+
+// rename 으로 교체된 DB 는 reload 후 조회에 반영된다.
+func TestGeoIPReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	writeTestMMDB(t, path, map[string]any{"203.0.113.0/24": cityRecord("KR", "11", "Seoul")})
+
+	g := newGeoIP(config.Config{GeoIPDB: path, GeoIPReload: time.Hour}, metrics.New())
+	db := g.dbs[0]
+
+	if reloaded, err := db.reloadIfChanged(); reloaded || err != nil {
+		t.Fatalf("reloadIfChanged on unchanged file = %v, %v", reloaded, err)
+	}
+
+	writeTestMMDB(t, path, map[string]any{"203.0.113.0/24": cityRecord("JP", "13", "Tokyo")})
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded, err := db.reloadIfChanged(); !reloaded || err != nil {
+		t.Fatalf("reloadIfChanged = %v, %v", reloaded, err)
+	}
+	if got := g.Lookup("203.0.113.7"); got.country != "JP" || got.city != "Tokyo" {
+		t.Fatalf("after reload Lookup = %+v", got)
+	}
+}
+
+// 교체된 Reader 는 진행 중인 조회가 끝날 때까지 닫히지 않고, 마지막 조회가 끝나면 닫힌다.
+func TestGeoIPReloadClosesAfterInflightLookups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	writeTestMMDB(t, path, map[string]any{"203.0.113.0/24": cityRecord("KR", "11", "Seoul")})
+
+	g := newGeoIP(config.Config{GeoIPDB: path, GeoIPReload: time.Hour}, metrics.New())
+	db := g.dbs[0]
+
+	inflight := db.acquire() // 교체 직전에 시작된 조회
+	if err := db.open(); err != nil {
+		t.Fatal(err)
+	}
+	if inflight == db.reader.Load() {
+		t.Fatal("reader not replaced")
+	}
+
+	var rec geoRecord
+	if _, ok, err := inflight.LookupNetwork(net.ParseIP("203.0.113.7"), &rec); err != nil || !ok {
+		t.Fatalf("in-flight lookup on replaced reader = %v, %v", ok, err)
+	}
+
+	inflight.release()
+	if _, _, err := inflight.LookupNetwork(net.ParseIP("203.0.113.7"), &rec); err == nil {
+		t.Fatal("replaced reader not closed after last lookup")
+	}
+	if got := g.Lookup("203.0.113.7"); got.country != "KR" {
+		t.Fatalf("Lookup after reload = %+v", got)
+	}
+}
+
+func TestGeoIPDisabled(t *testing.T) {
+	g := newGeoIP(config.Config{}, metrics.New())
+	if g != nil {
+		t.Fatal("newGeoIP without paths should return nil")
+	}
+	if got := g.Lookup("203.0.113.7"); got != (geoInfo{}) {
+		t.Fatalf("nil Lookup = %+v", got)
+	}
+}
@@ -2,8 +2,10 @@ package server
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
+	"strings"
 	"sync/atomic"
 
 	"estat-ingest/internal/config"
@@ -17,6 +19,16 @@ type Handler struct {
 	cfg     config.Config
 	metrics *metrics.Metrics
 	worker  *worker.Manager
+	ips     *ipResolver
+	anon    *ipAnonymizer
+	geo     *geoIP          // GEOIP_DB / GEOIP_ASN_DB 미설정 시 nil
+	ua      *uaParser       // UA_RULES_FILE 미설정 시 nil
+	tenants *tenantResolver // TENANTS_FILE 미설정 시 nil
+	auth    *authenticator  // AUTH_KEYS_FILE 미설정 시 nil
+	limiter *clientLimiter  // RATE_LIMIT_KEY=off 이면 nil
+
+	// shuttingDown 은 SIGTERM 수신 후 true 가 되며, /health/ready 는 즉시 503 을 반환한다.
+	shuttingDown atomic.Bool
 }
 
 func NewHandler(cfg config.Config, m *metrics.Metrics, w *worker.Manager) *Handler {
@@ -24,6 +36,13 @@ func NewHandler(cfg config.Config, m *metrics.Metrics, w *worker.Manager) *Handl
 		cfg:     cfg,
 		metrics: m,
 		worker:  w,
+		ips:     newIPResolver(cfg),
+		anon:    newIPAnonymizer(cfg),
+		geo:     newGeoIP(cfg, m),
+		ua:      newUAParser(cfg, m),
+		tenants: newTenantResolver(cfg, m),
+		auth:    newAuthenticator(cfg),
+		limiter: newClientLimiter(cfg, m),
 	}
 }
 
@@ -34,15 +53,27 @@ func NewHandler(cfg config.Config, m *metrics.Metrics, w *worker.Manager) *Handl
 // - POST: Body 기반
 //
 // 공통 동작:
-//  1. 요청 길이 제한(MaxBodySize)
+//  1. 요청 길이 제한(MaxBodySize) 및 Content-Encoding 해제(MaxDecompressedBodySize)
 //  2. BodyPool / EventPool 기반 메모리 재사용
-//  3. ingestion queue(EventCh)에 push (full이면 drop)
+//  3. ingestion queue(EventCh)에 push (full이면 ADMISSION_WAIT 만큼 기다린 뒤 503 + Retry-After)
 //  4. metrics 증가
 //
 // 운영 상 의미:
 //   - 이 함수는 ingest 서버의 "가장 뜨거운 경로(hot path)"로,
 //     서버 성능은 이 함수의 효율성에 큰 영향을 받는다.
+//   - AUTH_COLLECT 가 설정되면 인증된 요청만 받는다. 브라우저 beacon 은 HandleBeacon 을 사용한다.
 func (h *Handler) HandleCollect(w http.ResponseWriter, r *http.Request) {
+	h.collect(w, r, h.cfg.AuthCollect)
+}
+
+// HandleBeacon 은 BEACON_PATH 의 익명 수집 엔드포인트이다.
+// AUTH_COLLECT 와 무관하게 인증하지 않으며, 그 외 동작은 HandleCollect 와 같다.
+func (h *Handler) HandleBeacon(w http.ResponseWriter, r *http.Request) {
+	h.collect(w, r, "none")
+}
+
+// collect 는 authMode(none | api_key | hmac)로 인증한 뒤 단건 이벤트를 수집한다.
+func (h *Handler) collect(w http.ResponseWriter, r *http.Request, authMode string) {
 
 	// 허용 메서드 검사
 	if r.Method != http.MethodGet &&
@@ -58,11 +89,33 @@ func (h *Handler) HandleCollect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 인증 (api_key / hmac 헤더 검사). 실패 시 401 / 403.
+	sig, ok := h.authorize(w, r, authMode)
+	if !ok {
+		return
+	}
+
+	// tenant 판정 (TENANTS_FILE 설정 시). 알 수 없는 tenant 는 404, rate limit 초과는 429.
+	ts, ok := h.admitTenant(w, r)
+	if !ok {
+		return
+	}
+
+	// 요청 단위 필드(IP/Geo/UA). client 별 rate limit(429) 과 UA_BOT_POLICY=drop 은 body 를 읽기 전에 적용한다.
+	meta := h.requestMeta(r)
+	if !h.limitClient(w, r, meta, ts) {
+		return
+	}
+	if h.dropBot(w, meta) {
+		return
+	}
+
 	// --------------------------------------------------------------------
-	// 요청 Body 최대 크기 강제 제한
+	// 요청 Body 최대 크기 강제 제한 (tenant 별 max_body_size)
 	// Body가 커서 메모리가 과도하게 사용되는 것을 방지
 	// --------------------------------------------------------------------
-	r.Body = http.MaxBytesReader(w, r.Body, h.cfg.MaxBodySize)
+	maxBody := h.maxBodySize(ts)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
 	defer r.Body.Close()
 
 	var bodyStr string
@@ -73,13 +126,14 @@ func (h *Handler) HandleCollect(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 
 		// QueryString 크기 검사
-		if len(r.URL.RawQuery) > int(h.cfg.MaxBodySize) {
+		if len(r.URL.RawQuery) > int(maxBody) {
 			atomic.AddInt64(&h.metrics.HTTPRequestsRejectedBodyTooLargeTotal, 1)
 			w.WriteHeader(http.StatusRequestEntityTooLarge)
 			return
 		}
 
 		bodyStr = r.URL.RawQuery
+		h.metrics.HTTPRequestBodyBytes.Observe(float64(len(bodyStr)))
 
 	} else {
 		// ----------------------------------------------------------------
@@ -87,57 +141,207 @@ func (h *Handler) HandleCollect(w http.ResponseWriter, r *http.Request) {
 		// ----------------------------------------------------------------
 		buf := pool.BodyPool.Get().(*bytes.Buffer)
 		buf.Reset()
-		defer pool.PutBody(buf, h.cfg.MaxBodySize*2)
+		defer pool.PutBody(buf, h.bodyPoolCap(maxBody))
 
-		// io.Copy 는 매우 빠르고 GC-free. BodyPool 버퍼로 직접 복사.
-		if _, err := io.Copy(buf, r.Body); err != nil {
-			atomic.AddInt64(&h.metrics.HTTPRequestsRejectedBodyTooLargeTotal, 1)
-			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		if err := h.readBody(buf, r, maxBody); err != nil {
+			h.rejectBody(w, err)
 			return
 		}
 
 		bodyStr = buf.String()
+		h.metrics.HTTPRequestBodyBytes.Observe(float64(len(bodyStr)))
+	}
+
+	// hmac 서명 검증 (body 를 읽은 뒤에만 가능)
+	if !h.verifySignature(w, sig, bodyStr) {
+		return
 	}
 
 	// --------------------------------------------------------------------
 	// Event 객체 생성 (EventPool 재사용)
 	// --------------------------------------------------------------------
-	ev := pool.EventPool.Get().(*model.Event)
-	pool.ResetEvent(ev)
-
-	ev.Ts = worker.Unix()        // ingest 시점 timestamp
-	ev.IP = clientIP(r)          // X-Forwarded-For 기반 IP
-	ev.UserAgent = r.UserAgent() // UA
-	ev.Cookie = r.Header.Get("Cookie")
-	ev.Body = bodyStr
+	ev := newEvent(r, meta, bodyStr)
+	ev.BodyType = h.bodyType(r)
+	ev.Tenant = ts.tenantID()
 
 	atomic.AddInt64(&h.metrics.HTTPRequestsTotal, 1)
 
+	// --------------------------------------------------------------------
+	// WAL 기록 (WAL_ENABLED 인 경우에만, 비활성화 시 no-op)
+	// group commit fsync 가 끝난 뒤에만 200 을 반환할 수 있다.
+	// --------------------------------------------------------------------
+	if err := h.worker.WAL.Append(ev); err != nil {
+		pool.ResetEvent(ev)
+		pool.EventPool.Put(ev)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
 	// --------------------------------------------------------------------
 	// 이벤트를 ingestion queue(EventCh)에 push
-	// Queue가 가득 찬 경우 → drop (backpressure)
+	// Queue가 가득 찬 경우 → ADMISSION_WAIT 만큼 기다린 뒤 drop (backpressure)
 	// --------------------------------------------------------------------
-	select {
-	case h.worker.EventCh <- ev:
+	adm := h.newAdmission(r.Context())
+	defer adm.stop()
+
+	if adm.enqueue(ev) {
 		// 정상적으로 ingestion queue에 들어감
 		atomic.AddInt64(&h.metrics.HTTPRequestsAcceptedTotal, 1)
+		ts.countAccepted(1)
 		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Queue Full → drop (이벤트 재사용 풀로 반환)
+	h.worker.WAL.Ack(ev)
+	pool.ResetEvent(ev)
+	pool.EventPool.Put(ev)
 
+	atomic.AddInt64(&h.metrics.HTTPRequestsRejectedQueueFullTotal, 1)
+	h.setQueueFullRetryAfter(w)
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// readBody
+//
+// 요청 Body 를 BodyPool 버퍼로 읽어들인다.
+// r.Body 는 호출 전에 maxBody(요청의 tenant 별 max_body_size)로 MaxBytesReader 가 감싸져 있어야 하며,
+// 크기 초과 시 에러를 반환한다.
+//
+// Content-Encoding 이 지정된 경우 압축 body 를 별도 BodyPool 버퍼에 받은 뒤
+// 해제 결과(평문)를 buf 에 채운다.
+func (h *Handler) readBody(buf *bytes.Buffer, r *http.Request, maxBody int64) error {
+	enc := r.Header.Get("Content-Encoding")
+	if enc == "" || strings.EqualFold(enc, "identity") {
+		// io.Copy 는 매우 빠르고 GC-free. BodyPool 버퍼로 직접 복사.
+		_, err := io.Copy(buf, r.Body)
+		return err
+	}
+
+	raw := pool.BodyPool.Get().(*bytes.Buffer)
+	raw.Reset()
+	defer pool.PutBody(raw, maxBody*2)
+
+	if _, err := io.Copy(raw, r.Body); err != nil {
+		return err
+	}
+
+	return decompressInto(buf, raw.Bytes(), enc, h.cfg.MaxDecompressedBodySize)
+}
+
+// bodyPoolCap
+//
+// 해제 결과(평문)를 담는 버퍼의 BodyPool 반환 상한.
+// Content-Encoding 이 있으면 평문은 maxBody(요청의 body 상한)가 아니라 MaxDecompressedBodySize 까지 커질 수 있으므로
+// 둘 중 큰 값을 기준으로 한다. (압축 원본 버퍼 raw 는 maxBody 로 제한된다)
+func (h *Handler) bodyPoolCap(maxBody int64) int64 {
+	return max(maxBody, h.cfg.MaxDecompressedBodySize) * 2
+}
+
+// rejectBody
+//
+// readBody 에러를 응답 코드 및 metrics 로 변환한다.
+//   - 지원하지 않는 Content-Encoding → 415
+//   - 손상된 압축 스트림            → 400
+//   - 해제 후 크기 초과             → 413
+//   - 그 외(MaxBytesReader 초과 등) → 413
+func (h *Handler) rejectBody(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUnsupportedEncoding):
+		atomic.AddInt64(&h.metrics.HTTPRequestsDecompressErrorsTotal, 1)
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+	case errors.Is(err, errDecompressFailed):
+		atomic.AddInt64(&h.metrics.HTTPRequestsDecompressErrorsTotal, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, errDecompressedTooLarge):
+		atomic.AddInt64(&h.metrics.HTTPRequestsRejectedDecompressedTooLargeTotal, 1)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
 	default:
-		// Queue Full → drop (이벤트 재사용 풀로 반환)
-		pool.ResetEvent(ev)
-		pool.EventPool.Put(ev)
+		atomic.AddInt64(&h.metrics.HTTPRequestsRejectedBodyTooLargeTotal, 1)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}
+}
 
-		atomic.AddInt64(&h.metrics.HTTPRequestsRejectedQueueFullTotal, 1)
-		w.WriteHeader(http.StatusServiceUnavailable)
+// reqMeta 는 같은 요청에서 나온 모든 이벤트가 공유하는 요청 단위 필드이다.
+type reqMeta struct {
+	rawIP string // 익명화 전 IP. RATE_LIMIT_KEY=ip 의 key 로만 사용한다 (저장하지 않음)
+	ip    anonIP
+	geo   geoInfo
+	ua    uaInfo
+}
+
+// requestMeta 는 clientIP(GeoIP 조회, 익명화 포함)와 UA 파싱을 요청당 한 번 수행한다.
+func (h *Handler) requestMeta(r *http.Request) reqMeta {
+	raw, ip, geo := h.clientIP(r)
+	return reqMeta{rawIP: raw, ip: ip, geo: geo, ua: h.ua.Parse(r.UserAgent())}
+}
+
+// dropBot 은 UA_BOT_POLICY=drop 이고 봇 요청이면 204 로 응답하고 true 를 반환한다.
+// 조용히 사라지지 않도록 ua_bot_requests_dropped_total 로 센다.
+func (h *Handler) dropBot(w http.ResponseWriter, meta reqMeta) bool {
+	if !meta.ua.isBot || h.cfg.UABotPolicy != "drop" {
+		return false
+	}
+	atomic.AddInt64(&h.metrics.UABotRequestsDroppedTotal, 1)
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// newEvent
+//
+// EventPool 에서 Event 객체를 꺼내 요청 공통 필드(IP/Geo/UA/Cookie)와 body 를 채운다.
+// 배치 요청에서는 같은 요청의 여러 이벤트가 요청 단위 필드를 공유하므로
+// requestMeta 는 caller 가 한 번만 계산해 넘겨준다.
+func newEvent(r *http.Request, meta reqMeta, body string) *model.Event {
+	ev := pool.EventPool.Get().(*model.Event)
+	pool.ResetEvent(ev)
+
+	ev.Ts = worker.Unix() // ingest 시점 timestamp
+	ev.IP = meta.ip.ip    // 신뢰 프록시 헤더 / RemoteAddr 기반 IP (익명화 후)
+	ev.IPMode = meta.ip.mode
+	ev.IPSaltID = meta.ip.saltID
+	ev.GeoCountry = meta.geo.country
+	ev.GeoRegion = meta.geo.region
+	ev.GeoCity = meta.geo.city
+	ev.GeoASN = meta.geo.asn
+	ev.UserAgent = r.UserAgent() // UA
+	ev.UABrowser = meta.ua.browser
+	ev.UABrowserVersion = meta.ua.browserVersion
+	ev.UAOS = meta.ua.os
+	ev.UAOSVersion = meta.ua.osVersion
+	ev.UADevice = meta.ua.device
+	ev.UABot = meta.ua.bot
+	ev.IsBot = meta.ua.isBot
+	ev.Cookie = r.Header.Get("Cookie")
+	ev.Body = body
+	return ev
+}
+
+// bodyType 은 BODY_FORMAT=structured 일 때 worker 가 body 를 구조화할 방식을 반환한다 (raw 면 빈 값).
+// GET 은 QueryString(params), POST 는 JSON body(body_json) 로 취급한다.
+func (h *Handler) bodyType(r *http.Request) string {
+	switch {
+	case h.cfg.BodyFormat != "structured":
+		return ""
+	case r.Method == http.MethodGet:
+		return "query"
+	default:
+		return "post"
 	}
 }
 
 // HandleMetrics
 //
-// ingest 서버 상태를 나타내는 카운터 값들을 출력한다.
-// Prometheus pull 방식으로도 쉽게 전환 가능.
-func (h *Handler) HandleMetrics(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	_, _ = io.WriteString(w, h.metrics.String())
+// ingest 서버 상태를 나타내는 지표를 출력한다.
+//   - 기본           : Prometheus text exposition format (HELP/TYPE, histogram 포함)
+//   - ?format=kv     : 레거시 "name=value" 포맷 (기존 운영 스크립트 호환용)
+func (h *Handler) HandleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "kv" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, h.metrics.String())
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.PrometheusContentType)
+	_ = h.metrics.WritePrometheus(w)
 }
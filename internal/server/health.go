@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"estat-ingest/internal/worker"
+
+	json "github.com/goccy/go-json"
+)
+
+// ------------------------------------------------------------
+// Health check
+//
+//   - /health/live  : 프로세스가 살아있으면 항상 200 (ECS container health check 용)
+//   - /health/ready : 새 요청을 받아도 되는 상태인지 (ALB target group health check 용)
+//
+// readiness 판단 기준 (하나라도 해당하면 503):
+//  1. SIGTERM 수신 후 (BeginShutdown)
+//  2. EventCh 사용률 >= READY_QUEUE_RATIO
+//  3. DLQ 사용률(DLQSizeBytes / DLQ_MAX_SIZE_BYTES) >= READY_DLQ_RATIO
+//  4. 연속 S3 업로드 실패(재시도 소진 기준) >= READY_MAX_S3_FAILURES
+//  5. S3 circuit breaker 가 open (half-open 은 probe 중이므로 ready 로 본다)
+//
+// ALB 가 이 target 으로 새 트래픽을 보내지 않게 하는 것이 목적이므로,
+// 이미 수신 중인 /collect 요청 처리에는 영향을 주지 않는다.
+// ------------------------------------------------------------
+
+// readyResult 는 /health/ready 응답 body 이다.
+type readyResult struct {
+	Ready                 bool     `json:"ready"`
+	Reasons               []string `json:"reasons,omitempty"`
+	QueueFillRatio        float64  `json:"queue_fill_ratio"`
+	DLQFillRatio          float64  `json:"dlq_fill_ratio"`
+	S3ConsecutiveFailures int64    `json:"s3_consecutive_failures"`
+	S3Breaker             string   `json:"s3_breaker"`
+}
+
+// BeginShutdown 은 /health/ready 를 즉시 503 으로 전환한다.
+// main 은 SIGTERM 수신 시 이를 호출하고 ShutdownDrainDelay 만큼 기다린 뒤 srv.Shutdown 을 호출한다.
+func (h *Handler) BeginShutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// HandleLive
+//
+// liveness: 프로세스가 요청을 처리할 수 있으면 항상 200 "ok".
+func (h *Handler) HandleLive(w http.ResponseWriter, _ *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+// HandleReady
+//
+// readiness: 위 판단 기준에 따라 200 또는 503 과 JSON body(사유 포함)를 반환한다.
+func (h *Handler) HandleReady(w http.ResponseWriter, _ *http.Request) {
+	res := h.readiness()
+
+	status := http.StatusOK
+	if !res.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	b, _ := json.Marshal(res)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
+
+// readiness 는 현재 상태와 임계값을 비교해 readyResult 를 만든다.
+func (h *Handler) readiness() readyResult {
+	var res readyResult
+
+	if c := cap(h.worker.EventCh); c > 0 {
+		res.QueueFillRatio = float64(len(h.worker.EventCh)) / float64(c)
+	}
+	if max := h.cfg.DLQMaxSizeBytes; max > 0 {
+		res.DLQFillRatio = float64(atomic.LoadInt64(&h.metrics.DLQSizeBytes)) / float64(max)
+	}
+	res.S3ConsecutiveFailures = atomic.LoadInt64(&h.metrics.S3ConsecutiveFailures)
+	breaker := worker.BreakerState(atomic.LoadInt64(&h.metrics.S3BreakerState))
+	res.S3Breaker = breaker.String()
+
+	if h.shuttingDown.Load() {
+		res.Reasons = append(res.Reasons, "shutting down")
+	}
+	if res.QueueFillRatio >= h.cfg.ReadyQueueRatio {
+		res.Reasons = append(res.Reasons,
+			fmt.Sprintf("event queue %.0f%% full (threshold %.0f%%)", res.QueueFillRatio*100, h.cfg.ReadyQueueRatio*100))
+	}
+	if res.DLQFillRatio >= h.cfg.ReadyDLQRatio {
+		res.Reasons = append(res.Reasons,
+			fmt.Sprintf("DLQ %.0f%% full (threshold %.0f%%)", res.DLQFillRatio*100, h.cfg.ReadyDLQRatio*100))
+	}
+	if res.S3ConsecutiveFailures >= int64(h.cfg.ReadyMaxS3Failures) {
+		res.Reasons = append(res.Reasons,
+			fmt.Sprintf("%d consecutive S3 failures (threshold %d)", res.S3ConsecutiveFailures, h.cfg.ReadyMaxS3Failures))
+	}
+
+	if breaker == worker.BreakerOpen {
+		res.Reasons = append(res.Reasons, "S3 circuit breaker open")
+	}
+
+	res.Ready = len(res.Reasons) == 0
+	return res
+}
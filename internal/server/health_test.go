@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+	"estat-ingest/internal/worker"
+)
+
+// S3 circuit breaker 가 open 이면 readiness 는 503 이고, 상태는 body 에 포함된다.
+func TestHandleReadyBreakerOpen(t *testing.T) {
+	cfg := config.Config{ReadyQueueRatio: 0.9, ReadyDLQRatio: 0.9, ReadyMaxS3Failures: 5, DLQMaxSizeBytes: 100}
+	h := &Handler{cfg: cfg, metrics: metrics.New(), worker: &worker.Manager{EventCh: make(chan *model.Event, 10)}}
+
+	rec := httptest.NewRecorder()
+	h.HandleReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"s3_breaker":"closed"`) {
+		t.Fatalf("closed: status %d body %s", rec.Code, rec.Body)
+	}
+
+	h.metrics.S3BreakerState = int64(worker.BreakerOpen)
+	rec = httptest.NewRecorder()
+	h.HandleReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "S3 circuit breaker open") {
+		t.Fatalf("open: status %d body %s", rec.Code, rec.Body)
+	}
+
+	h.metrics.S3BreakerState = int64(worker.BreakerHalfOpen)
+	rec = httptest.NewRecorder()
+	h.HandleReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"s3_breaker":"half_open"`) {
+		t.Fatalf("half-open: status %d body %s", rec.Code, rec.Body)
+	}
+}
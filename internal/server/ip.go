@@ -4,6 +4,11 @@ import (
 	"net"
 	"net/http"
 	"strings"
+	"sync/atomic"
+
+	"estat-ingest/internal/config"
+
+	"github.com/rs/zerolog/log"
 )
 
 // ------------------------------------------------------------
@@ -11,26 +16,222 @@ import (
 //
 // ingest 서버는 ALB 또는 CloudFront 뒤에 배치되므로
 // RemoteAddr 만으로는 "실제 사용자 IP"를 알 수 없다.
-// 아래 로직은 AWS 표준 헤더 기반으로
-// 가장 신뢰할 수 있는 클라이언트 IP를 추출한다.
+//
+// 단, 프록시 헤더(X-Forwarded-For 등)는 클라이언트가 임의로 채워 보낼 수 있으므로
+// "신뢰하는 프록시(TRUSTED_PROXIES)"가 붙인 값만 사용한다.
+//
+//  1. RemoteAddr 가 신뢰 프록시가 아니면 헤더를 보지 않고 RemoteAddr 를 사용한다.
+//  2. 신뢰 프록시라면 CLIENT_IP_HEADERS 순서대로 헤더를 확인한다.
+//     - X-Forwarded-For / Forwarded : 오른쪽(가장 가까운 hop)부터 거슬러 올라가며
+//       처음 만나는 "신뢰하지 않는" 주소를 클라이언트로 본다.
+//       (왼쪽 값은 클라이언트가 위조할 수 있으므로 첫 번째 값을 믿지 않는다)
+//     - X-Real-IP / CloudFront-Viewer-Address : 프록시가 단일 값으로 덮어쓰는 헤더
+//       (프록시가 항상 덮어쓰는 구성에서만 CLIENT_IP_HEADERS 에 넣어야 한다)
+//  3. 어떤 헤더에서도 얻지 못하면 빈 값을 반환한다.
+//     RemoteAddr 는 프록시(ALB) 자신의 주소이므로 클라이언트 IP 로 기록하지 않는다.
 // ------------------------------------------------------------
 
-// isPublicIP:
-//   - private / loopback / link-local 등이 아닌 경우 true
-//   - X-Forwarded-For에서 내부 IP를 제외하기 위해 필요
-func isPublicIP(ip net.IP) bool {
-	if ip == nil {
-		return false
+// ipSource 는 클라이언트 IP 를 어디에서 얻었는지를 나타낸다 (metrics / debug 로그용).
+type ipSource int
+
+const (
+	ipSourceNone ipSource = iota
+	ipSourceRemoteAddr
+	ipSourceXFF
+	ipSourceForwarded
+	ipSourceXRealIP
+	ipSourceCloudFront
+)
+
+func (s ipSource) String() string {
+	switch s {
+	case ipSourceRemoteAddr:
+		return "remote_addr"
+	case ipSourceXFF:
+		return "x_forwarded_for"
+	case ipSourceForwarded:
+		return "forwarded"
+	case ipSourceXRealIP:
+		return "x_real_ip"
+	case ipSourceCloudFront:
+		return "cloudfront"
+	default:
+		return "none"
 	}
-	// IPv4 private ranges: 10/8, 172.16/12, 192.168/16
-	if ip.IsPrivate() {
-		return false
+}
+
+// headerSources 는 CLIENT_IP_HEADERS 에 허용되는 헤더 이름(정규화)과 source 의 매핑이다.
+// config.Load 는 config.ClientIPHeaderNames 로 같은 목록을 검증한다.
+var headerSources = map[string]ipSource{
+	"X-Forwarded-For":           ipSourceXFF,
+	"Forwarded":                 ipSourceForwarded,
+	"X-Real-Ip":                 ipSourceXRealIP,
+	"Cloudfront-Viewer-Address": ipSourceCloudFront,
+}
+
+// ipResolver 는 신뢰 프록시 목록과 헤더 우선순위를 담는다.
+// NewHandler 에서 한 번 만들어지며 이후 불변이다.
+type ipResolver struct {
+	trusted []*net.IPNet
+	headers []string // canonical header 이름, 우선순위 순
+}
+
+// newIPResolver 는 cfg 로부터 ipResolver 를 만든다.
+// 값은 config.Load 에서 이미 검증되었으므로 파싱 실패한 항목은 무시한다.
+func newIPResolver(cfg config.Config) *ipResolver {
+	r := &ipResolver{}
+	for _, c := range cfg.TrustedProxyCIDRs {
+		if _, n, err := net.ParseCIDR(c); err == nil {
+			r.trusted = append(r.trusted, n)
+		}
 	}
-	// Loopback, link-local 등 제외
-	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-		return false
+	for _, h := range cfg.ClientIPHeaders {
+		h = http.CanonicalHeaderKey(h)
+		if _, ok := headerSources[h]; ok {
+			r.headers = append(r.headers, h)
+		}
 	}
-	return true
+	return r
+}
+
+// isTrusted 는 ip 가 신뢰 프록시 CIDR 에 속하는지 확인한다.
+func (res *ipResolver) isTrusted(ip net.IP) bool {
+	for _, n := range res.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve 는 클라이언트 IP 와 그 출처를 반환한다.
+func (res *ipResolver) resolve(r *http.Request) (string, ipSource) {
+	remote := parseRemoteAddr(r.RemoteAddr)
+	if remote == nil {
+		return "", ipSourceNone
+	}
+
+	// 신뢰하지 않는 peer 가 보낸 헤더는 위조 가능 → RemoteAddr 만 사용
+	if !res.isTrusted(remote) {
+		return remote.String(), ipSourceRemoteAddr
+	}
+
+	for _, h := range res.headers {
+		values := r.Header.Values(h)
+		if len(values) == 0 {
+			continue
+		}
+
+		src := headerSources[h]
+		var ip net.IP
+		switch src {
+		case ipSourceXFF:
+			ip = res.walk(xffHops(values))
+		case ipSourceForwarded:
+			ip = res.walk(forwardedHops(values))
+		case ipSourceXRealIP:
+			ip = safeParseIP(values[0])
+		case ipSourceCloudFront:
+			ip = parseHostPort(values[0])
+		}
+
+		if ip != nil {
+			return ip.String(), src
+		}
+	}
+
+	// 신뢰 프록시 자신의 주소는 클라이언트 IP 가 아니다
+	return "", ipSourceNone
+}
+
+// walk 는 hop 목록(왼쪽=클라이언트, 오른쪽=가장 가까운 프록시)을 오른쪽부터 확인하여
+// 처음 만나는 신뢰하지 않는 주소를 반환한다.
+//
+//   - 파싱할 수 없는 hop(예: Forwarded 의 "unknown", "_hidden")을 만나면 더 이상 거슬러 올라가지 않는다.
+//     그 hop 너머의 값은 누가 붙였는지 알 수 없기 때문이다.
+//   - 모든 hop 이 신뢰 프록시면 가장 왼쪽 주소를 반환한다.
+func (res *ipResolver) walk(hops []string) net.IP {
+	var last net.IP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := safeParseIP(hops[i])
+		if ip == nil {
+			return last
+		}
+		if !res.isTrusted(ip) {
+			return ip
+		}
+		last = ip
+	}
+	return last
+}
+
+// xffHops 는 X-Forwarded-For 헤더(여러 줄 가능)를 hop 목록으로 펼친다.
+// 예: "203.0.113.1, 10.0.1.24"
+func xffHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	return hops
+}
+
+// forwardedHops 는 RFC 7239 Forwarded 헤더에서 for= 값만 hop 목록으로 추출한다.
+// 예: `for=192.0.2.60;proto=http;by=203.0.113.43, for="[2001:db8:cafe::17]:4711"`
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			node := ""
+			for _, pair := range strings.Split(elem, ";") {
+				k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(k, "for") {
+					node = forwardedNode(val)
+					break
+				}
+			}
+			hops = append(hops, node)
+		}
+	}
+	return hops
+}
+
+// forwardedNode 는 Forwarded 의 node 값에서 따옴표, IPv6 대괄호, 포트를 제거한다.
+//
+//	192.0.2.43            → 192.0.2.43
+//	"192.0.2.43:47011"    → 192.0.2.43
+//	"[2001:db8:cafe::17]" → 2001:db8:cafe::17
+func forwardedNode(v string) string {
+	v = strings.Trim(strings.TrimSpace(v), `"`)
+	if strings.HasPrefix(v, "[") {
+		if i := strings.IndexByte(v, ']'); i > 0 {
+			return v[1:i]
+		}
+		return ""
+	}
+	if i := strings.IndexByte(v, ':'); i >= 0 {
+		return v[:i]
+	}
+	return v
+}
+
+// parseHostPort 는 "ip:port" 에서 IP 를 파싱한다.
+// CloudFront-Viewer-Address 는 IPv6 도 대괄호 없이 "2404:6800:4004::200e:44321" 형태이므로
+// 마지막 ":" 를 기준으로 포트를 제거한다.
+func parseHostPort(s string) net.IP {
+	host := s
+	if i := strings.LastIndex(s, ":"); i != -1 {
+		host = s[:i]
+	}
+	return safeParseIP(host)
+}
+
+// parseRemoteAddr 는 http.Request.RemoteAddr("ip:port")에서 IP 를 파싱한다.
+func parseRemoteAddr(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return safeParseIP(addr)
+	}
+	return safeParseIP(host)
 }
 
 // safeParseIP:
@@ -44,53 +245,39 @@ func safeParseIP(s string) net.IP {
 	return net.ParseIP(s)
 }
 
-// ------------------------------------------------------------
-// clientIP:
+// clientIP
 //
-// "실제 사용자(브라우저)의 IP"를 추출.
-// 우선순위:
-//  1. X-Forwarded-For → 첫 번째 public IP
-//  2. CloudFront-Viewer-Address → 포트 제거 후 IP 사용
-//  3. RemoteAddr fallback
-//
-// ALB 뒤에 있는 ECS 환경에서 가장 표준적인 구현.
-// ------------------------------------------------------------
-func clientIP(r *http.Request) string {
-
-	// 1) X-Forwarded-For (ALB)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// 예: "203.0.113.1, 10.0.1.24"
-		parts := strings.Split(xff, ",")
-		for _, part := range parts {
-			ip := safeParseIP(part)
-			if isPublicIP(ip) {
-				return ip.String()
-			}
-		}
-	}
+// "실제 사용자(브라우저)의 IP"를 추출하고 IP_ANON_MODE 에 따라 익명화한다.
+// GeoIP 조회는 익명화 전의 원본 IP 로 수행해 함께 반환한다.
+// 출처별 카운터를 증가시키고, 출처는 debug 레벨 로그로 남긴다 (LOG_LEVEL=debug 에서만 비용 발생).
+// 로그에도 익명화된 값만 남긴다 (원본 IP 는 어디에도 기록하지 않는다).
+// 원본 IP 는 rate limit key 계산용으로만 함께 반환한다.
+func (h *Handler) clientIP(r *http.Request) (string, anonIP, geoInfo) {
+	ip, src := h.ips.resolve(r)
 
-	// 2) CloudFront-Viewer-Address
-	// 예: "203.0.113.55:44321" 또는 "2404:6800:4004::200e:44321"
-	if cf := r.Header.Get("CloudFront-Viewer-Address"); cf != "" {
-		host := cf
-		// 마지막 ":" 를 기준으로 포트 제거 (IPv6 포함 대응)
-		if i := strings.LastIndex(cf, ":"); i != -1 {
-			host = cf[:i]
-		}
-		ip := safeParseIP(host)
-		if isPublicIP(ip) {
-			return ip.String()
-		}
+	switch src {
+	case ipSourceRemoteAddr:
+		atomic.AddInt64(&h.metrics.ClientIPFromRemoteAddrTotal, 1)
+	case ipSourceXFF:
+		atomic.AddInt64(&h.metrics.ClientIPFromXFFTotal, 1)
+	case ipSourceForwarded:
+		atomic.AddInt64(&h.metrics.ClientIPFromForwardedTotal, 1)
+	case ipSourceXRealIP:
+		atomic.AddInt64(&h.metrics.ClientIPFromXRealIPTotal, 1)
+	case ipSourceCloudFront:
+		atomic.AddInt64(&h.metrics.ClientIPFromCloudFrontTotal, 1)
+	default:
+		atomic.AddInt64(&h.metrics.ClientIPUnknownTotal, 1)
 	}
 
-	// 3) RemoteAddr fallback
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err == nil {
-		ip := safeParseIP(host)
-		if isPublicIP(ip) {
-			return ip.String()
-		}
-	}
+	geo := h.geo.Lookup(ip)
+	a := h.anon.Apply(ip)
+
+	log.Debug().
+		Str("ip", a.ip).
+		Str("ip_mode", a.mode).
+		Stringer("source", src).
+		Msg("client ip resolved")
 
-	return ""
+	return ip, a, geo
 }
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"estat-ingest/internal/config"
+)
+
+var testTrustedProxies = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "127.0.0.0/8", "::1/128", "fc00::/7"}
+
+func TestIPResolverResolve(t *testing.T) {
+	allHeaders := []string{"X-Forwarded-For", "Forwarded", "X-Real-IP", "CloudFront-Viewer-Address"}
+
+	tests := []struct {
+		name    string
+		headers []string // CLIENT_IP_HEADERS (nil = 기본값과 같은 XFF 만)
+		remote  string
+		set     map[string]string
+		wantIP  string
+		wantSrc ipSource
+	}{
+		{
+			name:    "untrusted peer ignores headers",
+			remote:  "198.51.100.7:5555",
+			set:     map[string]string{"X-Forwarded-For": "203.0.113.1"},
+			wantIP:  "198.51.100.7",
+			wantSrc: ipSourceRemoteAddr,
+		},
+		{
+			name:    "alb appends client to xff",
+			remote:  "10.0.1.24:5555",
+			set:     map[string]string{"X-Forwarded-For": "203.0.113.1"},
+			wantIP:  "203.0.113.1",
+			wantSrc: ipSourceXFF,
+		},
+		{
+			name:    "spoofed leftmost xff is ignored",
+			remote:  "10.0.1.24:5555",
+			set:     map[string]string{"X-Forwarded-For": "1.1.1.1, 203.0.113.1"},
+			wantIP:  "203.0.113.1",
+			wantSrc: ipSourceXFF,
+		},
+		{
+			name:    "spoofed private xff is skipped over",
+			remote:  "10.0.1.24:5555",
+			set:     map[string]string{"X-Forwarded-For": "203.0.113.1, 10.9.9.9"},
+			wantIP:  "203.0.113.1",
+			wantSrc: ipSourceXFF,
+		},
+		{
+			name:    "garbage hop stops the walk",
+			remote:  "10.0.1.24:5555",
+			set:     map[string]string{"X-Forwarded-For": "203.0.113.1, bogus, 10.0.0.3"},
+			wantIP:  "10.0.0.3",
+			wantSrc: ipSourceXFF,
+		},
+		{
+			name:    "trusted peer without header records nothing",
+			remote:  "10.0.1.24:5555",
+			wantIP:  "",
+			wantSrc: ipSourceNone,
+		},
+		{
+			name:    "forwarded not consulted by default",
+			remote:  "10.0.1.24:5555",
+			set:     map[string]string{"Forwarded": "for=203.0.113.9"},
+			wantIP:  "",
+			wantSrc: ipSourceNone,
+		},
+		{
+			name:    "cloudfront viewer address not consulted by default",
+			remote:  "10.0.1.24:5555",
+			set:     map[string]string{"CloudFront-Viewer-Address": "1.1.1.1:443", "X-Forwarded-For": "203.0.113.1"},
+			wantIP:  "203.0.113.1",
+			wantSrc: ipSourceXFF,
+		},
+		{
+			name:    "forwarded with quoted ipv6 and port",
+			headers: []string{"Forwarded"},
+			remote:  "10.0.1.24:5555",
+			set:     map[string]string{"Forwarded": `for=1.1.1.1, for="[2001:db8:cafe::17]:4711";proto=https`},
+			wantIP:  "2001:db8:cafe::17",
+			wantSrc: ipSourceForwarded,
+		},
+		{
+			name:    "forwarded obfuscated node stops the walk",
+			headers: []string{"Forwarded"},
+			remote:  "10.0.1.24:5555",
+			set:     map[string]string{"Forwarded": "for=203.0.113.9, for=_hidden"},
+			wantIP:  "",
+			wantSrc: ipSourceNone,
+		},
+		{
+			name:    "cloudfront in front of alb",
+			headers: []string{"CloudFront-Viewer-Address", "X-Forwarded-For"},
+			remote:  "10.0.1.24:5555",
+			set: map[string]string{
+				"CloudFront-Viewer-Address": "2404:6800:4004::200e:44321",
+				"X-Forwarded-For":           "2404:6800:4004::200e, 130.176.1.1",
+			},
+			wantIP:  "2404:6800:4004::200e",
+			wantSrc: ipSourceCloudFront,
+		},
+		{
+			name:    "header priority follows config",
+			headers: allHeaders,
+			remote:  "10.0.1.24:5555",
+			set:     map[string]string{"X-Real-IP": "198.51.100.1", "X-Forwarded-For": "203.0.113.1"},
+			wantIP:  "203.0.113.1",
+			wantSrc: ipSourceXFF,
+		},
+		{
+			name:    "x-real-ip",
+			headers: allHeaders,
+			remote:  "[::1]:5555",
+			set:     map[string]string{"X-Real-IP": " 198.51.100.1 "},
+			wantIP:  "198.51.100.1",
+			wantSrc: ipSourceXRealIP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := tt.headers
+			if headers == nil {
+				headers = []string{"X-Forwarded-For"}
+			}
+			res := newIPResolver(config.Config{TrustedProxyCIDRs: testTrustedProxies, ClientIPHeaders: headers})
+
+			r := httptest.NewRequest(http.MethodGet, "/collect", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.set {
+				r.Header.Set(k, v)
+			}
+
+			ip, src := res.resolve(r)
+			if ip != tt.wantIP || src != tt.wantSrc {
+				t.Fatalf("resolve = (%q, %s), want (%q, %s)", ip, src, tt.wantIP, tt.wantSrc)
+			}
+		})
+	}
+}
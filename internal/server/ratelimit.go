@@ -0,0 +1,174 @@
+package server
+
+import (
+	"container/list"
+	"hash/maphash"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+)
+
+// ------------------------------------------------------------
+// Client 별 rate limit (RATE_LIMIT_KEY)
+//
+// RATE_LIMIT_KEY(ip | api_key | tenant) 로 고른 key 마다 token bucket 을 두고,
+// 초과 요청은 body 를 읽기 전에 429 + Retry-After 로 거절한다.
+// (EventCh full 503 은 모든 client 를 똑같이 거절하지만, 이쪽은 과다 요청한 client 만 거절한다)
+//
+// 메모리 상한:
+//   - key 는 maphash 값(uint64)으로만 보관한다 (IP / API key 원문을 메모리에 남기지 않음).
+//   - rateLimitShards 개 shard 마다 LRU 이며, shard 당 RATE_LIMIT_MAX_KEYS / rateLimitShards 개를 넘으면
+//     가장 오래 쓰지 않은 key 를 버린다 (rate_limit_keys_evicted_total).
+//   - 버려진 key 는 다음 요청에서 burst 로 다시 시작한다.
+//
+// tenant 별 rate_limit(TENANTS_FILE)과는 별개이며, 둘 다 설정되면 둘 다 적용된다.
+// ------------------------------------------------------------
+
+const rateLimitShards = 16
+
+// tokenBucket 은 초당 rate 개씩 채워지고 최대 burst 개까지 쌓이는 token bucket 이다.
+// 요청 하나가 token 하나를 사용한다.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// take 는 now 시점에 token 하나를 사용한다.
+// token 이 없으면 false 와 다음 token 이 채워질 때까지의 대기 시간을 반환한다.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// writeRateLimited 는 429 와 Retry-After(초, 올림)로 응답한다.
+func writeRateLimited(w http.ResponseWriter, wait time.Duration) {
+	secs := max(int64(math.Ceil(wait.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+// clientLimiter 는 key 별 token bucket 이다. RATE_LIMIT_KEY=off 이면 nil 이다.
+type clientLimiter struct {
+	key        string // ip | api_key | tenant
+	rate       float64
+	burst      int
+	shardLimit int
+
+	seed   maphash.Seed
+	shards [rateLimitShards]limiterShard
+
+	metrics *metrics.Metrics
+	now     func() time.Time
+}
+
+type limiterShard struct {
+	mu  sync.Mutex
+	lru *list.List // front 가 가장 최근. Value 는 *limiterEntry
+	m   map[uint64]*list.Element
+}
+
+type limiterEntry struct {
+	key    uint64
+	bucket *tokenBucket
+}
+
+func newClientLimiter(cfg config.Config, m *metrics.Metrics) *clientLimiter {
+	if cfg.RateLimitKey == "off" || cfg.RateLimitKey == "" {
+		return nil
+	}
+
+	l := &clientLimiter{
+		key:        cfg.RateLimitKey,
+		rate:       cfg.RateLimit,
+		burst:      cfg.RateLimitBurst,
+		shardLimit: max(cfg.RateLimitMaxKeys/rateLimitShards, 1),
+		seed:       maphash.MakeSeed(),
+		metrics:    m,
+		now:        time.Now,
+	}
+	for i := range l.shards {
+		l.shards[i].lru = list.New()
+		l.shards[i].m = make(map[uint64]*list.Element)
+	}
+	return l
+}
+
+// allow 는 key 의 token 하나를 사용한다. 부족하면 false 와 대기 시간을 반환한다.
+func (l *clientLimiter) allow(key string) (bool, time.Duration) {
+	h := maphash.String(l.seed, key)
+	sh := &l.shards[h%rateLimitShards]
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	var b *tokenBucket
+	if e, ok := sh.m[h]; ok {
+		sh.lru.MoveToFront(e)
+		b = e.Value.(*limiterEntry).bucket
+	} else {
+		if sh.lru.Len() >= l.shardLimit {
+			oldest := sh.lru.Back()
+			sh.lru.Remove(oldest)
+			delete(sh.m, oldest.Value.(*limiterEntry).key)
+			atomic.AddInt64(&l.metrics.RateLimitKeysEvictedTotal, 1)
+		}
+		b = newTokenBucket(l.rate, l.burst)
+		sh.m[h] = sh.lru.PushFront(&limiterEntry{key: h, bucket: b})
+	}
+	return b.take(l.now())
+}
+
+// limitClient 는 RATE_LIMIT_KEY 의 key 로 rate limit 을 적용한다.
+// 초과한 경우 429 로 응답하고 false 를 반환한다.
+func (h *Handler) limitClient(w http.ResponseWriter, r *http.Request, meta reqMeta, ts *tenantState) bool {
+	l := h.limiter
+	if l == nil {
+		return true
+	}
+
+	// key 종류별로 prefix 를 붙여, API key 가 없어 IP 로 센 요청과 섞이지 않게 한다.
+	var key string
+	switch l.key {
+	case "ip":
+		key = "ip:" + meta.rawIP
+	case "api_key":
+		if k := r.Header.Get(h.cfg.APIKeyHeader); k != "" {
+			key = "key:" + k
+		} else {
+			key = "ip:" + meta.rawIP
+		}
+	case "tenant":
+		key = "tenant:" + ts.tenantID()
+	}
+
+	if ok, wait := l.allow(key); !ok {
+		atomic.AddInt64(&h.metrics.HTTPRequestsRejectedRateLimitedTotal, 1)
+		writeRateLimited(w, wait)
+		return false
+	}
+	return true
+}
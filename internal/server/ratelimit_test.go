@@ -0,0 +1,96 @@
+package server
+
+import (
+	"hash/maphash"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+	"estat-ingest/internal/worker"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(2, 1)
+	now := time.Unix(0, 0)
+	if ok, _ := b.take(now); !ok {
+		t.Fatal("first take failed")
+	}
+	ok, wait := b.take(now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("second take = %v, %v", ok, wait)
+	}
+	if ok, _ := b.take(now.Add(500 * time.Millisecond)); !ok {
+		t.Fatal("take after refill failed")
+	}
+	// burst 이상으로는 쌓이지 않는다
+	now = now.Add(time.Hour)
+	b.take(now)
+	if ok, _ := b.take(now); ok {
+		t.Fatal("bucket exceeded burst")
+	}
+}
+
+func TestClientLimiterEvictsLRU(t *testing.T) {
+	m := metrics.New()
+	l := newClientLimiter(config.Config{RateLimitKey: "ip", RateLimit: 1, RateLimitBurst: 1, RateLimitMaxKeys: rateLimitShards}, m)
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+
+	if ok, _ := l.allow("a"); !ok {
+		t.Fatal("first request of a rejected")
+	}
+	if ok, _ := l.allow("a"); ok {
+		t.Fatal("second request of a allowed")
+	}
+
+	// shard 당 1개이므로, a 와 같은 shard 의 다른 key 가 들어오면 a 는 버려진다.
+	shard := func(key string) uint64 { return maphash.String(l.seed, key) % rateLimitShards }
+	other := "b"
+	for shard(other) != shard("a") {
+		other += "b"
+	}
+	l.allow(other)
+	if m.RateLimitKeysEvictedTotal != 1 {
+		t.Fatalf("evicted = %d, want 1", m.RateLimitKeysEvictedTotal)
+	}
+	if ok, _ := l.allow("a"); !ok {
+		t.Fatal("evicted key should restart with a full burst")
+	}
+}
+
+// 요청 IP 별로 따로 세며, 429(rate limit)와 503(queue full)은 다른 카운터로 센다.
+func TestHandleCollectRateLimitedByIP(t *testing.T) {
+	cfg := config.Config{MaxBodySize: 1024, RateLimitKey: "ip", RateLimit: 1, RateLimitBurst: 1, RateLimitMaxKeys: 100}
+	m := metrics.New()
+	w := &worker.Manager{EventCh: make(chan *model.Event, 1)}
+	h := &Handler{cfg: cfg, metrics: m, worker: w, ips: newIPResolver(cfg), anon: newIPAnonymizer(cfg), limiter: newClientLimiter(cfg, m)}
+	h.limiter.now = func() time.Time { return time.Unix(0, 0) }
+
+	do := func(ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/collect?e=pv", nil)
+		r.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		h.HandleCollect(rec, r)
+		return rec
+	}
+
+	if rec := do("203.0.113.1"); rec.Code != http.StatusOK {
+		t.Fatalf("first: status %d", rec.Code)
+	}
+	rec := do("203.0.113.1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("second: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// 다른 IP 는 영향을 받지 않지만 EventCh(1) 가 가득 차 503
+	if rec := do("203.0.113.2"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("other ip: status %d", rec.Code)
+	}
+
+	if m.HTTPRequestsRejectedRateLimitedTotal != 1 || m.HTTPRequestsRejectedQueueFullTotal != 1 {
+		t.Fatalf("rate_limited=%d queue_full=%d", m.HTTPRequestsRejectedRateLimitedTotal, m.HTTPRequestsRejectedQueueFullTotal)
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Routes 는 설정에 맞게 엔드포인트를 등록한 mux 를 반환한다.
+//
+//   - /collect/{tenant}, /collect/{tenant}/batch : TENANTS_FILE 설정 시에만
+//   - BEACON_PATH(, BEACON_PATH/{tenant})        : 설정 시에만. 인증 없이 raw 버킷에 쓰므로 opt-in 이다.
+func (h *Handler) Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/collect", h.HandleCollect)
+	mux.HandleFunc("/collect/batch", h.HandleCollectBatch)
+	if h.cfg.Tenants != nil {
+		mux.HandleFunc("/collect/{tenant}", h.HandleCollect)
+		mux.HandleFunc("/collect/{tenant}/batch", h.HandleCollectBatch)
+	}
+	if h.cfg.BeaconPath != "" {
+		mux.HandleFunc(h.cfg.BeaconPath, h.HandleBeacon)
+		if h.cfg.Tenants != nil {
+			mux.HandleFunc(strings.TrimSuffix(h.cfg.BeaconPath, "/")+"/{tenant}", h.HandleBeacon)
+		}
+	}
+	mux.HandleFunc("/metrics", h.HandleMetrics)
+	mux.HandleFunc("/health", h.HandleLive)
+	mux.HandleFunc("/health/live", h.HandleLive)
+	mux.HandleFunc("/health/ready", h.HandleReady)
+	return mux
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/tenant"
+)
+
+// ------------------------------------------------------------
+// Multi-tenant 판정 (TENANTS_FILE)
+//
+// 요청마다 TENANT_SOURCES 순서대로 tenant 를 판정한다.
+//
+//	path    : /collect/{tenant}, /collect/{tenant}/batch
+//	header  : TENANT_HEADER (기본 X-Tenant-ID)
+//	api_key : API_KEY_HEADER (기본 X-API-Key) 값을 가진 tenant
+//
+// 값이 있는 첫 번째 방식의 결과를 사용하며 (뒤쪽 방식으로 넘어가지 않음),
+// 아무 값도 없으면 TENANT_DEFAULT 를 사용한다.
+//
+// 응답:
+//   - 판정 실패 / 테이블에 없는 tenant → 404 (tenant_unknown_total)
+//   - enabled=false                    → 404 (tenant_disabled_total)
+//   - rate_limit 초과                  → 429 + Retry-After (tenant_requests_rate_limited_total)
+//
+// 판정은 body 를 읽기 전에 하므로, 거절된 요청은 tenant 의 body 크기 제한과 무관하게 싸다.
+// ------------------------------------------------------------
+
+// tenantState 는 tenant 하나의 요청 처리 상태(rate limiter, 카운터)이다.
+type tenantState struct {
+	t       *tenant.Tenant
+	limiter *tokenBucket // rate_limit 미설정 시 nil
+	stat    *metrics.TenantStat
+}
+
+// tenantResolver 는 요청의 tenant 를 판정한다. TENANTS_FILE 미설정 시 nil 이다.
+type tenantResolver struct {
+	sources      []string
+	header       string
+	apiKeyHeader string
+	def          string
+	table        *tenant.Table
+	states       map[string]*tenantState
+	metrics      *metrics.Metrics
+
+	now func() time.Time
+}
+
+func newTenantResolver(cfg config.Config, m *metrics.Metrics) *tenantResolver {
+	if cfg.Tenants == nil {
+		return nil
+	}
+
+	tr := &tenantResolver{
+		sources:      cfg.TenantSources,
+		header:       cfg.TenantHeader,
+		apiKeyHeader: cfg.APIKeyHeader,
+		def:          cfg.TenantDefault,
+		table:        cfg.Tenants,
+		states:       make(map[string]*tenantState),
+		metrics:      m,
+		now:          time.Now,
+	}
+	stats := m.InitTenants(cfg.Tenants.IDs())
+	for i, t := range cfg.Tenants.All() {
+		st := &tenantState{t: t, stat: &stats[i]}
+		if t.RateLimit > 0 {
+			st.limiter = newTokenBucket(t.RateLimit, t.RateBurst)
+		}
+		tr.states[t.ID] = st
+	}
+	return tr
+}
+
+// lookup 은 TENANT_SOURCES 순서대로 tenant id 를 찾는다. (테이블 존재 여부는 확인하지 않는다)
+func (tr *tenantResolver) lookup(r *http.Request) string {
+	for _, src := range tr.sources {
+		switch src {
+		case "path":
+			if id := r.PathValue("tenant"); id != "" {
+				return id
+			}
+		case "header":
+			if id := r.Header.Get(tr.header); id != "" {
+				return id
+			}
+		case "api_key":
+			key := r.Header.Get(tr.apiKeyHeader)
+			if key == "" {
+				continue
+			}
+			if t, ok := tr.table.ByAPIKey(key); ok {
+				return t.ID
+			}
+			return "" // 알 수 없는 key 는 TENANT_DEFAULT 로 넘어가지 않는다
+		}
+	}
+	return tr.def
+}
+
+// admitTenant 는 요청의 tenant 를 판정하고 rate limit 을 적용한다.
+// 거절한 경우 응답을 쓰고 false 를 반환한다. TENANTS_FILE 미설정 시 (nil, true) 이다.
+func (h *Handler) admitTenant(w http.ResponseWriter, r *http.Request) (*tenantState, bool) {
+	tr := h.tenants
+	if tr == nil {
+		return nil, true
+	}
+
+	st := tr.states[tr.lookup(r)]
+	switch {
+	case st == nil:
+		atomic.AddInt64(&h.metrics.TenantUnknownTotal, 1)
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	case !st.t.IsEnabled():
+		atomic.AddInt64(&h.metrics.TenantDisabledTotal, 1)
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+
+	if st.limiter != nil {
+		if ok, wait := st.limiter.take(tr.now()); !ok {
+			atomic.AddInt64(&st.stat.RateLimited, 1)
+			writeRateLimited(w, wait)
+			return nil, false
+		}
+	}
+	return st, true
+}
+
+// tenantID 는 Event.Tenant 에 기록할 값이다 (single-tenant 면 빈 값).
+func (st *tenantState) tenantID() string {
+	if st == nil {
+		return ""
+	}
+	return st.t.ID
+}
+
+// maxBodySize 는 tenant 의 max_body_size, single-tenant 면 MAX_BODY_SIZE 이다.
+func (h *Handler) maxBodySize(st *tenantState) int64 {
+	if st == nil {
+		return h.cfg.MaxBodySize
+	}
+	return st.t.MaxBodySize
+}
+
+// countAccepted 는 tenant 별 enqueue 이벤트 수를 더한다.
+func (st *tenantState) countAccepted(n int) {
+	if st != nil && n > 0 {
+		atomic.AddInt64(&st.stat.Accepted, int64(n))
+	}
+}
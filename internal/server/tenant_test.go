@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+	"estat-ingest/internal/tenant"
+	"estat-ingest/internal/worker"
+)
+
+func newTenantTestHandler(t *testing.T) (*Handler, *worker.Manager) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(`{"tenants": [
+		{"id": "shop", "max_body_size": 16, "rate_limit": 1, "rate_burst": 2, "api_keys": ["shop-key"]},
+		{"id": "blog", "enabled": false}
+	]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tb, err := tenant.Load(path, tenant.Defaults{Prefix: "raw", MaxBodySize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{
+		MaxBodySize:   1024,
+		TenantSources: []string{"path", "header", "api_key"},
+		TenantHeader:  "X-Tenant-ID",
+		APIKeyHeader:  "X-API-Key",
+		Tenants:       tb,
+	}
+	w := &worker.Manager{EventCh: make(chan *model.Event, 10)}
+	m := metrics.New()
+	h := &Handler{cfg: cfg, metrics: m, worker: w, ips: newIPResolver(cfg), anon: newIPAnonymizer(cfg), tenants: newTenantResolver(cfg, m)}
+	return h, w
+}
+
+func TestHandleCollectTenant(t *testing.T) {
+	h, w := newTenantTestHandler(t)
+	now := time.Unix(1000, 0)
+	h.tenants.now = func() time.Time { return now }
+
+	do := func(pathTenant string, hdr map[string]string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/collect", strings.NewReader(body))
+		if pathTenant != "" {
+			r.SetPathValue("tenant", pathTenant)
+		}
+		for k, v := range hdr {
+			r.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.HandleCollect(rec, r)
+		return rec
+	}
+
+	if rec := do("shop", nil, "a=1"); rec.Code != http.StatusOK {
+		t.Fatalf("path tenant: status %d", rec.Code)
+	}
+	if ev := <-w.EventCh; ev.Tenant != "shop" {
+		t.Fatalf("ev.Tenant = %q", ev.Tenant)
+	}
+	if rec := do("", map[string]string{"X-API-Key": "shop-key"}, "a=2"); rec.Code != http.StatusOK {
+		t.Fatalf("api key tenant: status %d", rec.Code)
+	}
+	<-w.EventCh
+
+	// burst 2 를 모두 사용 → 429 + Retry-After
+	rec := do("", map[string]string{"X-Tenant-ID": "shop"}, "a=3")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("rate limited: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// 1초 뒤 token 1개 충전. tenant 의 max_body_size(16) 가 적용된다.
+	now = now.Add(time.Second)
+	if rec := do("shop", nil, strings.Repeat("x", 32)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("tenant max body: status %d", rec.Code)
+	}
+
+	if rec := do("nope", nil, "a=1"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown tenant: status %d", rec.Code)
+	}
+	if rec := do("", map[string]string{"X-API-Key": "bad"}, "a=1"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown api key: status %d", rec.Code)
+	}
+	if rec := do("", nil, "a=1"); rec.Code != http.StatusNotFound {
+		t.Fatalf("no tenant: status %d", rec.Code)
+	}
+	if rec := do("blog", nil, "a=1"); rec.Code != http.StatusNotFound {
+		t.Fatalf("disabled tenant: status %d", rec.Code)
+	}
+
+	m := h.metrics
+	if m.TenantUnknownTotal != 3 || m.TenantDisabledTotal != 1 {
+		t.Fatalf("unknown=%d disabled=%d", m.TenantUnknownTotal, m.TenantDisabledTotal)
+	}
+	if st := m.TenantStats[0]; st.Name != "shop" || st.Accepted != 2 || st.RateLimited != 1 {
+		t.Fatalf("shop stat = %+v", st)
+	}
+}
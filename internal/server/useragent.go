@@ -0,0 +1,246 @@
+package server
+
+import (
+	"fmt"
+	"hash/maphash"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+// ------------------------------------------------------------
+// User-Agent 파싱 / 봇 분류
+//
+// UA_RULES_FILE(JSON)의 정규식 규칙으로 User-Agent 를 분류하여
+// Event 에 ua_browser / ua_os / ua_device / is_bot 등을 붙인다.
+//
+// 규칙 파일 형식 (예시는 docs/ua_rules.example.json):
+//
+//	{
+//	  "bots":     [{"name": "Googlebot", "regex": "Googlebot"}],
+//	  "browsers": [{"name": "Chrome", "regex": "Chrome/([0-9.]+)"}],
+//	  "os":       [{"name": "Android", "regex": "Android ([0-9.]+)"}],
+//	  "devices":  [{"name": "mobile", "regex": "Mobi|iPhone"}]
+//	}
+//
+//   - 카테고리마다 위에서부터 처음 일치하는 규칙 하나만 사용한다 (순서가 우선순위).
+//   - 첫 번째 capture group 이 있으면 버전으로 기록한다.
+//   - devices 에 일치하는 규칙이 없으면 "desktop", 봇이면 "bot" 이다.
+//
+// 봇 처리 정책 (UA_BOT_POLICY):
+//
+//	tag   : is_bot 만 표시하고 RAW prefix 에 함께 저장 (기본값)
+//	route : 봇 이벤트는 BOT_PREFIX 로 분리 저장 (업로드 worker 가 배치를 나눈다)
+//	drop  : 봇 요청은 enqueue 하지 않고 204 (ua_bot_requests_dropped_total)
+//
+// 정규식 매칭은 요청마다 하기엔 비싸므로, 결과를 UA 문자열 기준으로 캐시한다.
+// 캐시는 shard 별 상한(UA_CACHE_SIZE / uaCacheShards)에 도달하면 비우고 다시 채운다.
+// UA 는 앞 uaMaxLen 바이트만 분류 / 캐시 key 에 사용한다 (긴 UA 로 캐시 메모리와 정규식 비용이 커지지 않도록).
+// ------------------------------------------------------------
+
+const (
+	uaCacheShards = 16
+	uaMaxLen      = 512
+)
+
+// uaInfo 는 UA 파싱 결과이다.
+type uaInfo struct {
+	browser        string
+	browserVersion string
+	os             string
+	osVersion      string
+	device         string
+	bot            string // 일치한 bot 규칙 이름
+	isBot          bool
+}
+
+// uaRulesFile 은 UA_RULES_FILE 의 JSON 구조이다.
+type uaRulesFile struct {
+	Bots     []uaRuleSpec `json:"bots"`
+	Browsers []uaRuleSpec `json:"browsers"`
+	OS       []uaRuleSpec `json:"os"`
+	Devices  []uaRuleSpec `json:"devices"`
+}
+
+type uaRuleSpec struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+}
+
+// uaRule 은 컴파일된 규칙이다.
+type uaRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// match 는 ua 가 규칙에 일치하는지와 버전(첫 번째 capture group)을 반환한다.
+func (r uaRule) match(ua string) (bool, string) {
+	if r.re.NumSubexp() == 0 {
+		return r.re.MatchString(ua), ""
+	}
+	m := r.re.FindStringSubmatch(ua)
+	if m == nil {
+		return false, ""
+	}
+	return true, m[1]
+}
+
+// uaParser 는 UA 규칙과 결과 캐시이다. UA_RULES_FILE 미설정 시 nil 이며, Parse 는 빈 값을 반환한다.
+type uaParser struct {
+	bots, browsers, oses, devices []uaRule
+
+	metrics *metrics.Metrics
+
+	seed       maphash.Seed
+	shards     [uaCacheShards]uaCacheShard
+	shardLimit int
+}
+
+type uaCacheShard struct {
+	mu sync.Mutex
+	m  map[string]uaInfo
+}
+
+// newUAParser 는 UA_RULES_FILE 을 읽어 uaParser 를 만든다.
+// 파일은 config.Load 에서 존재 여부가 검증되었으며, 형식 오류는 즉시 종료한다.
+func newUAParser(cfg config.Config, m *metrics.Metrics) *uaParser {
+	if cfg.UARulesFile == "" {
+		return nil
+	}
+
+	p, err := loadUAParser(cfg.UARulesFile, cfg.UACacheSize)
+	if err != nil {
+		log.Fatal().Err(err).Str("path", cfg.UARulesFile).Msg("failed to load UA rules")
+	}
+	p.metrics = m
+
+	log.Info().
+		Str("path", cfg.UARulesFile).
+		Int("bots", len(p.bots)).
+		Int("browsers", len(p.browsers)).
+		Int("os", len(p.oses)).
+		Int("devices", len(p.devices)).
+		Str("bot_policy", cfg.UABotPolicy).
+		Msg("UA rules loaded")
+	return p
+}
+
+// loadUAParser 는 규칙 파일을 읽고 정규식을 컴파일한다.
+func loadUAParser(path string, cacheSize int) (*uaParser, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f uaRulesFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+
+	p := &uaParser{
+		seed:       maphash.MakeSeed(),
+		shardLimit: max(cacheSize/uaCacheShards, 1),
+	}
+	for _, c := range []struct {
+		name  string
+		specs []uaRuleSpec
+		dst   *[]uaRule
+	}{
+		{"bots", f.Bots, &p.bots},
+		{"browsers", f.Browsers, &p.browsers},
+		{"os", f.OS, &p.oses},
+		{"devices", f.Devices, &p.devices},
+	} {
+		for i, s := range c.specs {
+			if s.Name == "" {
+				return nil, fmt.Errorf("%s[%d]: empty name", c.name, i)
+			}
+			re, err := regexp.Compile(s.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d] %q: %w", c.name, i, s.Name, err)
+			}
+			*c.dst = append(*c.dst, uaRule{name: s.Name, re: re})
+		}
+	}
+	for i := range p.shards {
+		p.shards[i].m = make(map[string]uaInfo)
+	}
+	return p, nil
+}
+
+// Parse 는 ua 를 분류한다. 봇으로 판정되면 ua_bot_requests_total 을 증가시킨다.
+func (p *uaParser) Parse(ua string) uaInfo {
+	if p == nil {
+		return uaInfo{}
+	}
+
+	if len(ua) > uaMaxLen {
+		// 잘라낸 부분 문자열이 원래 헤더 전체를 캐시에 붙잡아 두지 않도록 복사한다.
+		ua = strings.Clone(ua[:uaMaxLen])
+	}
+
+	sh := &p.shards[maphash.String(p.seed, ua)%uaCacheShards]
+	sh.mu.Lock()
+	info, ok := sh.m[ua]
+	sh.mu.Unlock()
+
+	if !ok {
+		info = p.classify(ua)
+		sh.mu.Lock()
+		if len(sh.m) >= p.shardLimit {
+			clear(sh.m)
+		}
+		sh.m[ua] = info
+		sh.mu.Unlock()
+	}
+
+	if info.isBot {
+		atomic.AddInt64(&p.metrics.UABotRequestsTotal, 1)
+	}
+	return info
+}
+
+// classify 는 캐시 없이 규칙을 적용한다.
+func (p *uaParser) classify(ua string) uaInfo {
+	var info uaInfo
+
+	for _, r := range p.bots {
+		if ok, _ := r.match(ua); ok {
+			info.isBot, info.bot = true, r.name
+			break
+		}
+	}
+	for _, r := range p.browsers {
+		if ok, v := r.match(ua); ok {
+			info.browser, info.browserVersion = r.name, v
+			break
+		}
+	}
+	for _, r := range p.oses {
+		if ok, v := r.match(ua); ok {
+			info.os, info.osVersion = r.name, v
+			break
+		}
+	}
+	for _, r := range p.devices {
+		if ok, _ := r.match(ua); ok {
+			info.device = r.name
+			break
+		}
+	}
+
+	switch {
+	case info.isBot:
+		info.device = "bot"
+	case info.device == "" && ua != "":
+		info.device = "desktop"
+	}
+	return info
+}
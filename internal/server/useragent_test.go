@@ -0,0 +1,126 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+)
+
+func TestUAParserExampleRules(t *testing.T) {
+	p, err := loadUAParser("../../docs/ua_rules.example.json", 100)
+	if err != nil {
+		t.Fatalf("example rules: %v", err)
+	}
+	p.metrics = metrics.New()
+
+	tests := []struct {
+		ua   string
+		want uaInfo
+	}{
+		{
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			want: uaInfo{browser: "Chrome", browserVersion: "126.0.0.0", os: "Windows", osVersion: "10.0", device: "desktop"},
+		},
+		{
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.2592.87",
+			want: uaInfo{browser: "Edge", browserVersion: "126.0.2592.87", os: "Windows", osVersion: "10.0", device: "desktop"},
+		},
+		{
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			want: uaInfo{browser: "Safari", browserVersion: "17.5", os: "iOS", osVersion: "17_5", device: "mobile"},
+		},
+		{
+			ua:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want: uaInfo{bot: "Googlebot", isBot: true, device: "bot"},
+		},
+		{
+			ua:   "curl/8.5.0",
+			want: uaInfo{bot: "http-client", isBot: true, device: "bot"},
+		},
+		{
+			ua:   "",
+			want: uaInfo{bot: "generic", isBot: true, device: "bot"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := p.Parse(tt.ua); got != tt.want {
+			t.Errorf("Parse(%q)\n got  %+v\n want %+v", tt.ua, got, tt.want)
+		}
+	}
+	if p.metrics.UABotRequestsTotal != 3 {
+		t.Fatalf("UABotRequestsTotal = %d, want 3", p.metrics.UABotRequestsTotal)
+	}
+}
+
+// 캐시가 상한에 도달하면 비우고 다시 채운다 (메모리 상한).
+func TestUAParserCacheBounded(t *testing.T) {
+	p, err := loadUAParser("../../docs/ua_rules.example.json", uaCacheShards*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.metrics = metrics.New()
+
+	for i := 0; i < 1000; i++ {
+		p.Parse("agent/" + string(rune('a'+i%26)) + string(rune('a'+i/26)))
+	}
+	for i := range p.shards {
+		if n := len(p.shards[i].m); n > p.shardLimit {
+			t.Fatalf("shard %d has %d entries, limit %d", i, n, p.shardLimit)
+		}
+	}
+}
+
+// 긴 UA 는 앞 uaMaxLen 바이트로 분류하며, 그 뒤만 다른 UA 는 캐시 항목 하나를 공유한다.
+func TestUAParserTruncatesLongUA(t *testing.T) {
+	p, err := loadUAParser("../../docs/ua_rules.example.json", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.metrics = metrics.New()
+
+	prefix := "Mozilla/5.0 (compatible; Googlebot/2.1) " + strings.Repeat("x", uaMaxLen)
+	for i := 0; i < 100; i++ {
+		info := p.Parse(prefix + strconv.Itoa(i) + strings.Repeat("y", 64<<10))
+		if !info.isBot || info.bot != "Googlebot" {
+			t.Fatalf("info = %+v, want Googlebot", info)
+		}
+	}
+
+	entries := 0
+	for i := range p.shards {
+		for k := range p.shards[i].m {
+			if len(k) > uaMaxLen {
+				t.Fatalf("cache key has %d bytes, want <= %d", len(k), uaMaxLen)
+			}
+			entries++
+		}
+	}
+	if entries != 1 {
+		t.Fatalf("cache entries = %d, want 1", entries)
+	}
+}
+
+// UA_BOT_POLICY=drop 이면 봇 요청은 enqueue 되지 않고 204 + 카운터.
+func TestHandleCollectDropsBots(t *testing.T) {
+	m := metrics.New()
+	cfg := config.Config{UARulesFile: "../../docs/ua_rules.example.json", UABotPolicy: "drop", UACacheSize: 100, MaxBodySize: 1024}
+	h := &Handler{cfg: cfg, metrics: m, ips: newIPResolver(cfg), anon: newIPAnonymizer(cfg), ua: newUAParser(cfg, m)}
+
+	r := httptest.NewRequest(http.MethodGet, "/collect?e=pv", nil)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (compatible; bingbot/2.0)")
+	w := httptest.NewRecorder()
+	h.HandleCollect(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", w.Code)
+	}
+	if m.UABotRequestsDroppedTotal != 1 || m.HTTPRequestsAcceptedTotal != 0 {
+		t.Fatalf("dropped=%d accepted=%d", m.UABotRequestsDroppedTotal, m.HTTPRequestsAcceptedTotal)
+	}
+}
@@ -0,0 +1,178 @@
+// internal/tenant/tenant.go
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ------------------------------------------------------------
+// Multi-tenant 테이블
+//
+// 하나의 ingest fleet 을 여러 제품 팀(tenant)이 나눠 쓰기 위한 tenant 목록이다.
+// TENANTS_FILE(JSON)에서 읽으며, config.Load 가 기동 시 한 번 로드/검증한다 (fail-fast).
+//
+// 파일 형식 (// 는 설명이며 실제 파일에는 쓸 수 없다):
+//
+//	{
+//	  "tenants": [
+//	    {
+//	      "id": "shop",                 // 필수. [a-z0-9][a-z0-9_-]* (URL path / 지표 label 로 사용)
+//	      "bucket": "estat-shop-raw",   // 비우면 RAW_BUCKET
+//	      "prefix": "shop/raw",         // 비우면 RAW_PREFIX/<id>
+//	      "max_body_size": 32768,       // 비우면 MAX_BODY_SIZE
+//	      "rate_limit": 500,            // 초당 요청 수. 비우면 무제한
+//	      "rate_burst": 1000,           // 비우면 rate_limit 과 같음 (1초치)
+//	      "enabled": true,              // 비우면 true. false 면 404
+//	      "api_keys": ["..."]           // 이 key 로 요청하면 이 tenant 로 판정
+//	    }
+//	  ]
+//	}
+//
+// 한 객체(S3 object)에는 한 tenant 의 이벤트만 들어간다 (collectLoop 가 tenant 별로 배치한다).
+// ------------------------------------------------------------
+
+// idRe 는 tenant id 로 허용하는 문자이다.
+var idRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// reservedIDs 는 같은 위치의 다른 route 와 겹치므로 tenant id 로 쓸 수 없다. (/collect/batch)
+var reservedIDs = map[string]bool{"batch": true}
+
+// Tenant 는 tenant 하나의 설정이다. Load 이후에는 읽기 전용이다.
+type Tenant struct {
+	ID          string   `json:"id"`
+	Bucket      string   `json:"bucket"`
+	Prefix      string   `json:"prefix"`
+	MaxBodySize int64    `json:"max_body_size"`
+	RateLimit   float64  `json:"rate_limit"`
+	RateBurst   int      `json:"rate_burst"`
+	Enabled     *bool    `json:"enabled"`
+	APIKeys     []string `json:"api_keys"`
+}
+
+// IsEnabled 는 enabled 가 생략되었거나 true 이면 true 이다.
+func (t *Tenant) IsEnabled() bool {
+	return t.Enabled == nil || *t.Enabled
+}
+
+// Defaults 는 tenant 항목에서 생략된 값을 채울 전역 설정이다.
+type Defaults struct {
+	Bucket      string // RAW_BUCKET
+	Prefix      string // RAW_PREFIX (tenant prefix 는 <Prefix>/<id>)
+	MaxBodySize int64  // MAX_BODY_SIZE
+}
+
+// Table 은 id / API key 로 tenant 를 찾는 읽기 전용 테이블이다.
+type Table struct {
+	tenants []*Tenant // 파일 순서
+	byID    map[string]*Tenant
+	byKey   map[string]*Tenant
+}
+
+type tableFile struct {
+	Tenants []*Tenant `json:"tenants"`
+}
+
+// Load 는 path 의 tenant 테이블을 읽고 검증한 뒤 생략된 값을 d 로 채운다.
+func Load(path string, d Defaults) (*Table, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var f tableFile
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields() // 오타난 필드가 조용히 무시되지 않도록
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	return newTable(f.Tenants, d)
+}
+
+func newTable(tenants []*Tenant, d Defaults) (*Table, error) {
+	if len(tenants) == 0 {
+		return nil, errors.New("no tenants")
+	}
+
+	t := &Table{
+		tenants: tenants,
+		byID:    make(map[string]*Tenant, len(tenants)),
+		byKey:   make(map[string]*Tenant),
+	}
+	for i, tn := range tenants {
+		switch {
+		case tn == nil:
+			return nil, fmt.Errorf("tenants[%d]: null", i)
+		case !idRe.MatchString(tn.ID):
+			return nil, fmt.Errorf("tenants[%d]: invalid id %q (expected [a-z0-9][a-z0-9_-]*)", i, tn.ID)
+		case reservedIDs[tn.ID]:
+			return nil, fmt.Errorf("tenants[%d]: reserved id %q", i, tn.ID)
+		case t.byID[tn.ID] != nil:
+			return nil, fmt.Errorf("tenants[%d]: duplicate id %q", i, tn.ID)
+		case tn.MaxBodySize < 0 || tn.RateLimit < 0 || tn.RateBurst < 0:
+			return nil, fmt.Errorf("tenant %q: negative limit", tn.ID)
+		}
+		for _, k := range tn.APIKeys {
+			if k == "" {
+				return nil, fmt.Errorf("tenant %q: empty api key", tn.ID)
+			}
+			if other := t.byKey[k]; other != nil {
+				return nil, fmt.Errorf("tenant %q: api key already used by %q", tn.ID, other.ID)
+			}
+			t.byKey[k] = tn
+		}
+
+		if tn.Bucket == "" {
+			tn.Bucket = d.Bucket
+		}
+		if tn.Prefix == "" {
+			tn.Prefix = strings.TrimSuffix(d.Prefix, "/") + "/" + tn.ID
+		}
+		if tn.MaxBodySize == 0 {
+			tn.MaxBodySize = d.MaxBodySize
+		}
+		if tn.RateLimit > 0 && tn.RateBurst == 0 {
+			tn.RateBurst = max(int(tn.RateLimit), 1)
+		}
+		t.byID[tn.ID] = tn
+	}
+	return t, nil
+}
+
+// Get 은 id 의 tenant 를 반환한다. (enabled 여부는 호출자가 확인한다)
+// nil Table(single-tenant)에서는 항상 false 이다.
+func (t *Table) Get(id string) (*Tenant, bool) {
+	if t == nil {
+		return nil, false
+	}
+	tn, ok := t.byID[id]
+	return tn, ok
+}
+
+// ByAPIKey 는 key 를 가진 tenant 를 반환한다.
+func (t *Table) ByAPIKey(key string) (*Tenant, bool) {
+	if t == nil {
+		return nil, false
+	}
+	tn, ok := t.byKey[key]
+	return tn, ok
+}
+
+// All 은 파일 순서대로 모든 tenant 를 반환한다.
+func (t *Table) All() []*Tenant {
+	return t.tenants
+}
+
+// IDs 는 파일 순서대로 모든 tenant id 를 반환한다.
+func (t *Table) IDs() []string {
+	ids := make([]string, len(t.tenants))
+	for i, tn := range t.tenants {
+		ids[i] = tn.ID
+	}
+	return ids
+}
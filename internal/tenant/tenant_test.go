@@ -0,0 +1,80 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(`{"tenants": [
+		{"id": "shop", "bucket": "shop-raw", "prefix": "shop/raw", "max_body_size": 1024, "rate_limit": 10, "api_keys": ["k1"]},
+		{"id": "blog", "rate_limit": 0.5, "enabled": false}
+	]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tb, err := Load(path, Defaults{Bucket: "raw", Prefix: "raw/", MaxBodySize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shop, ok := tb.Get("shop")
+	if !ok || shop.Bucket != "shop-raw" || shop.Prefix != "shop/raw" || shop.MaxBodySize != 1024 || shop.RateBurst != 10 || !shop.IsEnabled() {
+		t.Fatalf("shop = %+v", shop)
+	}
+	blog, ok := tb.Get("blog")
+	if !ok || blog.Bucket != "raw" || blog.Prefix != "raw/blog" || blog.MaxBodySize != 4096 || blog.RateBurst != 1 || blog.IsEnabled() {
+		t.Fatalf("blog = %+v", blog)
+	}
+
+	if got, ok := tb.ByAPIKey("k1"); !ok || got != shop {
+		t.Fatalf("ByAPIKey(k1) = %v, %v", got, ok)
+	}
+	if _, ok := tb.ByAPIKey("nope"); ok {
+		t.Fatal("ByAPIKey(nope) found a tenant")
+	}
+	if ids := tb.IDs(); len(ids) != 2 || ids[0] != "shop" || ids[1] != "blog" {
+		t.Fatalf("IDs = %v", ids)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{"empty", `{"tenants": []}`, "no tenants"},
+		{"unknown field", `{"tenants": [{"id": "a", "bukket": "x"}]}`, "unknown field"},
+		{"bad id", `{"tenants": [{"id": "Shop"}]}`, "invalid id"},
+		{"reserved", `{"tenants": [{"id": "batch"}]}`, "reserved id"},
+		{"duplicate", `{"tenants": [{"id": "a"}, {"id": "a"}]}`, "duplicate id"},
+		{"negative", `{"tenants": [{"id": "a", "rate_limit": -1}]}`, "negative limit"},
+		{"empty key", `{"tenants": [{"id": "a", "api_keys": [""]}]}`, "empty api key"},
+		{"shared key", `{"tenants": [{"id": "a", "api_keys": ["k"]}, {"id": "b", "api_keys": ["k"]}]}`, "already used"},
+		{"null", `{"tenants": [null]}`, "null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tenants.json")
+			if err := os.WriteFile(path, []byte(tt.body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path, Defaults{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilTable(t *testing.T) {
+	var tb *Table
+	if _, ok := tb.Get("a"); ok {
+		t.Fatal("nil Get found a tenant")
+	}
+	if _, ok := tb.ByAPIKey("k"); ok {
+		t.Fatal("nil ByAPIKey found a tenant")
+	}
+}
@@ -0,0 +1,188 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ------------------------------------------------------------
+// S3 circuit breaker
+//
+// S3 장애 중에도 배치마다 전체 재시도(S3AppRetries × S3Timeout)를 거친 뒤 DLQ 로 가고,
+// DLQ 재업로드(ProcessOneCtx)는 50ms 마다 S3 를 두드린다. breaker 는 업로드와 DLQ 재업로드가
+// 공유하며(S3Uploader 의 모든 Put*), 상태는 다음과 같다.
+//
+//   - closed    : 정상. 재시도까지 모두 실패한 업로드가 연속 S3_BREAKER_FAILURES 회이면 open.
+//   - open      : S3 를 호출하지 않고 errS3BreakerOpen 을 반환한다 (배치는 바로 DLQ, 재업로드는 멈춤).
+//   - half-open : S3_BREAKER_COOLDOWN 경과 후 1건만 probe 로 보낸다. 성공하면 closed, 실패하면 다시 open.
+//
+// shutdown 으로 취소된 업로드는 S3 상태와 무관하므로 결과로 치지 않는다 (recordResult 와 같은 기준).
+// non-retryable 오류(AccessDenied, NoSuchBucket 등)는 특정 tenant 의 버킷 / 권한 문제이므로 실패로 세지 않는다.
+// S3 가 응답했다는 뜻이므로 half-open probe 에서는 성공으로 본다.
+// S3_BREAKER_ENABLED=false 이면 nil 이며, 메서드는 항상 허용한다.
+// ------------------------------------------------------------
+
+// errS3BreakerOpen 은 breaker 가 open 이라 S3 를 호출하지 않았음을 나타낸다.
+var errS3BreakerOpen = errors.New("S3 circuit breaker open")
+
+// BreakerState 는 metrics.S3BreakerState gauge 값이다.
+type BreakerState int64
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	metrics   *metrics.Metrics
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int       // closed 상태의 연속 실패 수
+	openedAt time.Time // 마지막 open 전환 시각
+	probing  bool      // half-open probe 진행 중
+}
+
+func newBreaker(cfg config.Config, m *metrics.Metrics) *breaker {
+	if !cfg.S3BreakerEnabled {
+		return nil
+	}
+	return &breaker{
+		threshold: cfg.S3BreakerFailures,
+		cooldown:  cfg.S3BreakerCooldown,
+		metrics:   m,
+		now:       time.Now,
+	}
+}
+
+// allow 는 S3 호출을 해도 되는지 반환한다. true 이면 호출 후 반드시 record 를 호출한다.
+// half-open 에서는 probe 1건만 허용한다.
+func (b *breaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.setState(BreakerHalfOpen)
+	}
+
+	switch {
+	case b.state == BreakerClosed:
+		return true
+	case b.state == BreakerHalfOpen && !b.probing:
+		b.probing = true
+		return true
+	default:
+		atomic.AddInt64(&b.metrics.S3BreakerRejectedTotal, 1)
+		return false
+	}
+}
+
+// ready 는 allow 가 true 를 반환할 상태인지 확인만 한다 (probe 를 차지하지 않는다).
+// DLQ 재업로드는 파일을 고르기 전에 이것으로 멈출지 판단한다.
+func (b *breaker) ready() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		return b.now().Sub(b.openedAt) >= b.cooldown
+	case BreakerHalfOpen:
+		return !b.probing
+	default:
+		return true
+	}
+}
+
+// record 는 allow 로 허용된 S3 호출(재시도 포함 1건)의 결과를 반영한다.
+func (b *breaker) record(ctx context.Context, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	canceled := err != nil && (errors.Is(err, context.Canceled) || ctx.Err() != nil)
+	// S3 장애로 보는 오류: retryable / unknown 분류만
+	failed := err != nil && !canceled && classifyS3Error(err).retryable()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probing = false
+		switch {
+		case canceled:
+			// probe 결과를 알 수 없으므로 half-open 을 유지한다 (다음 호출이 probe).
+		case failed:
+			b.open()
+		default:
+			b.failures = 0
+			b.setState(BreakerClosed)
+		}
+
+	case BreakerClosed:
+		switch {
+		case failed:
+			b.failures++
+			if b.failures >= b.threshold {
+				b.open()
+			}
+		case err == nil:
+			b.failures = 0
+		}
+
+		// open: open 전에 시작된 호출의 결과는 무시한다 (half-open probe 가 판단).
+	}
+}
+
+func (b *breaker) open() {
+	b.failures = 0
+	b.openedAt = b.now()
+	b.setState(BreakerOpen)
+	atomic.AddInt64(&b.metrics.S3BreakerOpenedTotal, 1)
+}
+
+// setState 는 상태와 gauge 를 바꾸고 전환을 로그로 남긴다. (mu 보유 상태에서 호출)
+func (b *breaker) setState(s BreakerState) {
+	if b.state == s {
+		return
+	}
+	prev := b.state
+	b.state = s
+	atomic.StoreInt64(&b.metrics.S3BreakerState, int64(s))
+
+	ev := log.Info()
+	if s == BreakerOpen {
+		ev = log.Warn().Dur("cooldown", b.cooldown)
+	}
+	ev.Str("from", prev.String()).
+		Str("to", s.String()).
+		Msg("S3 circuit breaker state changed")
+}
@@ -0,0 +1,176 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+
+	"github.com/aws/smithy-go"
+)
+
+func testEvents(n int) []*model.Event {
+	evs := make([]*model.Event, n)
+	for i := range evs {
+		evs[i] = &model.Event{Body: "a=1"}
+	}
+	return evs
+}
+
+func newTestBreaker(threshold int) (*breaker, *metrics.Metrics, *time.Time) {
+	m := metrics.New()
+	now := time.Unix(1000, 0)
+	b := newBreaker(config.Config{S3BreakerEnabled: true, S3BreakerFailures: threshold, S3BreakerCooldown: 10 * time.Second}, m)
+	b.now = func() time.Time { return now }
+	return b, m, &now
+}
+
+// closed → (연속 실패) open → (cooldown) half-open probe 1건 → 성공 closed / 실패 open
+func TestBreakerTransitions(t *testing.T) {
+	b, m, now := newTestBreaker(2)
+	ctx := context.Background()
+	fail := errors.New("500")
+
+	b.record(ctx, fail)
+	b.record(ctx, nil) // 성공이 연속 실패를 끊는다
+	b.record(ctx, fail)
+	if !b.allow() || m.S3BreakerState != int64(BreakerClosed) {
+		t.Fatal("breaker opened before threshold")
+	}
+	b.record(ctx, fail)
+	if b.allow() || b.ready() || m.S3BreakerState != int64(BreakerOpen) || m.S3BreakerOpenedTotal != 1 {
+		t.Fatalf("state=%d opened=%d, want open", m.S3BreakerState, m.S3BreakerOpenedTotal)
+	}
+	if m.S3BreakerRejectedTotal != 1 {
+		t.Fatalf("S3BreakerRejectedTotal = %d, want 1", m.S3BreakerRejectedTotal)
+	}
+
+	// cooldown 경과 → probe 1건만 허용
+	*now = now.Add(10 * time.Second)
+	if !b.ready() || !b.allow() {
+		t.Fatal("probe not allowed after cooldown")
+	}
+	if b.ready() || b.allow() || m.S3BreakerState != int64(BreakerHalfOpen) {
+		t.Fatal("second call allowed while probing")
+	}
+
+	// probe 실패 → 다시 open
+	b.record(ctx, fail)
+	if b.allow() || m.S3BreakerState != int64(BreakerOpen) || m.S3BreakerOpenedTotal != 2 {
+		t.Fatalf("state=%d opened=%d after failed probe", m.S3BreakerState, m.S3BreakerOpenedTotal)
+	}
+
+	// 취소된 probe 는 결과로 치지 않는다 → half-open 유지
+	*now = now.Add(10 * time.Second)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	b.allow()
+	b.record(cancelled, context.Canceled)
+	if m.S3BreakerState != int64(BreakerHalfOpen) || !b.ready() {
+		t.Fatalf("state=%d after cancelled probe, want half-open", m.S3BreakerState)
+	}
+
+	// probe 성공 → closed
+	b.allow()
+	b.record(ctx, nil)
+	if !b.allow() || m.S3BreakerState != int64(BreakerClosed) {
+		t.Fatalf("state=%d after successful probe, want closed", m.S3BreakerState)
+	}
+}
+
+// non-retryable 오류(tenant 의 권한 / 버킷 문제)는 연속 실패로 세지 않는다.
+func TestBreakerIgnoresNonRetryable(t *testing.T) {
+	b, m, now := newTestBreaker(2)
+	ctx := context.Background()
+	denied := &smithy.GenericAPIError{Code: "AccessDenied"}
+	noBucket := fmt.Errorf("put: %w", &smithy.GenericAPIError{Code: "NoSuchBucket"})
+	fail := &smithy.GenericAPIError{Code: "InternalError"}
+
+	b.record(ctx, fail)
+	for i := 0; i < 5; i++ {
+		b.record(ctx, denied)
+		b.record(ctx, noBucket)
+	}
+	if m.S3BreakerState != int64(BreakerClosed) || m.S3BreakerOpenedTotal != 0 {
+		t.Fatalf("state=%d opened=%d, want closed", m.S3BreakerState, m.S3BreakerOpenedTotal)
+	}
+
+	// non-retryable 오류가 앞선 retryable 실패를 끊지도 않는다
+	b.record(ctx, fail)
+	if m.S3BreakerState != int64(BreakerOpen) {
+		t.Fatalf("state=%d after 2 retryable failures, want open", m.S3BreakerState)
+	}
+
+	// half-open probe 가 non-retryable 오류를 받으면 S3 는 응답한 것이므로 closed
+	*now = now.Add(10 * time.Second)
+	b.allow()
+	b.record(ctx, denied)
+	if m.S3BreakerState != int64(BreakerClosed) {
+		t.Fatalf("state=%d after non-retryable probe, want closed", m.S3BreakerState)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := newBreaker(config.Config{S3BreakerEnabled: false}, metrics.New())
+	if b != nil {
+		t.Fatal("disabled breaker is not nil")
+	}
+	b.record(context.Background(), errors.New("500"))
+	if !b.allow() || !b.ready() {
+		t.Fatal("nil breaker rejected a call")
+	}
+}
+
+// open 상태에서 새 배치는 S3 호출 없이 바로 DLQ 로 가고, DLQ 재업로드는 멈춘다.
+func TestUploadBatchBreakerOpenGoesToDLQ(t *testing.T) {
+	f := newFakeS3(t)
+	var requests atomic.Int32
+	f.setFail(func(*http.Request) int {
+		requests.Add(1)
+		return http.StatusInternalServerError
+	})
+
+	u, m := newTestMultipartUploader(f, 1024)
+	u.cfg.S3AppRetries = 1
+	u.cfg.InstanceID = "test"
+	u.cfg.DLQDir = t.TempDir()
+	u.cfg.DLQMaxSizeBytes = 1 << 20
+	u.cfg.S3BreakerEnabled = true
+	u.cfg.S3BreakerFailures = 1
+	u.cfg.S3BreakerCooldown = time.Hour
+	u.breaker = newBreaker(u.cfg, m)
+
+	mgr := &Manager{
+		cfg:     u.cfg,
+		metrics: m,
+		sink:    u,
+		dlq:     NewDLQManager(u.cfg, m, u),
+		encoder: NewEncoder(u.cfg, m),
+	}
+	ctx := context.Background()
+	dst := destination{bucket: "raw"}
+
+	// 1번째 배치: S3 실패 → breaker open → DLQ
+	mgr.uploadBatch(ctx, dst, "raw", testEvents(3))
+	// 2번째 배치: S3 호출 없이 DLQ
+	mgr.uploadBatch(ctx, dst, "raw", testEvents(3))
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("S3 requests = %d, want 1", n)
+	}
+	if m.DLQEventsEnqueuedTotal != 6 || m.S3BreakerRejectedTotal != 1 {
+		t.Fatalf("DLQ enqueued=%d rejected=%d", m.DLQEventsEnqueuedTotal, m.S3BreakerRejectedTotal)
+	}
+
+	// 재업로드 정지
+	mgr.dlq.ProcessOneCtx(ctx)
+	if n := requests.Load(); n != 1 || m.DLQFilesCurrent != 2 {
+		t.Fatalf("replay while open: requests=%d files=%d", n, m.DLQFilesCurrent)
+	}
+}
@@ -6,13 +6,15 @@ import (
 	"bytes"
 	"context"
 	stdjson "encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -30,51 +32,120 @@ import (
 // - S3 업로드 실패: gzip+JSONL 배치를 로컬 DLQ에 저장
 // TTL 판단은 "파일명 prefix 의 Unix timestamp" 기준으로 한다.
 type DLQManager struct {
-	cfg      config.Config
-	metrics  *metrics.Metrics
-	uploader *S3Uploader
+	cfg     config.Config
+	metrics *metrics.Metrics
+	sink    Sink
 
 	// 현재 DLQ 디렉토리에 저장된 data 파일 총 바이트 수
 	dlqSizeBytes int64
+
+	// mu 는 파일 선택(pickOldest) · 삭제 · 용량 회계를 직렬화한다.
+	// UploadWorkers 개의 worker 가 동시에 Save → ensureCapacity 를 호출하고
+	// worker 0 의 ProcessOneCtx 가 같은 디렉토리를 재처리하므로,
+	// 같은 파일을 두 번 evict(이중 차감)하거나 재업로드 중인 파일을 삭제하지 않도록 한다.
+	mu sync.Mutex
+
+	// replaying 은 재업로드 중인 data 파일 이름이다 (mu 보호).
+	// S3 업로드는 lock 밖에서 수행하므로, 그 동안 pickOldest / eviction 대상에서 제외한다.
+	replaying map[string]struct{}
+}
+
+// errDLQBusy 는 다른 goroutine 이 재업로드 중인 파일을 조작하려 할 때 반환된다.
+var errDLQBusy = errors.New("DLQ file is being replayed")
+
+// errDLQFull 은 오래된 파일을 정리해도 DLQMaxSizeBytes 안에 배치를 넣을 수 없을 때 Save 가 반환한다.
+// 호출자는 배치가 저장되지 않았으므로 WAL Ack 를 하면 안 된다.
+var errDLQFull = errors.New("DLQ full")
+
+// dlqMeta 는 DLQ data 파일 옆에 저장되는 메타 파일(.meta.json)의 내용이다.
+//
+// S3Key 는 최초 업로드 시도 시 사용한 RAW key 이다.
+// 재업로드 시 이 key 를 그대로 사용하므로, 배치는 실패 시점이 아닌
+// "원래 속해야 했던" 파티션(dt/hr)에 저장된다.
+// S3Key 가 없는 legacy 파일은 파일명의 unix timestamp 로 파티션을 계산한다.
+//
+// Tenant 는 배치의 tenant id(S3_KEY_TEMPLATE 의 {tenant})이며, single-tenant 이면 비어있다.
+// Bucket 은 tenant 버킷(TENANTS_FILE)이며, 비어있으면 RAW_BUCKET 이다.
+// Prefix / DLQPrefix 는 배치의 원래 prefix(RAW / BOT / INVALID)와 tenant 의 DLQ prefix 이며,
+// S3Key 를 쓸 수 없을 때(깨진 파일 / legacy 메타) 재업로드 key 를 만드는 데 사용한다.
+type dlqMeta struct {
+	NumEvents int64  `json:"num_events"`
+	S3Key     string `json:"s3_key,omitempty"`
+	Tenant    string `json:"tenant,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	DLQPrefix string `json:"dlq_prefix,omitempty"`
 }
 
 // NewDLQManager 는 DLQ 디렉토리를 초기화하고, 기존 파일을 스캔하여
 // DLQSizeBytes / DLQFilesCurrent 를 복원한다.
 // 이때 meta orphan (data 없이 .meta.json 만 남은 경우) 도 정리한다.
-func NewDLQManager(cfg config.Config, m *metrics.Metrics, uploader *S3Uploader) *DLQManager {
+func NewDLQManager(cfg config.Config, m *metrics.Metrics, sink Sink) *DLQManager {
 	_ = os.MkdirAll(cfg.DLQDir, 0o755)
+	removeOrphanMeta(cfg.DLQDir)
+	return OpenDLQManager(cfg, m, sink)
+}
 
+// OpenDLQManager 는 디렉토리를 생성하거나 정리하지 않고 DLQManager 를 만든다.
+// dlqctl 처럼 list / cat / -dry-run 에서 디렉토리를 변경하면 안 되는 호출자가 사용한다.
+// sink 는 재업로드(Replay / ProcessOneCtx)를 하지 않으면 nil 이어도 된다.
+func OpenDLQManager(cfg config.Config, m *metrics.Metrics, sink Sink) *DLQManager {
 	d := &DLQManager{
-		cfg:      cfg,
-		metrics:  m,
-		uploader: uploader,
+		cfg:       cfg,
+		metrics:   m,
+		sink:      sink,
+		replaying: make(map[string]struct{}),
+	}
+	d.Rescan()
+	return d
+}
+
+// removeOrphanMeta 는 같은 이름의 data 파일 없이 남은 *.meta.json 과,
+// SaveStream 도중 죽어서 남은 임시 파일(.tmp-*)을 삭제한다.
+func removeOrphanMeta(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, dlqTempPrefix) {
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if e.IsDir() || !strings.HasSuffix(name, ".meta.json") {
+			continue
+		}
+		dataName := strings.TrimSuffix(name, ".meta.json")
+		if _, err := os.Stat(filepath.Join(dir, dataName)); os.IsNotExist(err) {
+			_ = os.Remove(filepath.Join(dir, name))
+		}
 	}
+}
+
+// Rescan 은 DLQ 디렉토리의 data 파일을 다시 세어 용량/파일 수 회계를 디스크 기준으로 맞춘다.
+//
+// dlqctl 은 별도 프로세스에서 파일을 purge / quarantine / replay 하므로
+// 서버의 카운터(dlqSizeBytes)가 실제보다 커질 수 있다. 그대로 두면 ensureCapacity 가
+// 실제 파일을 일찍 evict 하고 /health/ready 가 DLQ full 로 계속 판단하므로,
+// worker 0 이 DLQRescanInterval 마다 호출한다.
+func (d *DLQManager) Rescan() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rescanLocked()
+}
 
-	var total int64
-	var count int64
+func (d *DLQManager) rescanLocked() {
+	var total, count int64
 
-	entries, err := os.ReadDir(cfg.DLQDir)
+	entries, err := os.ReadDir(d.cfg.DLQDir)
 	if err == nil {
 		for _, e := range entries {
-			if e.IsDir() {
+			if e.IsDir() || !isDLQDataName(e.Name()) {
 				continue
 			}
-
-			name := e.Name()
-			full := filepath.Join(cfg.DLQDir, name)
-
-			// meta orphan 제거: *.meta.json 이고, 같은 이름의 data 파일이 없으면 삭제
-			if strings.HasSuffix(name, ".meta.json") {
-				dataName := strings.TrimSuffix(name, ".meta.json")
-				if _, err := os.Stat(filepath.Join(cfg.DLQDir, dataName)); os.IsNotExist(err) {
-					_ = os.Remove(full)
-				}
-				continue
-			}
-
 			// data 파일만 카운트
-			info, err := e.Info()
-			if err == nil {
+			if info, err := e.Info(); err == nil {
 				total += info.Size()
 				count++
 			}
@@ -82,47 +153,47 @@ func NewDLQManager(cfg config.Config, m *metrics.Metrics, uploader *S3Uploader)
 	}
 
 	atomic.StoreInt64(&d.dlqSizeBytes, total)
-	if total > 0 {
-		atomic.AddInt64(&m.DLQSizeBytes, total)
-	}
-	if count > 0 {
-		atomic.AddInt64(&m.DLQFilesCurrent, count)
-	}
-
-	return d
+	atomic.StoreInt64(&d.metrics.DLQSizeBytes, total)
+	atomic.StoreInt64(&d.metrics.DLQFilesCurrent, count)
 }
 
 // Save 는 S3 업로드 실패한 gzip+JSONL 배치를 로컬 DLQ 에 저장한다.
-// numEvents 는 해당 배치에 포함된 이벤트 수이며, 메타 파일(.meta.json)에 기록된다.
+// meta 는 배치의 이벤트 수와 업로드에 실패한 원래 위치(tenant / bucket / prefix / key)이며,
+// 메타 파일(.meta.json)에 그대로 기록된다.
+//
+// nil 을 반환하면 data/meta 파일과 디렉토리 엔트리가 모두 fsync 된 상태이다.
+// 용량 부족으로 배치를 버린 경우 errDLQFull 을 반환한다.
+//
+// 메타 파일을 먼저 쓰므로, data 파일이 보이면 메타도 항상 존재한다.
+// (data 저장 전에 죽어서 남은 meta orphan 은 NewDLQManager 가 정리한다)
 //
 // TTL 판단은 파일명 prefix 의 Unix timestamp 기반이므로
 // 별도로 mtime 을 조정할 필요는 없다.
-func (d *DLQManager) Save(data []byte, numEvents int) error {
+func (d *DLQManager) Save(data []byte, meta dlqMeta) error {
+	numEvents := int(meta.NumEvents)
 	if len(data) == 0 || numEvents <= 0 {
 		return nil
 	}
 
+	// 용량 확보 → 파일 쓰기 → 회계 갱신을 한 번에 처리한다 (동시 Save 간 이중 evict 방지).
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	size := int64(len(data))
 	if !d.ensureCapacity(size) {
-		n := atomic.AddInt64(&d.metrics.DLQEventsDroppedTotal, int64(numEvents))
-		// 용량 부족: 가장 오래된 파일들 정리했지만 여전히 공간 부족 → drop (샘플링: 1,000)
-		if n%1000 == 0 {
-			log.Error().
-				Int64("bytes", size).
-				Int("events", numEvents).
-				Int64("dropped_total", n).
-				Msg("DLQ full → dropping batches")
-		}
-
-		return nil
+		return d.dropFull(size, numEvents)
 	}
 
 	filename := NewFilename(d.cfg.InstanceID)         // "<unix>_<instance>_<counter>.jsonl.gz"
 	dataPath := filepath.Join(d.cfg.DLQDir, filename) // data 파일
 	metaPath := dataPath + ".meta.json"               // 메타 파일
 
+	// 메타 파일 저장 (num_events + 원래 tenant / bucket / prefix / S3 key)
+	d.writeMeta(metaPath, meta)
+
 	// data 파일 저장
-	if err := os.WriteFile(dataPath, data, 0o600); err != nil {
+	if err := writeFileDurable(dataPath, writeAll(data)); err != nil {
+		_ = os.Remove(metaPath)
 		log.Error().
 			Err(err).
 			Str("path", dataPath).
@@ -130,28 +201,137 @@ func (d *DLQManager) Save(data []byte, numEvents int) error {
 		return err
 	}
 
-	// 메타 파일 저장 (현재는 num_events 만 기록)
-	meta := []byte(fmt.Sprintf(`{"num_events":%d}`, numEvents))
-	_ = os.WriteFile(metaPath, meta, 0o600)
+	d.account(size, numEvents)
+	return nil
+}
+
+// SaveStream 은 Save 와 같지만, write 가 쓰는 내용(JSONL.gz)을 메모리를 거치지 않고 파일로 저장한다.
+// multipart streaming 업로드에 실패한 큰 배치를 DLQ 로 보낼 때 사용한다.
+//
+// 크기를 미리 알 수 없으므로 숨김 임시 파일(pickOldest 대상 아님)에 먼저 쓰고,
+// 그 크기로 용량을 확보한 뒤 rename 한다. 용량이 부족하면 임시 파일을 지우고 errDLQFull 이다.
+func (d *DLQManager) SaveStream(meta dlqMeta, write func(io.Writer) error) error {
+	numEvents := int(meta.NumEvents)
+	if numEvents <= 0 {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(d.cfg.DLQDir, dlqTempPrefix+"*")
+	if err != nil {
+		log.Error().Err(err).Str("dir", d.cfg.DLQDir).Msg("DLQ write failed")
+		return err
+	}
+	defer os.Remove(tmp.Name()) // rename 성공 시에는 no-op
+
+	size, err := fillTemp(tmp, write)
+	if err != nil {
+		log.Error().Err(err).Str("path", tmp.Name()).Msg("DLQ write failed")
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.ensureCapacity(size) {
+		return d.dropFull(size, numEvents)
+	}
+
+	dataPath := filepath.Join(d.cfg.DLQDir, NewFilename(d.cfg.InstanceID))
+	metaPath := dataPath + ".meta.json"
+	d.writeMeta(metaPath, meta)
+
+	if err := os.Rename(tmp.Name(), dataPath); err != nil {
+		_ = os.Remove(metaPath)
+		log.Error().Err(err).Str("path", dataPath).Msg("DLQ write failed")
+		return err
+	}
+	if err := syncDir(d.cfg.DLQDir); err != nil {
+		log.Warn().Err(err).Str("dir", d.cfg.DLQDir).Msg("DLQ dir sync failed")
+	}
+
+	d.account(size, numEvents)
+	return nil
+}
+
+// dlqTempPrefix 는 SaveStream 임시 파일 이름 prefix 이다. '.' 으로 시작하므로 data 파일로 취급되지 않는다.
+const dlqTempPrefix = ".tmp-"
+
+// fillTemp 는 write 로 f 를 채우고 fsync 한 뒤 닫고, 기록된 크기를 반환한다.
+func fillTemp(f *os.File, write func(io.Writer) error) (int64, error) {
+	if err := write(f); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	return info.Size(), f.Close()
+}
+
+// writeMeta 는 메타 파일을 저장한다. 실패해도 data 파일은 저장한다 (재업로드 시 파일명으로 key 계산).
+func (d *DLQManager) writeMeta(metaPath string, meta dlqMeta) {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+	if err := writeFileDurable(metaPath, writeAll(b)); err != nil {
+		log.Warn().
+			Err(err).
+			Str("path", metaPath).
+			Msg("DLQ meta write failed")
+	}
+}
+
+// dropFull 은 용량 부족으로 배치를 저장하지 못한 것을 기록하고 errDLQFull 을 반환한다.
+func (d *DLQManager) dropFull(size int64, numEvents int) error {
+	n := atomic.AddInt64(&d.metrics.DLQEventsDroppedTotal, int64(numEvents))
+	// 용량 부족: 가장 오래된 파일들 정리했지만 여전히 공간 부족 → drop (샘플링: 1,000)
+	if n%1000 == 0 {
+		log.Error().
+			Int64("bytes", size).
+			Int("events", numEvents).
+			Int64("dropped_total", n).
+			Msg("DLQ full → dropping batches")
+	}
+	return errDLQFull
+}
 
-	// metrics
+// account 는 저장된 파일을 용량 / 파일 수 회계에 반영한다. (mu 보유 상태에서 호출)
+func (d *DLQManager) account(size int64, numEvents int) {
 	atomic.AddInt64(&d.dlqSizeBytes, size)
 	atomic.AddInt64(&d.metrics.DLQSizeBytes, size)
 	atomic.AddInt64(&d.metrics.DLQFilesCurrent, 1)
 	atomic.AddInt64(&d.metrics.DLQEventsEnqueuedTotal, int64(numEvents))
+}
 
-	return nil
+// writeAll 은 b 전체를 파일에 쓰는 writeFileDurable 용 fill 함수를 반환한다.
+func writeAll(b []byte) func(*os.File) error {
+	return func(f *os.File) error {
+		_, err := f.Write(b)
+		return err
+	}
 }
 
 // ensureCapacity 는 DLQMaxSizeBytes 를 초과하지 않도록
 // 가장 오래된 data/meta 파일부터 삭제한다.
 // data 파일이 더 이상 없으면 false 를 반환한다.
+// d.mu 를 잡은 상태에서 호출해야 한다.
+//
+// 카운터와 디스크가 어긋난 흔적(고를 파일이 없거나 파일이 이미 사라짐)이 보이면
+// 한 번 rescan 하여 디스크 기준으로 다시 판단한다.
 func (d *DLQManager) ensureCapacity(incoming int64) bool {
 	max := d.cfg.DLQMaxSizeBytes
 	if max <= 0 {
 		return true
 	}
 
+	rescanned := false
 	for {
 		curr := atomic.LoadInt64(&d.dlqSizeBytes)
 		if curr+incoming <= max {
@@ -160,18 +340,31 @@ func (d *DLQManager) ensureCapacity(incoming int64) bool {
 
 		oldest := d.pickOldest()
 		if oldest == "" {
-			return false
+			if rescanned {
+				return false
+			}
+			d.rescanLocked()
+			rescanned = true
+			continue
 		}
 
 		dataPath := filepath.Join(d.cfg.DLQDir, oldest)
 		metaPath := dataPath + ".meta.json"
 
 		info, err := os.Stat(dataPath)
-		if err == nil {
-			atomic.AddInt64(&d.dlqSizeBytes, -info.Size())
-			atomic.AddInt64(&d.metrics.DLQSizeBytes, -info.Size())
+		if err != nil {
+			// 다른 프로세스(dlqctl)가 이미 삭제/이동한 파일
+			if rescanned {
+				return false
+			}
+			d.rescanLocked()
+			rescanned = true
+			continue
 		}
 
+		atomic.AddInt64(&d.dlqSizeBytes, -info.Size())
+		atomic.AddInt64(&d.metrics.DLQSizeBytes, -info.Size())
+
 		_ = os.Remove(dataPath)
 		_ = os.Remove(metaPath)
 
@@ -195,9 +388,38 @@ func (d *DLQManager) ProcessOneCtx(ctx context.Context) {
 	default:
 	}
 
+	// S3 circuit breaker 가 open 이면 재업로드를 멈춘다 (half-open 이 되면 이 호출이 probe 가 될 수 있다).
+	if !d.sink.Available() {
+		return
+	}
+
+	name, size, ok := d.claimOldest()
+	if !ok {
+		return
+	}
+	defer d.release(name)
+
+	// shutdown 다시 체크
+	select {
+	case <-ctx.Done():
+		return
+	default:
+	}
+
+	_ = d.reupload(ctx, name, size)
+}
+
+// claimOldest 는 d.mu 를 잡은 채로 가장 오래된 파일을 골라
+// 사라진 파일 정리 / TTL 만료 삭제를 처리하고,
+// 재업로드할 파일이면 replaying 에 등록하여 반환한다.
+// 호출자는 재업로드 후 release 를 호출해야 한다.
+func (d *DLQManager) claimOldest() (string, int64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	name := d.pickOldest()
 	if name == "" {
-		return
+		return "", 0, false
 	}
 
 	dataPath := filepath.Join(d.cfg.DLQDir, name)
@@ -205,15 +427,14 @@ func (d *DLQManager) ProcessOneCtx(ctx context.Context) {
 
 	info, err := os.Stat(dataPath)
 	if err != nil {
-		// 파일이 사라진 경우 정리만 수행
-		_ = os.Remove(dataPath)
+		// 파일이 사라진 경우(dlqctl 등) 정리 후 디스크 기준으로 회계를 다시 맞춘다.
 		_ = os.Remove(metaPath)
-		atomic.AddInt64(&d.metrics.DLQFilesCurrent, -1)
+		d.rescanLocked()
 
 		log.Warn().
 			Str("file", name).
 			Msg("DLQ file missing → cleaned")
-		return
+		return "", 0, false
 	}
 
 	size := info.Size()
@@ -237,18 +458,44 @@ func (d *DLQManager) ProcessOneCtx(ctx context.Context) {
 					Str("file", name).
 					Str("age", age.String()).
 					Msg("DLQ TTL expired → deleted")
-				return
+				return "", 0, false
 			}
 		}
 		// filename 에서 unix 를 읽지 못하면 TTL 판단은 skip 하고 계속 진행
 	}
 
-	// shutdown 다시 체크
-	select {
-	case <-ctx.Done():
-		return
-	default:
+	d.replaying[name] = struct{}{}
+	return name, size, true
+}
+
+// claim 은 name 을 replaying 에 등록한다. 이미 재업로드 중이면 false 를 반환한다.
+func (d *DLQManager) claim(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.replaying[name]; ok {
+		return false
 	}
+	d.replaying[name] = struct{}{}
+	return true
+}
+
+// release 는 claim / claimOldest 로 등록한 name 을 해제한다.
+func (d *DLQManager) release(name string) {
+	d.mu.Lock()
+	delete(d.replaying, name)
+	d.mu.Unlock()
+}
+
+// reupload 는 DLQ data 파일 name 을 유효성 검사 후 RAW 또는 RAW_DLQ 로 업로드하고,
+// 성공하면 로컬 data/meta 파일을 제거한다.
+// ProcessOneCtx(자동 재처리)와 Replay(dlqctl 수동 재처리)가 공유한다.
+//
+// 호출자는 name 을 claim 한 상태여야 한다. 업로드는 lock 밖에서 수행하고,
+// 파일 삭제와 회계 갱신만 d.mu 안에서 처리한다.
+func (d *DLQManager) reupload(ctx context.Context, name string, size int64) error {
+	dataPath := filepath.Join(d.cfg.DLQDir, name)
+	metaPath := dataPath + ".meta.json"
 
 	// data 파일 open
 	f, err := os.Open(dataPath)
@@ -257,7 +504,7 @@ func (d *DLQManager) ProcessOneCtx(ctx context.Context) {
 			Str("file", name).
 			Err(err).
 			Msg("DLQ open failed")
-		return
+		return err
 	}
 	defer f.Close()
 
@@ -270,43 +517,33 @@ func (d *DLQManager) ProcessOneCtx(ctx context.Context) {
 			Str("file", name).
 			Err(err).
 			Msg("DLQ seek failed")
-		return
+		return err
 	}
 
 	// 유효하면 RAW, 아니면 RAW_DLQ 로 보낸다.
-	var key string
-	if valid {
-		key = BuildS3Key(d.cfg.RawPrefix, name)
-	} else {
-		key = BuildS3Key(d.cfg.DLQPrefix, name)
-	}
+	// 파티션은 재업로드 시점이 아니라 원래 업로드 시점 기준이다.
+	meta := readMeta(metaPath)
+	key := d.replayKey(name, meta, valid)
 
-	if err := d.uploader.UploadFileWithRetryCtx(ctx, key, f, size); err != nil {
+	if err := d.sink.PutReader(ctx, meta.Bucket, key, f, size); err != nil {
 		log.Warn().
 			Str("s3_key", key).
 			Err(err).
 			Msg("DLQ reupload failed")
-		return
+		return err
 	}
 
-	// meta 에서 num_events 읽기 (없거나 깨져 있으면 1 로 fallback)
-	numEvents := int64(1)
-	if meta, err := os.ReadFile(metaPath); err == nil {
-		var v struct {
-			NumEvents int64 `json:"num_events"`
-		}
-		if json.Unmarshal(meta, &v) == nil && v.NumEvents > 0 {
-			numEvents = v.NumEvents
-		}
-	}
+	numEvents := meta.NumEvents
 
 	// 업로드 성공 → 로컬 파일 제거
+	d.mu.Lock()
 	_ = os.Remove(dataPath)
 	_ = os.Remove(metaPath)
 
 	atomic.AddInt64(&d.dlqSizeBytes, -size)
 	atomic.AddInt64(&d.metrics.DLQSizeBytes, -size)
 	atomic.AddInt64(&d.metrics.DLQFilesCurrent, -1)
+	d.mu.Unlock()
 	atomic.AddInt64(&d.metrics.DLQEventsReuploadedTotal, numEvents)
 
 	if valid {
@@ -320,6 +557,63 @@ func (d *DLQManager) ProcessOneCtx(ctx context.Context) {
 			Int64("events", numEvents).
 			Msg("DLQ → RAW_DLQ success")
 	}
+
+	return nil
+}
+
+// readMeta 는 메타 파일을 읽는다.
+// 없거나 깨져 있으면 NumEvents 는 1 로 fallback 하고 S3Key 는 비워둔다.
+func readMeta(metaPath string) dlqMeta {
+	var v dlqMeta
+	if b, err := os.ReadFile(metaPath); err == nil {
+		if json.Unmarshal(b, &v) != nil {
+			v = dlqMeta{}
+		}
+	}
+	if v.NumEvents <= 0 {
+		v.NumEvents = 1
+	}
+	return v
+}
+
+// replayKey 는 DLQ 파일 name 을 재업로드할 S3 key 를 결정한다.
+//
+//   - valid + meta.S3Key 있음 : 원래 key 를 그대로 사용
+//   - 그 외                   : 원래 시각(meta.S3Key 의 파일명, 없으면 DLQ 파일명의 unix)
+//     기준 파티션으로 meta 의 tenant prefix(valid) / DLQ prefix(깨진 파일) key 를 생성
+//   - prefix 가 없는 legacy 메타는 RAW_PREFIX / DLQ_PREFIX (tenant 가 있으면 뒤에 /<tenant>) 를 사용한다.
+//   - 시각을 알 수 없으면 현재 파티션을 사용한다.
+//
+// 버킷은 meta.Bucket 을 그대로 사용한다 (ProcessOneCtx).
+func (d *DLQManager) replayKey(name string, meta dlqMeta, valid bool) string {
+	if valid && meta.S3Key != "" {
+		return meta.S3Key
+	}
+
+	var prefix string
+	switch {
+	case valid && meta.Prefix != "":
+		prefix = meta.Prefix
+	case valid:
+		prefix = path.Join(d.cfg.RawPrefix, meta.Tenant)
+	case meta.DLQPrefix != "":
+		prefix = meta.DLQPrefix
+	default:
+		prefix = path.Join(d.cfg.DLQPrefix, meta.Tenant)
+	}
+
+	var sec int64
+	var ok bool
+	if meta.S3Key != "" {
+		sec, ok = extractUnixFromFilename(path.Base(meta.S3Key))
+	}
+	if !ok {
+		sec, ok = extractUnixFromFilename(name)
+	}
+	if !ok {
+		return BuildS3Key(prefix, meta.Tenant, name)
+	}
+	return BuildS3KeyAt(prefix, meta.Tenant, name, sec)
 }
 
 // validateFile
@@ -364,6 +658,7 @@ func (d *DLQManager) validateFile(f *os.File, size int64) bool {
 
 // pickOldest는 DLQ 디렉토리에 있는 데이터 파일들 중,
 // "부분 스캔(partial scan)"을 이용해 가장 오래된 파일을 선택한다.
+// 재업로드 중인(replaying) 파일은 제외하며, d.mu 를 잡은 상태에서 호출해야 한다.
 //
 // ------------------------------------------------------------
 // [운영 최적화: Partial Scan 방식 적용]
@@ -416,12 +711,16 @@ func (d *DLQManager) pickOldest() string {
 	// 3. 유효한 데이터 파일 필터링
 	// - .meta.json 파일 제외
 	// - 숨김 파일(.으로 시작) 제외
+	// - 재업로드 중인 파일 제외
 	var candidates []string
 	for _, name := range names {
 		// Readdirnames는 빈 이름을 반환하지 않으므로 name[0] 접근은 안전하다.
 		if strings.HasSuffix(name, ".meta.json") || name[0] == '.' {
 			continue
 		}
+		if _, busy := d.replaying[name]; busy {
+			continue
+		}
 		candidates = append(candidates, name)
 	}
 
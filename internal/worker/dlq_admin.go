@@ -0,0 +1,258 @@
+// internal/worker/dlq_admin.go
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/rs/zerolog/log"
+)
+
+// dlq_admin.go
+// ------------------------------------------------------------
+// 운영자용 DLQ 조작 API (cmd/dlqctl 에서 사용).
+//
+// 서버의 자동 재처리(ProcessOneCtx)와 동일한 규칙을 사용한다:
+//   - 유효성 검사     : validateFile (첫 줄 JSON 확인)
+//   - 재업로드 key    : replayKey (원래 파티션 유지)
+//   - 메타 파일       : readMeta (num_events, s3_key)
+//   - 메트릭/용량 회계 : dlqSizeBytes, DLQSizeBytes, DLQFilesCurrent
+//
+// pickOldest 와 달리 List 는 디렉토리 전체를 스캔한다.
+// 운영자가 수동으로 호출하는 경로이므로 O(N) 비용을 허용한다.
+// ------------------------------------------------------------
+
+// ErrInvalidDLQName 은 DLQ 디렉토리 밖을 가리키거나 data 파일이 아닌 이름에 대해 반환된다.
+var ErrInvalidDLQName = errors.New("invalid DLQ file name")
+
+// DLQFile 은 DLQ data 파일 하나의 상태이다.
+type DLQFile struct {
+	Name      string // "<unix>_<instance>_<counter>.jsonl.gz"
+	Size      int64  // data 파일 크기 (bytes)
+	Unix      int64  // 파일명 prefix 의 unix timestamp (읽지 못하면 0)
+	NumEvents int64  // .meta.json 의 num_events (없으면 1)
+	S3Key     string // .meta.json 의 s3_key (legacy 파일은 빈 값)
+	Valid     bool   // validateFile 결과 (false 면 RAW_DLQ 로 재업로드됨)
+	ReplayKey string // 재업로드 시 사용할 S3 key
+}
+
+// Dir 은 DLQ 디렉토리 경로를 반환한다.
+func (d *DLQManager) Dir() string {
+	return d.cfg.DLQDir
+}
+
+// List 는 DLQ 디렉토리의 모든 data 파일을 오래된 순으로 반환한다.
+func (d *DLQManager) List() ([]DLQFile, error) {
+	entries, err := os.ReadDir(d.cfg.DLQDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !isDLQDataName(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make([]DLQFile, 0, len(names))
+	for _, name := range names {
+		f, err := d.Inspect(name)
+		if err != nil {
+			// List 도중 재처리/삭제된 파일은 건너뛴다.
+			continue
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// Inspect 는 data 파일 name 의 크기, 메타, 유효성, 재업로드 key 를 읽는다.
+func (d *DLQManager) Inspect(name string) (DLQFile, error) {
+	if !isDLQDataName(name) {
+		return DLQFile{}, fmt.Errorf("%w: %q", ErrInvalidDLQName, name)
+	}
+
+	dataPath := filepath.Join(d.cfg.DLQDir, name)
+
+	f, err := os.Open(dataPath)
+	if err != nil {
+		return DLQFile{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return DLQFile{}, err
+	}
+
+	meta := readMeta(dataPath + ".meta.json")
+	valid := d.validateFile(f, info.Size())
+	sec, _ := extractUnixFromFilename(name)
+
+	return DLQFile{
+		Name:      name,
+		Size:      info.Size(),
+		Unix:      sec,
+		NumEvents: meta.NumEvents,
+		S3Key:     meta.S3Key,
+		Valid:     valid,
+		ReplayKey: d.replayKey(name, meta, valid),
+	}, nil
+}
+
+// OpenEvents 는 data 파일 name 의 압축을 해제한 JSONL 스트림을 반환한다.
+// 호출자는 반드시 Close 해야 한다.
+func (d *DLQManager) OpenEvents(name string) (io.ReadCloser, error) {
+	if !isDLQDataName(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDLQName, name)
+	}
+
+	f, err := os.Open(filepath.Join(d.cfg.DLQDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: gz, f: f}, nil
+}
+
+// Replay 는 data 파일 name 을 즉시 재업로드한다.
+// 규칙은 ProcessOneCtx 와 동일하며 TTL 판단만 하지 않는다.
+func (d *DLQManager) Replay(ctx context.Context, name string) error {
+	if !isDLQDataName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidDLQName, name)
+	}
+	if !d.claim(name) {
+		return fmt.Errorf("%w: %q", errDLQBusy, name)
+	}
+	defer d.release(name)
+
+	info, err := os.Stat(filepath.Join(d.cfg.DLQDir, name))
+	if err != nil {
+		return err
+	}
+	return d.reupload(ctx, name, info.Size())
+}
+
+// Quarantine 은 data/meta 파일을 dir 로 이동하여 재처리 대상에서 제외한다.
+// dir 은 DLQ 디렉토리와 같은 파일시스템에 있어야 한다 (os.Rename).
+func (d *DLQManager) Quarantine(name, dir string) error {
+	if !isDLQDataName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidDLQName, name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, busy := d.replaying[name]; busy {
+		return fmt.Errorf("%w: %q", errDLQBusy, name)
+	}
+
+	dataPath := filepath.Join(d.cfg.DLQDir, name)
+	metaPath := dataPath + ".meta.json"
+
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return err
+	}
+
+	// meta 를 먼저 옮긴다. (data 만 남으면 num_events 가 1 로 fallback 될 뿐 유실은 없다)
+	if err := os.Rename(metaPath, filepath.Join(dir, name+".meta.json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(dataPath, filepath.Join(dir, name)); err != nil {
+		return err
+	}
+
+	d.forget(info.Size())
+
+	log.Warn().
+		Str("file", name).
+		Str("dir", dir).
+		Msg("DLQ file quarantined")
+	return nil
+}
+
+// Purge 는 data/meta 파일을 삭제한다.
+// 삭제된 파일은 TTL 만료와 동일하게 DLQFilesExpiredTotal 로 집계된다.
+func (d *DLQManager) Purge(name string) error {
+	if !isDLQDataName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidDLQName, name)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, busy := d.replaying[name]; busy {
+		return fmt.Errorf("%w: %q", errDLQBusy, name)
+	}
+
+	dataPath := filepath.Join(d.cfg.DLQDir, name)
+
+	info, err := os.Stat(dataPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dataPath); err != nil {
+		return err
+	}
+	_ = os.Remove(dataPath + ".meta.json")
+
+	d.forget(info.Size())
+	atomic.AddInt64(&d.metrics.DLQFilesExpiredTotal, 1)
+
+	log.Info().
+		Str("file", name).
+		Msg("DLQ file purged")
+	return nil
+}
+
+// forget 은 DLQ 에서 빠져나간 data 파일 하나만큼 용량/파일 수 회계를 되돌린다.
+// d.mu 를 잡은 상태에서 호출해야 한다.
+func (d *DLQManager) forget(size int64) {
+	atomic.AddInt64(&d.dlqSizeBytes, -size)
+	atomic.AddInt64(&d.metrics.DLQSizeBytes, -size)
+	atomic.AddInt64(&d.metrics.DLQFilesCurrent, -1)
+}
+
+// isDLQDataName 은 name 이 DLQ 디렉토리 바로 아래의 data 파일 이름인지 확인한다.
+// pickOldest 와 같은 규칙(.meta.json / 숨김 파일 제외)에 경로 구분자 금지를 더한다.
+func isDLQDataName(name string) bool {
+	if name == "" || name[0] == '.' || strings.HasSuffix(name, ".meta.json") {
+		return false
+	}
+	return filepath.Base(name) == name
+}
+
+// gzipFile 은 gzip.Reader 와 원본 파일을 함께 닫는 ReadCloser 이다.
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.Reader.Close()
+	if err2 := g.f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+package worker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+)
+
+func TestDLQSaveWritesDataAndMeta(t *testing.T) {
+	cfg := config.Config{DLQDir: t.TempDir(), InstanceID: "test"}
+	d := NewDLQManager(cfg, metrics.New(), nil)
+
+	if err := d.Save([]byte("payload"), dlqMeta{NumEvents: 3, S3Key: "raw/dt=2024-01-01/hr=00/x.jsonl.gz"}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	entries, err := os.ReadDir(cfg.DLQDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("DLQ dir has %d entries, want data + meta (no temp files)", len(entries))
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Fatalf("temp file left behind: %s", e.Name())
+		}
+		if strings.HasSuffix(e.Name(), ".meta.json") {
+			meta := readMeta(filepath.Join(cfg.DLQDir, e.Name()))
+			if meta.NumEvents != 3 || meta.S3Key == "" {
+				t.Fatalf("meta = %+v", meta)
+			}
+		}
+	}
+}
+
+// 용량 부족으로 버린 배치는 에러를 반환해야 호출자가 WAL Ack 를 하지 않는다.
+func TestDLQSaveReturnsErrorWhenFull(t *testing.T) {
+	cfg := config.Config{DLQDir: t.TempDir(), InstanceID: "test", DLQMaxSizeBytes: 4}
+	m := metrics.New()
+	d := NewDLQManager(cfg, m, nil)
+
+	err := d.Save([]byte("too large"), dlqMeta{NumEvents: 2})
+	if !errors.Is(err, errDLQFull) {
+		t.Fatalf("err = %v, want errDLQFull", err)
+	}
+	if m.DLQEventsDroppedTotal != 2 {
+		t.Fatalf("DLQEventsDroppedTotal = %d, want 2", m.DLQEventsDroppedTotal)
+	}
+}
+
+// 여러 upload worker 가 동시에 Save → eviction 해도 파일 수/용량 회계가 디스크와 일치해야 한다.
+func TestDLQConcurrentSaveAccounting(t *testing.T) {
+	cfg := config.Config{DLQDir: t.TempDir(), InstanceID: "test", DLQMaxSizeBytes: 10 * 100}
+	m := metrics.New()
+	d := NewDLQManager(cfg, m, nil)
+
+	payload := []byte(strings.Repeat("x", 100))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if err := d.Save(payload, dlqMeta{NumEvents: 1}); err != nil {
+					t.Errorf("Save: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var files, size int64
+	entries, err := os.ReadDir(cfg.DLQDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if !isDLQDataName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files++
+		size += info.Size()
+	}
+
+	if m.DLQFilesCurrent != files {
+		t.Errorf("DLQFilesCurrent = %d, files on disk = %d", m.DLQFilesCurrent, files)
+	}
+	if m.DLQSizeBytes != size || d.dlqSizeBytes != size {
+		t.Errorf("DLQSizeBytes = %d / %d, bytes on disk = %d", m.DLQSizeBytes, d.dlqSizeBytes, size)
+	}
+	if m.DLQFilesExpiredTotal != 160-files {
+		t.Errorf("DLQFilesExpiredTotal = %d, want %d", m.DLQFilesExpiredTotal, 160-files)
+	}
+}
+
+// dlqctl 이 서버 밖에서 파일을 지워도 Rescan 후에는 회계가 디스크와 일치해야 한다.
+func TestDLQRescanAfterExternalRemoval(t *testing.T) {
+	cfg := config.Config{DLQDir: t.TempDir(), InstanceID: "test"}
+	m := metrics.New()
+	d := NewDLQManager(cfg, m, nil)
+
+	for i := 0; i < 3; i++ {
+		if err := d.Save([]byte("payload"), dlqMeta{NumEvents: 1}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	// 별도 프로세스(dlqctl)의 purge
+	other := OpenDLQManager(cfg, metrics.New(), nil)
+	files, err := other.List()
+	if err != nil || len(files) != 3 {
+		t.Fatalf("List = %v, %v", files, err)
+	}
+	if err := other.Purge(files[0].Name); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+
+	d.Rescan()
+	if m.DLQFilesCurrent != 2 || m.DLQSizeBytes != 2*int64(len("payload")) {
+		t.Fatalf("after rescan files=%d size=%d", m.DLQFilesCurrent, m.DLQSizeBytes)
+	}
+}
+
+// 읽기 전용 open 은 디렉토리를 만들거나 orphan meta 를 지우지 않는다.
+func TestOpenDLQManagerIsReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	orphan := filepath.Join(dir, "1_x_000001.jsonl.gz.meta.json")
+	if err := os.WriteFile(orphan, []byte(`{}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	OpenDLQManager(config.Config{DLQDir: dir}, metrics.New(), nil)
+	if _, err := os.Stat(orphan); err != nil {
+		t.Fatalf("orphan meta removed: %v", err)
+	}
+
+	missing := filepath.Join(dir, "missing")
+	OpenDLQManager(config.Config{DLQDir: missing}, metrics.New(), nil)
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Fatalf("DLQ dir created: %v", err)
+	}
+}
+
+// S3Key 를 쓸 수 없는 파일은 메타에 기록된 tenant 의 prefix 로 재업로드 key 를 만든다.
+func TestDLQReplayKeyFallback(t *testing.T) {
+	loc := Location()
+	SetLocation(time.UTC)
+	t.Cleanup(func() { SetLocation(loc) })
+
+	d := &DLQManager{cfg: config.Config{RawPrefix: "raw", DLQPrefix: "dlq"}}
+	const name = "1700000000_test_1.jsonl.gz" // 2023-11-14 22:13 UTC
+	tenantMeta := dlqMeta{
+		NumEvents: 1,
+		S3Key:     "events/shop/dt=2023-11-14/hr=22/1700000000_test_0.jsonl.gz",
+		Tenant:    "shop",
+		Bucket:    "shop-raw",
+		Prefix:    "events/shop",
+		DLQPrefix: "dlq/shop",
+	}
+
+	tests := []struct {
+		name  string
+		meta  dlqMeta
+		valid bool
+		want  string
+	}{
+		{"valid with key", tenantMeta, true, tenantMeta.S3Key},
+		{"invalid → tenant DLQ prefix", tenantMeta, false, "dlq/shop/dt=2023-11-14/hr=22/" + name},
+		{"valid without key → tenant prefix", dlqMeta{Tenant: "shop", Prefix: "events/shop", DLQPrefix: "dlq/shop"}, true, "events/shop/dt=2023-11-14/hr=22/" + name},
+		{"legacy tenant meta", dlqMeta{Tenant: "shop"}, false, "dlq/shop/dt=2023-11-14/hr=22/" + name},
+		{"no meta", dlqMeta{}, true, "raw/dt=2023-11-14/hr=22/" + name},
+	}
+	for _, tt := range tests {
+		if got := d.replayKey(name, tt.meta, tt.valid); got != tt.want {
+			t.Errorf("%s: replayKey = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package worker
 
 import (
 	"bytes"
+	"io"
 
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
 	"estat-ingest/internal/model"
 	"estat-ingest/internal/pool"
 
@@ -13,10 +16,22 @@ import (
 // Encoder 는 이벤트 배치를 JSONL → gzip 형태로 직렬화하는 컴포넌트.
 // 전체 ingest 파이프라인에서 CPU 사용량과 메모리 사용량에
 // 가장 큰 영향을 주는 핵심 구간이다.
-type Encoder struct{}
+//
+// BODY_FORMAT=structured 이면 인코딩 직전에 body 를 params / body_json 으로 구조화한다 (structured.go).
+type Encoder struct {
+	structured bool
+	maxKeys    int
+	maxBytes   int
+	metrics    *metrics.Metrics
+}
 
-func NewEncoder() *Encoder {
-	return &Encoder{}
+func NewEncoder(cfg config.Config, m *metrics.Metrics) *Encoder {
+	return &Encoder{
+		structured: cfg.BodyFormat == "structured",
+		maxKeys:    cfg.StructuredMaxKeys,
+		maxBytes:   cfg.StructuredMaxBytes,
+		metrics:    m,
+	}
 }
 
 // EncodeBatchJSONLGZ
@@ -34,54 +49,63 @@ func NewEncoder() *Encoder {
 func (e *Encoder) EncodeBatchJSONLGZ(events []*model.Event) (*bytes.Buffer, error) {
 
 	// ------------------------------------------------------------
-	// 1) gzip 결과를 담을 bytes.Buffer 를 pool에서 가져온다.
+	// gzip 결과를 담을 bytes.Buffer 를 pool에서 가져온다.
 	// ------------------------------------------------------------
 	buf := pool.BufferPool.Get().(*bytes.Buffer)
 	buf.Reset()
 
+	if err := e.EncodeBatchJSONLGZTo(buf, events); err != nil {
+		pool.PutBuffer(buf) // 실패했으므로 즉시 반환(폐기)
+		return nil, err
+	}
+
+	// ------------------------------------------------------------
+	// [최적화 핵심]
+	// make([]byte) + copy() 과정을 제거한다.
+	// 5MB 배치를 처리할 때, 복사본을 만들면 순간 10MB가 필요하지만
+	// 포인터만 넘기면 5MB로 끝난다. (OOM 방지 핵심)
+	// ------------------------------------------------------------
+	return buf, nil
+}
+
+// EncodeBatchJSONLGZTo
+//
+// events 를 JSONL + gzip 으로 w 에 직접 기록한다.
+// 결과 전체를 메모리에 두지 않아야 하는 multipart streaming 업로드(S3PartSize 단위 part)와
+// 그 실패 시 DLQ 파일 spill 에서 사용한다.
+func (e *Encoder) EncodeBatchJSONLGZTo(w io.Writer, events []*model.Event) error {
+
 	// ------------------------------------------------------------
-	// 2) gzip.Writer 를 pool에서 가져오고 buffer로 reset
+	// 1) gzip.Writer 를 pool에서 가져오고 w 로 reset
 	// ------------------------------------------------------------
 	gz := pool.GzipPool.Get().(*gzip.Writer)
-	gz.Reset(buf)
+	gz.Reset(w)
+	defer pool.GzipPool.Put(gz)
 
 	// ------------------------------------------------------------
-	// 3) goccy/go-json encoder 생성 (gzip writer에 직결)
+	// 2) goccy/go-json encoder 생성 (gzip writer에 직결)
 	// ------------------------------------------------------------
 	enc := json.NewEncoder(gz)
 
 	// ------------------------------------------------------------
-	// 4) JSONL 인코딩
+	// 3) JSONL 인코딩
 	//    이벤트마다 한 줄씩 JSON 인코딩 → gz writer로 바로 write
 	// ------------------------------------------------------------
 	for _, ev := range events {
+		if e.structured {
+			e.structureBody(ev)
+		}
 		if err := enc.Encode(ev); err != nil {
-			// 실패 시 자원 정리: Gzip Writer 닫고 버퍼 반환
 			_ = gz.Close()
-			pool.GzipPool.Put(gz)
-			pool.PutBuffer(buf) // 실패했으므로 즉시 반환(폐기)
-			return nil, err
+			return err
 		}
 	}
 
 	// ------------------------------------------------------------
-	// 5) gzip footer flush & close
+	// 4) gzip footer flush & close
 	//    Close() 시 압축 스트림이 완성됨.
 	// ------------------------------------------------------------
-	if err := gz.Close(); err != nil {
-		pool.GzipPool.Put(gz)
-		pool.PutBuffer(buf)
-		return nil, err
-	}
-	pool.GzipPool.Put(gz)
-
-	// ------------------------------------------------------------
-	// [최적화 핵심]
-	// make([]byte) + copy() 과정을 제거한다.
-	// 5MB 배치를 처리할 때, 복사본을 만들면 순간 10MB가 필요하지만
-	// 포인터만 넘기면 5MB로 끝난다. (OOM 방지 핵심)
-	// ------------------------------------------------------------
-	return buf, nil
+	return gz.Close()
 }
 
 // RecycleEvents 는 이벤트 slice 내 개별 Event 객체를 초기화 후
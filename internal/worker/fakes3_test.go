@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3 는 path-style S3 API 중 업로드에 쓰는 호출만 흉내 내는 in-process 서버이다.
+// (PutObject / CreateMultipartUpload / UploadPart / CompleteMultipartUpload / AbortMultipartUpload)
+type fakeS3 struct {
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	objects map[string][]byte         // "bucket/key" → body
+	uploads map[string]map[int][]byte // uploadId → partNumber → body
+	aborted int
+	nextID  int
+	auth    string // 마지막 요청의 Authorization 헤더
+
+	// fail 이 0 이 아닌 status 를 반환하면 해당 요청을 그 status 의 S3 오류로 응답한다. (setFail 로 설정)
+	fail func(r *http.Request) int
+}
+
+func (f *fakeS3) setFail(fn func(r *http.Request) int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = fn
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+	f := &fakeS3{
+		objects: make(map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+	}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// client 는 fake 서버를 가리키는 S3 client 를 만든다. SDK 재시도는 끈다 (앱 재시도만 사용).
+func (f *fakeS3) client() *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(f.srv.URL),
+		UsePathStyle: true,
+		Credentials:  aws.AnonymousCredentials{},
+		Retryer:      aws.NopRetryer{},
+	})
+}
+
+func (f *fakeS3) object(path string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.objects[path]
+	return b, ok
+}
+
+// snapshot 은 저장된 객체의 복사본을 반환한다.
+func (f *fakeS3) snapshot() map[string][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string][]byte, len(f.objects))
+	for k, v := range f.objects {
+		out[k] = v
+	}
+	return out
+}
+
+func (f *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.auth = r.Header.Get("Authorization")
+	fail := f.fail
+	f.mu.Unlock()
+	if fail != nil {
+		if code := fail(r); code != 0 {
+			writeS3Error(w, code)
+			return
+		}
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	q := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound)
+			return
+		}
+		n, _ := strconv.Atoi(q.Get("partNumber"))
+		parts[n] = body
+		w.Header().Set("ETag", fmt.Sprintf("%q", "etag-"+strconv.Itoa(n)))
+
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		parts, ok := f.uploads[q.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound)
+			return
+		}
+		nums := make([]int, 0, len(parts))
+		for n := range parts {
+			nums = append(nums, n)
+		}
+		sort.Ints(nums)
+		var obj bytes.Buffer
+		for _, n := range nums {
+			obj.Write(parts[n])
+		}
+		f.objects[path] = obj.Bytes()
+		delete(f.uploads, q.Get("uploadId"))
+		fmt.Fprint(w, "<CompleteMultipartUploadResult><ETag>\"done\"</ETag></CompleteMultipartUploadResult>")
+
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		delete(f.uploads, q.Get("uploadId"))
+		f.aborted++
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		f.objects[path] = body
+		w.Header().Set("ETag", `"put"`)
+
+	default:
+		writeS3Error(w, http.StatusNotImplemented)
+	}
+}
+
+// s3StatusCodes 는 fake 오류 status 별로 응답할 S3 오류 코드이다.
+var s3StatusCodes = map[int]string{
+	http.StatusForbidden:           "AccessDenied",
+	http.StatusNotFound:            "NoSuchBucket",
+	http.StatusInternalServerError: "InternalError",
+	http.StatusServiceUnavailable:  "SlowDown",
+}
+
+func writeS3Error(w http.ResponseWriter, status int) {
+	code, ok := s3StatusCodes[status]
+	if !ok {
+		code = fmt.Sprintf("Fake%d", status)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>fake error</Message></Error>", code)
+}
@@ -3,7 +3,15 @@ package worker
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync/atomic"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/partition"
+
+	"github.com/rs/zerolog/log"
 )
 
 // file_util.go
@@ -48,15 +56,93 @@ func NewFilename(instanceID string) string {
 	return fmt.Sprintf("%d_%s_%06d.jsonl.gz", sec, instanceID, c)
 }
 
+// writeFileDurable
+// ------------------------------------------------------------
+// path 와 같은 디렉토리의 임시 파일(.tmp-*)에 fill 로 내용을 채우고
+// fsync → rename → 디렉토리 fsync 순서로 저장한다.
+//
+// 반환값이 nil 이면 내용과 디렉토리 엔트리 모두 디스크에 반영된 상태이므로,
+// 호출자는 이후 WAL Ack 등 "저장 완료"를 전제로 한 처리를 해도 된다.
+// 도중에 죽더라도 반쯤 쓰인 파일이 path 로 보이지 않는다
+// (임시 파일은 '.' 으로 시작하므로 DLQ pickOldest 대상에서도 제외된다).
+func writeFileDurable(path string, fill func(*os.File) error) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // rename 성공 시에는 no-op
+
+	if err := fill(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir 는 디렉토리를 fsync 하여 생성/rename 된 엔트리를 디스크에 반영한다.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// keyTemplate 은 BuildS3Key 가 사용하는 S3 key 템플릿이다.
+// 기본값은 partition.DefaultTemplate 이며, NewManager 에서 S3_KEY_TEMPLATE 로 교체된다.
+var keyTemplate atomic.Pointer[partition.Template]
+
+func init() {
+	keyTemplate.Store(partition.MustParse(partition.DefaultTemplate))
+}
+
+// SetKeyTemplate 은 BuildS3Key 가 사용할 key 템플릿을 교체한다.
+func SetKeyTemplate(t *partition.Template) {
+	keyTemplate.Store(t)
+}
+
+// ConfigurePartitioning 은 cfg 의 파티션 타임존(PARTITION_TZ)과
+// key 템플릿(S3_KEY_TEMPLATE)을 timecache / BuildS3Key 에 적용한다.
+// 두 값은 config.Load 에서 이미 검증되었으므로 여기서 실패하면 즉시 종료한다.
+func ConfigurePartitioning(cfg config.Config) {
+	loc, err := time.LoadLocation(cfg.PartitionTimezone)
+	if err != nil {
+		log.Fatal().Err(err).Str("tz", cfg.PartitionTimezone).Msg("invalid partition timezone")
+	}
+	SetLocation(loc)
+	SetKeyTemplate(partition.MustParse(cfg.S3KeyTemplate))
+}
+
 // BuildS3Key
 // ------------------------------------------------------------
 // 표준화된 S3 Key 생성기.
-// S3 폴더 구조(Partitioning):
+// S3 폴더 구조(Partitioning)는 S3_KEY_TEMPLATE 으로 정의되며, 기본값은:
 //
 //	<prefix>/dt=<YYYY-MM-DD>/hr=<HH>/<filename>
 //
 // Athena / Glue 파티션 스캔 비용을 줄이기 위한 표준적인 구조.
-// ingest 내부에서는 prefix(RAW, DLQ)만 구분해 주면 된다.
-func BuildS3Key(prefix, filename string) string {
-	return fmt.Sprintf("%s/dt=%s/hr=%s/%s", prefix, DT(), HR(), filename)
+// 파티션 값은 timecache 가 캐싱한 현재 시각(PARTITION_TZ 기준)을 사용한다.
+// ingest 내부에서는 prefix(RAW, DLQ)와 tenant({tenant}, single-tenant 이면 빈 값)만 넘겨주면 된다.
+func BuildS3Key(prefix, tenant, filename string) string {
+	return keyTemplate.Load().Render(prefix, tenant, filename, Parts())
+}
+
+// BuildS3KeyAt 는 현재 시각 대신 sec(UTC epoch seconds) 기준 파티션으로 key 를 만든다.
+// DLQ 재업로드 시 배치가 원래 속했던 파티션을 유지하기 위해 사용한다.
+func BuildS3KeyAt(prefix, tenant, filename string, sec int64) string {
+	p := partition.PartsOf(time.Unix(sec, 0), Location())
+	return keyTemplate.Load().Render(prefix, tenant, filename, p)
 }
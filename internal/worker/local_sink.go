@@ -0,0 +1,75 @@
+// internal/worker/local_sink.go
+package worker
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"estat-ingest/internal/config"
+)
+
+// LocalSink 는 배치를 로컬 디렉토리에 저장하는 Sink 구현체이다.
+//
+// S3 key 를 그대로 상대 경로로 사용하므로,
+//
+//	<LocalSinkDir>/<prefix>/dt=YYYY-MM-DD/hr=HH/<file>.jsonl.gz
+//
+// 형태로 저장되며 S3 와 동일한 파티션 구조를 눈으로 확인할 수 있다.
+// tenant 버킷이 지정된 배치는 <LocalSinkDir>/<bucket>/<key> 에 저장된다.
+//
+// 쓰기는 임시 파일 → fsync → rename → 디렉토리 fsync 순서로 수행하여 (writeFileDurable),
+// 도중에 프로세스가 죽더라도 반쯤 쓰인 .jsonl.gz 가 남지 않고,
+// 성공을 반환한 배치는 crash 후에도 남아있도록 한다 (WAL Ack 전제 조건).
+type LocalSink struct {
+	dir string
+}
+
+// NewLocalSink 는 루트 디렉토리를 생성하고 LocalSink 를 반환한다.
+func NewLocalSink(cfg config.Config) *LocalSink {
+	_ = os.MkdirAll(cfg.LocalSinkDir, 0o755)
+	return &LocalSink{dir: cfg.LocalSinkDir}
+}
+
+// PutBytes 는 body 를 bucket/key 경로에 저장한다.
+func (s *LocalSink) PutBytes(ctx context.Context, bucket, key string, body []byte) error {
+	return s.write(ctx, bucket, key, func(f *os.File) error {
+		_, err := f.Write(body)
+		return err
+	})
+}
+
+// PutReader 는 r 의 내용을 bucket/key 경로에 저장한다.
+func (s *LocalSink) PutReader(ctx context.Context, bucket, key string, r io.ReadSeeker, size int64) error {
+	return s.write(ctx, bucket, key, func(f *os.File) error {
+		_, err := io.CopyN(f, r, size)
+		return err
+	})
+}
+
+// PutStream 은 write 가 쓰는 내용을 bucket/key 경로에 저장한다.
+func (s *LocalSink) PutStream(ctx context.Context, bucket, key string, write func(io.Writer) error) error {
+	return s.write(ctx, bucket, key, func(f *os.File) error {
+		return write(f)
+	})
+}
+
+// Available 은 항상 true 이다 (로컬 디스크에는 circuit breaker 가 없다).
+func (s *LocalSink) Available() bool {
+	return true
+}
+
+// write 는 bucket/key 경로의 파티션 디렉토리를 만들고 fill 로 내용을 채워 durable 하게 저장한다.
+func (s *LocalSink) write(ctx context.Context, bucket, key string, fill func(*os.File) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, bucket, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return writeFileDurable(path, fill)
+}
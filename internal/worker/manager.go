@@ -4,6 +4,9 @@ package worker
 import (
 	"bytes"
 	"context"
+	"errors"
+	"io"
+	"path"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,14 +23,14 @@ import (
 //
 // HTTP 핸들러가 EventCh 로 넘긴 이벤트들을 모아서(batch):
 //  1. JSONL + gzip 으로 인코딩하고
-//  2. S3 RAW Prefix 로 업로드하며
+//  2. Sink(S3 또는 로컬 파일시스템)의 RAW Prefix 로 업로드하며
 //  3. 실패 시 로컬 DLQ 에 저장하고 재업로드를 시도한다.
 //
 // 주요 구성 요소:
 //   - EventCh   : HTTP 수집 → Manager 로 이벤트 전달 (백프레셔의 첫 단계)
 //   - collectLoop : EventCh 를 읽어 배치로 모으고, uploadCh 로 전달
 //   - uploadCh  : 인코딩 + S3 업로드 작업 큐
-//   - uploadLoop  : 실제 인코딩 + 업로드 + DLQ 재업로드 담당
+//   - uploadLoop  : 실제 인코딩 + 업로드 + DLQ 재업로드 담당 (UploadWorkers 개 병렬 실행)
 //
 // Shutdown 설계:
 //   - Graceful drain 패턴을 사용한다.
@@ -38,13 +41,22 @@ import (
 type Manager struct {
 	cfg     config.Config
 	metrics *metrics.Metrics
-	s3      *S3Uploader
+	sink    Sink
 	dlq     *DLQManager
 	encoder *Encoder
+	schema  *SchemaValidator // SCHEMA_MODE=off 이면 nil
 
 	EventCh  chan *model.Event    // HTTP 수집기가 push 하는 이벤트 큐
 	uploadCh chan model.UploadJob // 인코딩/업로드 작업 큐
 
+	// WAL 은 WALEnabled 일 때만 생성된다 (비활성화 시 nil, 메서드는 no-op).
+	// HTTP 핸들러는 EventCh push 전에 WAL.Append 를 호출한다.
+	WAL *WAL
+
+	// recovered 는 시작 시 WAL 에서 복구한 이벤트이며,
+	// collectLoop 가 EventCh 보다 먼저 배치로 흘려보낸다.
+	recovered []*model.Event
+
 	ctx    context.Context
 	cancel context.CancelFunc
 
@@ -52,30 +64,60 @@ type Manager struct {
 	stopOnce sync.Once
 }
 
-// NewManager는 S3Uploader · DLQManager · Encoder 를 초기화하고
+// NewManager는 Sink(cfg.SinkType) · DLQManager · Encoder 를 초기화하고
 // 이벤트 처리 채널(EventCh, uploadCh)을 생성한다.
+// 파티션 타임존(PARTITION_TZ)과 S3 key 템플릿(S3_KEY_TEMPLATE)도 여기서 적용되므로,
+// 정상 업로드와 DLQ 재업로드 모두 같은 key 구조를 사용한다.
+//
+// WALEnabled 인 경우 WAL 디렉토리에 남아있는 세그먼트(이전 프로세스의 비정상 종료 흔적)를
+// 읽어 이벤트를 복구하며, 복구된 이벤트는 Start() 이후 가장 먼저 업로드된다.
 //
 // 실제 goroutine 실행은 Start() 호출 시점에 이루어진다.
 func NewManager(cfg config.Config, m *metrics.Metrics) *Manager {
-	uploader := NewS3Uploader(cfg, m)
-	dlq := NewDLQManager(cfg, m, uploader)
-	encoder := NewEncoder()
+	ConfigurePartitioning(cfg)
+
+	sink := NewSink(cfg, m)
+	dlq := NewDLQManager(cfg, m, sink)
+	encoder := NewEncoder(cfg, m)
 
-	return &Manager{
+	m.InitUploadWorkers(cfg.UploadWorkers)
+
+	mgr := &Manager{
 		cfg:      cfg,
 		metrics:  m,
-		s3:       uploader,
+		sink:     sink,
 		dlq:      dlq,
 		encoder:  encoder,
+		schema:   NewSchemaValidator(cfg, m),
 		EventCh:  make(chan *model.Event, cfg.ChannelSize),
 		uploadCh: make(chan model.UploadJob, cfg.UploadQueue),
 	}
+
+	if cfg.WALEnabled {
+		wal, recovered, err := OpenWAL(cfg, m)
+		if err != nil {
+			log.Fatal().Err(err).Str("dir", cfg.WALDir).Msg("failed to open WAL")
+		}
+		mgr.WAL = wal
+		mgr.recovered = recovered
+
+		if len(recovered) > 0 {
+			atomic.AddInt64(&m.WALEventsReplayedTotal, int64(len(recovered)))
+			log.Warn().
+				Int("events", len(recovered)).
+				Msg("WAL replay: recovered events from previous run")
+		}
+	}
+
+	return mgr
 }
 
 // Start는 ingest 파이프라인 처리용 goroutine 을 시작한다.
 //
 //   - collectLoop: EventCh 에서 이벤트를 받아 배치로 모으고 uploadCh 로 전달.
 //   - uploadLoop : uploadCh 를 소비하면서 인코딩 + S3 업로드 + DLQ 재업로드 수행.
+//     UploadWorkers 개의 worker 가 같은 uploadCh 를 공유하므로,
+//     느린 PutObject 하나가 전체 업로드를 멈추지 않는다.
 //
 // ctx/cancel 은 S3Uploader, DLQ 처리 등의 내부 호출에서
 // per-request timeout 을 묶어주는 용도로 사용되며,
@@ -83,9 +125,12 @@ func NewManager(cfg config.Config, m *metrics.Metrics) *Manager {
 func (m *Manager) Start() {
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 
-	m.wg.Add(2)
+	// UploadWorkers 는 optInt 로 로드되므로 항상 1 이상이다.
+	m.wg.Add(1 + m.cfg.UploadWorkers)
 	go m.collectLoop()
-	go m.uploadLoop()
+	for id := 0; id < m.cfg.UploadWorkers; id++ {
+		go m.uploadLoop(id)
+	}
 }
 
 // Shutdown 은 graceful drain 을 수행한다.
@@ -93,7 +138,7 @@ func (m *Manager) Start() {
 // 순서:
 //  1. EventCh 를 닫아서 더 이상 신규 이벤트를 받지 않는다.
 //  2. collectLoop 가 남아있는 배치를 모두 flush 한 뒤 uploadCh 를 닫는다.
-//  3. uploadLoop 가 uploadCh 를 모두 비우고 DLQ 재업로드까지 끝내면 종료된다.
+//  3. 모든 uploadLoop worker 가 uploadCh 를 나눠서 비우고 나면 종료된다.
 //  4. 모든 goroutine 종료를 기다린 뒤, cancel() 로 백그라운드 자원을 정리한다.
 //
 // 주의:
@@ -106,28 +151,38 @@ func (m *Manager) Shutdown() {
 		close(m.EventCh)
 	})
 
-	// 모든 goroutine (collectLoop, uploadLoop) 종료 대기
+	// 모든 goroutine (collectLoop, uploadLoop worker 전체) 종료 대기
 	m.wg.Wait()
 
+	// 모든 배치가 저장(Ack)된 뒤 WAL 을 닫는다. (정상 종료 시 WAL 디렉토리는 비워진다)
+	m.WAL.Close()
+
 	// 마지막으로 context 취소 → 내부에서 ctx 를 참조하는 작업이 있다면 정리
 	if m.cancel != nil {
 		m.cancel()
 	}
 }
 
-// collectLoop 는 EventCh 에서 이벤트를 읽어 배치로 묶은 뒤,
-// BatchSize 또는 FlushInterval 조건이 만족되면 uploadCh 로 전달한다.
+// collectLoop 는 EventCh 에서 이벤트를 읽어 tenant 별 배치로 묶은 뒤,
+// 다음 중 하나가 만족되면 uploadCh 로 전달한다 (batch_flush_<trigger>_total).
+//
+//   - count : 이벤트 수가 BatchSize 에 도달
+//   - bytes : 이벤트 크기 합(압축 전 추정치, eventSize)이 BatchMaxBytes 에 도달
+//   - age   : FlushInterval 경과
+//
+// 배치는 tenant(Event.Tenant) 별로 따로 모으므로 한 객체에 여러 tenant 가 섞이지 않는다.
+// single-tenant(TENANTS_FILE 미설정)에서는 tenant "" 배치 하나만 사용한다.
 //
 // Shutdown 시나리오:
 //   - Shutdown() 이 EventCh 를 닫는다.
 //   - 여기서는 `<-EventCh` 의 ok=false 를 감지하여
-//     남아 있는 batch 를 마지막으로 flush 한 뒤 종료한다.
+//     남아 있는 batch 를 마지막으로 flush 한 뒤 종료한다 (trigger=shutdown).
 //   - 이때는 ctx.Done() 과 경쟁하지 않으며, 데이터를 drop 하지 않는다.
 func (m *Manager) collectLoop() {
 	defer m.wg.Done()
-	defer close(m.uploadCh) // 더 이상 배치가 없음을 uploadLoop 에 알림
+	defer close(m.uploadCh) // 더 이상 배치가 없음을 모든 uploadLoop worker 에 알림
 
-	batch := make([]*model.Event, 0, m.cfg.BatchSize)
+	batches := make(map[string]*pendingBatch)
 
 	timer := time.NewTimer(m.cfg.FlushInterval)
 	defer timer.Stop()
@@ -144,46 +199,92 @@ func (m *Manager) collectLoop() {
 		timer.Reset(m.cfg.FlushInterval)
 	}
 
-	// 일반적인 flush: uploadCh 로 block 전송.
+	// 일반적인 flush: tenant 하나의 배치를 uploadCh 로 block 전송.
 	// - 여기서는 ctx.Done() 을 보지 않는다.
 	//   (backpressure 를 그대로 전파하여 상위에서 속도 조절)
-	flush := func() {
-		if len(batch) == 0 {
+	// - 다른 tenant 의 배치가 남아있으면 타이머를 유지한다.
+	//   (한 tenant 의 count flush 가 다른 tenant 배치의 시간 flush 를 미루지 않도록)
+	flush := func(tenant string, trigger *int64) {
+		b := batches[tenant]
+		if b == nil || len(b.events) == 0 {
 			return
 		}
-		m.uploadCh <- model.UploadJob{Events: batch} // 필요 시 여기서 block 되어 backpressure
-		batch = make([]*model.Event, 0, m.cfg.BatchSize)
+		m.uploadCh <- model.UploadJob{Tenant: tenant, Events: b.events} // 필요 시 여기서 block 되어 backpressure
+		atomic.AddInt64(trigger, 1)
+		delete(batches, tenant)
+		if len(batches) == 0 {
+			resetTimer()
+		}
+	}
+
+	// 시간 기반 / 종료 시 flush: 모든 tenant 배치를 보내고, 비어있었더라도 타이머를 다시 건다.
+	flushAll := func(trigger *int64) {
+		for tenant := range batches {
+			flush(tenant, trigger)
+		}
 		resetTimer()
 	}
 
+	add := func(ev *model.Event) {
+		b := batches[ev.Tenant]
+		if b == nil {
+			b = &pendingBatch{events: make([]*model.Event, 0, m.cfg.BatchSize)}
+			batches[ev.Tenant] = b
+		}
+		b.events = append(b.events, ev)
+		b.bytes += eventSize(ev)
+
+		switch {
+		case len(b.events) >= m.cfg.BatchSize:
+			flush(ev.Tenant, &m.metrics.BatchFlushCountTotal)
+		case m.cfg.BatchMaxBytes > 0 && b.bytes >= m.cfg.BatchMaxBytes:
+			flush(ev.Tenant, &m.metrics.BatchFlushBytesTotal)
+		}
+	}
+
+	// WAL replay 이벤트를 EventCh 보다 먼저 흘려보낸다.
+	for _, ev := range m.recovered {
+		add(ev)
+	}
+	m.recovered = nil
+
 	for {
 		select {
 		case ev, ok := <-m.EventCh:
 			if !ok {
 				// EventCh 가 닫혔다는 것은 Shutdown 시작을 의미한다.
 				// 남아 있는 batch 를 마지막으로 업로드 시도 후 종료.
-				flush()
+				flushAll(&m.metrics.BatchFlushShutdownTotal)
 				return
 			}
-
-			batch = append(batch, ev)
-			if len(batch) >= m.cfg.BatchSize {
-				flush()
-			}
+			add(ev)
 
 		case <-timer.C:
 			// 시간 기반 flush (트래픽이 적을 때도 일정 간격으로 업로드)
-			flush()
+			flushAll(&m.metrics.BatchFlushAgeTotal)
 		}
 	}
 }
 
+// pendingBatch 는 collectLoop 가 모으고 있는 tenant 하나의 배치이다.
+type pendingBatch struct {
+	events []*model.Event
+	bytes  int64 // eventSize 합
+}
+
+// eventSize 는 이벤트의 압축 전 크기 추정치이다 (가변 길이 필드 합).
+// 고정 필드(ts, ip_mode, geo 등)는 이벤트마다 거의 같으므로 BatchSize 쪽에서 이미 제한된다.
+func eventSize(ev *model.Event) int64 {
+	return int64(len(ev.Body) + len(ev.UserAgent) + len(ev.Cookie) + len(ev.IP))
+}
+
 // uploadLoop 는 uploadCh 에서 배치를 꺼내 실제 업로드를 수행한다.
+// Start() 에서 UploadWorkers 개가 실행되며, id 는 0 부터 시작하는 worker 번호이다.
 //
 // 주요 책임:
 //  1. 배치 인코딩 (JSONL + gzip)
 //  2. S3 RAW prefix 로 업로드 (실패 시 로컬 DLQ 저장)
-//  3. idle 상태일 때만 DLQ 재업로드를 소량 수행 (ProcessOneCtx)
+//  3. idle 상태일 때만 DLQ 재업로드를 소량 수행 (ProcessOneCtx, worker 0 전담)
 //
 // 중요 설계 원칙:
 //   - UploadLoop는 ingest 서버의 "핫 패스(hot path)"이다.
@@ -191,16 +292,30 @@ func (m *Manager) collectLoop() {
 //     업로드 처리 중에는 DLQ 처리를 절대 섞지 않는다.
 //   - DLQ 처리는 서버가 idle 일 때만 1건씩 천천히 처리하여
 //     UploadLoop 성능을 침해하지 않도록 설계한다.
+//   - DLQ 재업로드는 worker 0 만 수행한다.
+//     (여러 worker 가 동시에 재처리하면 DLQ backlog 가 업로드 경로의 worker 를 빼앗는다.
+//     파일 선택/삭제 자체는 DLQManager.mu 로 직렬화되어 있어 Save 와 경쟁해도 안전하다)
 //
 // 종료 조건:
 //   - collectLoop 가 uploadCh 를 닫으면 recv 시 ok=false 가 되어 종료된다.
 //   - ctx.Done() 을 select 로 감시하지 않는다.
 //     (ctx 취소 시 premature termination 발생 → UploadCh 잔여 배치 유실 위험)
-func (m *Manager) uploadLoop() {
+func (m *Manager) uploadLoop(id int) {
 	defer m.wg.Done()
 
-	ticker := time.NewTicker(50 * time.Millisecond)
-	defer ticker.Stop()
+	busy := &m.metrics.UploadWorkerBusy[id]
+
+	// DLQ 재처리 / rescan ticker 는 worker 0 에만 둔다. (nil 채널은 select 에서 영원히 block)
+	var tickC, rescanC <-chan time.Time
+	if id == 0 {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		tickC = ticker.C
+
+		rescan := time.NewTicker(m.cfg.DLQRescanInterval)
+		defer rescan.Stop()
+		rescanC = rescan.C
+	}
 
 	for {
 		select {
@@ -214,24 +329,31 @@ func (m *Manager) uploadLoop() {
 			// - JSONL + gzip 인코딩
 			// - S3 RAW 업로드
 			// - 실패 시 로컬 DLQ 저장
+			atomic.StoreInt64(busy, 1)
 			m.processUploadCtx(m.ctx, job)
+			atomic.StoreInt64(busy, 0)
 
 			// 업로드가 진행되고 있는 동안에는 DLQ 를 건드리지 않는다.
 			// (업로드 경로의 throughput 저하 방지)
 
 		// 2) idle 상태일 때만 DLQ 재처리 1건 진행
-		case <-ticker.C:
+		case <-tickC:
 			// UploadLoop 가 당장 처리할 job 이 없는 경우에만
 			// DLQ 파일 한 건을 재업로드 시도한다.
 			m.dlq.ProcessOneCtx(m.ctx)
+
+		// 3) dlqctl 등 외부 변경을 반영하도록 DLQ 회계를 디스크 기준으로 재계산
+		case <-rescanC:
+			m.dlq.Rescan()
 		}
 	}
 }
 
 // processUploadCtx 는 하나의 이벤트 배치에 대해
-//  1. JSONL + gzip 인코딩 (Zero-Copy)
-//  2. S3 업로드 (실패 시 로컬 DLQ 저장)
-//  3. 사용 완료된 버퍼 반환 및 이벤트 객체 재사용
+//  1. 저장 위치(prefix)별로 이벤트 분리
+//     (SCHEMA_MODE=enforce 이면 검증 실패 → InvalidPrefix, UA_BOT_POLICY=route 이면 봇 → BotPrefix)
+//  2. prefix 별 JSONL + gzip 인코딩 후 S3 업로드 (실패 시 로컬 DLQ 저장)
+//  3. 이벤트 객체 재사용
 //
 // 을 수행한다.
 func (m *Manager) processUploadCtx(ctx context.Context, job model.UploadJob) {
@@ -239,27 +361,157 @@ func (m *Manager) processUploadCtx(ctx context.Context, job model.UploadJob) {
 		return
 	}
 
+	m.metrics.BatchEvents.Observe(float64(len(job.Events)))
+
+	dst := m.destination(job.Tenant)
+
+	valid, invalid := m.schema.Split(job.Events)
+	raw, bots := m.routeBots(valid)
+	if len(raw) > 0 {
+		m.uploadBatch(ctx, dst, dst.raw, raw)
+	}
+	if len(bots) > 0 {
+		atomic.AddInt64(&m.metrics.UABotEventsRoutedTotal, int64(len(bots)))
+		m.uploadBatch(ctx, dst, dst.bot, bots)
+	}
+	if len(invalid) > 0 {
+		atomic.AddInt64(&m.metrics.SchemaEventsInvalidStoredTotal, int64(len(invalid)))
+		m.uploadBatch(ctx, dst, dst.invalid, invalid)
+	}
+
+	// 이벤트 객체 재사용 가능하도록 Pool 반환
+	m.encoder.RecycleEvents(job.Events)
+}
+
+// destination 은 배치의 저장 위치(버킷, prefix)이다.
+type destination struct {
+	tenant                 string // S3_KEY_TEMPLATE 의 {tenant}. single-tenant 이면 빈 값
+	bucket                 string // 빈 값이면 RAW_BUCKET
+	raw, bot, invalid, dlq string
+}
+
+// dlqMeta 는 이 위치의 prefix / key 로 업로드하지 못한 배치를 로컬 DLQ 에 저장할 때의 메타이다.
+func (d destination) dlqMeta(numEvents int, prefix, key string) dlqMeta {
+	return dlqMeta{
+		NumEvents: int64(numEvents),
+		S3Key:     key,
+		Tenant:    d.tenant,
+		Bucket:    d.bucket,
+		Prefix:    prefix,
+		DLQPrefix: d.dlq,
+	}
+}
+
+// destination 은 tenant 의 저장 위치를 반환한다.
+//
+//   - single-tenant("")    : RAW_BUCKET 의 RAW_PREFIX / BOT_PREFIX / INVALID_PREFIX / DLQ_PREFIX
+//   - tenant               : tenant 버킷의 tenant prefix, 나머지 prefix 는 뒤에 /<tenant> 를 붙인다
+//   - 테이블에 없는 tenant : WAL replay 중 TENANTS_FILE 에서 빠진 경우. RAW_BUCKET 의 RAW_PREFIX/<tenant>
+func (m *Manager) destination(tenantID string) destination {
+	if tenantID == "" {
+		return destination{
+			raw:     m.cfg.RawPrefix,
+			bot:     m.cfg.BotPrefix,
+			invalid: m.cfg.InvalidPrefix,
+			dlq:     m.cfg.DLQPrefix,
+		}
+	}
+
+	d := destination{
+		tenant:  tenantID,
+		raw:     path.Join(m.cfg.RawPrefix, tenantID),
+		bot:     path.Join(m.cfg.BotPrefix, tenantID),
+		invalid: path.Join(m.cfg.InvalidPrefix, tenantID),
+		dlq:     path.Join(m.cfg.DLQPrefix, tenantID),
+	}
+	if t, ok := m.cfg.Tenants.Get(tenantID); ok {
+		d.bucket, d.raw = t.Bucket, t.Prefix
+	} else {
+		log.Warn().
+			Str("tenant", tenantID).
+			Msg("tenant not in TENANTS_FILE, storing under RAW_PREFIX")
+	}
+	return d
+}
+
+// routeBots 는 UA_BOT_POLICY=route 일 때 봇 이벤트를 분리한다. (순서 유지)
+// 그 외 정책이거나 봇이 없으면 events 를 그대로 raw 로 반환한다 (추가 할당 없음).
+func (m *Manager) routeBots(events []*model.Event) (raw, bots []*model.Event) {
+	if m.cfg.UABotPolicy != "route" {
+		return events, nil
+	}
+	for i, ev := range events {
+		if !ev.IsBot {
+			continue
+		}
+		// 첫 번째 봇을 만난 시점에만 분리용 slice 를 만든다.
+		raw = append(make([]*model.Event, 0, len(events)), events[:i]...)
+		for _, ev := range events[i:] {
+			if ev.IsBot {
+				bots = append(bots, ev)
+			} else {
+				raw = append(raw, ev)
+			}
+		}
+		return raw, bots
+	}
+	return events, nil
+}
+
+// uploadBatch 는 events 를 dst 버킷의 prefix 아래 하나의 객체로 저장한다.
+//  1. JSONL + gzip 인코딩 (Zero-Copy)
+//  2. Sink 업로드 (실패 시 로컬 DLQ 저장)
+//  3. 저장(또는 포기)된 이벤트의 WAL Ack
+//
+// 이벤트 객체의 Pool 반환은 caller(processUploadCtx)가 한다.
+func (m *Manager) uploadBatch(ctx context.Context, dst destination, prefix string, events []*model.Event) {
+
+	// --- 0) 큰 배치는 메모리에 인코딩하지 않고 multipart streaming 으로 보낸다 ---
+	if m.cfg.S3MultipartThreshold > 0 {
+		var size int64
+		for _, ev := range events {
+			size += eventSize(ev)
+		}
+		if size >= m.cfg.S3MultipartThreshold {
+			m.uploadStream(ctx, dst, prefix, events)
+			return
+		}
+	}
+
 	// --- 1) JSONL + gzip 인코딩 (Zero-Copy) ---
 	// 메모리 할당을 최소화하기 위해 복사본이 아닌 원본 버퍼(*bytes.Buffer)를 받아온다.
-	buf, err := m.encoder.EncodeBatchJSONLGZ(job.Events)
+	encStart := time.Now()
+	buf, err := m.encoder.EncodeBatchJSONLGZ(events)
+	m.metrics.EncodeDurationSeconds.Observe(time.Since(encStart).Seconds())
 	if err != nil {
 		// 인코딩 실패는 매우 드문 경우 (데이터 깨짐 등)
 		atomic.AddInt64(&m.metrics.S3PutErrorsTotal, 1)
 
 		// 인코딩 실패 시 원본 텍스트로라도 저장 시도 (Fallback)
 		var txtBuf bytes.Buffer
-		for _, ev := range job.Events {
+		for _, ev := range events {
 			txtBuf.WriteString(ev.Body)
 			txtBuf.WriteByte('\n')
 		}
 
 		name := NewFilename(m.cfg.InstanceID)
-		key := BuildS3Key(m.cfg.DLQPrefix, name)
-
-		_ = m.s3.UploadBytesWithRetryCtx(ctx, key, txtBuf.Bytes())
-		atomic.AddInt64(&m.metrics.DLQEventsEnqueuedTotal, int64(len(job.Events)))
-
-		m.encoder.RecycleEvents(job.Events)
+		key := BuildS3Key(dst.dlq, dst.tenant, name)
+
+		// S3 DLQ prefix 에 저장하고, 실패하면 로컬 DLQ 에 저장한다.
+		// 인코딩 자체가 불가능한 데이터는 replay 해도 결과가 같으므로 저장되면 WAL 에서 해제한다.
+		// 둘 다 실패하면 WAL 에 남겨두어 데이터를 잃지 않는다.
+		if err := m.sink.PutBytes(ctx, dst.bucket, key, txtBuf.Bytes()); err == nil {
+			atomic.AddInt64(&m.metrics.S3EventsStoredTotal, int64(len(events)))
+			m.WAL.Ack(events...)
+			return
+		}
+		if err2 := m.dlq.Save(txtBuf.Bytes(), dst.dlqMeta(len(events), dst.dlq, key)); err2 != nil {
+			if !errors.Is(err2, errDLQFull) {
+				log.Error().Err(err2).Msg("local DLQ save failed")
+			}
+			return
+		}
+		m.WAL.Ack(events...)
 		return
 	}
 
@@ -267,22 +519,78 @@ func (m *Manager) processUploadCtx(ctx context.Context, job model.UploadJob) {
 	// 1MB 이상인 경우 Pool 내부 정책에 따라 버려지므로 안전하다.
 	defer pool.PutBuffer(buf)
 
-	// --- 2) 정상 인코딩 → S3 RAW 업로드 ---
+	m.metrics.BatchEncodedBytes.Observe(float64(buf.Len()))
+
+	// --- 2) 정상 인코딩 → S3 업로드 (RAW / BOT / INVALID prefix) ---
 	name := NewFilename(m.cfg.InstanceID)
-	key := BuildS3Key(m.cfg.RawPrefix, name)
+	key := BuildS3Key(prefix, dst.tenant, name)
 
 	// buf.Bytes()는 슬라이스 헤더만 참조하므로 메모리 복사가 없다.
-	if err := m.s3.UploadBytesWithRetryCtx(ctx, key, buf.Bytes()); err != nil {
+	if err := m.sink.PutBytes(ctx, dst.bucket, key, buf.Bytes()); err != nil {
 		// 업로드 실패 → 로컬 DLQ 로 저장
 		// 여기서도 buf.Bytes()를 그대로 사용하므로 추가 할당 없음
-		if err2 := m.dlq.Save(buf.Bytes(), len(job.Events)); err2 != nil {
-			log.Error().Err(err2).Msg("local DLQ save failed")
+		// 원래 key 를 함께 기록하여, 재업로드 시에도 같은 파티션에 저장되도록 한다.
+		if err2 := m.dlq.Save(buf.Bytes(), dst.dlqMeta(len(events), prefix, key)); err2 != nil {
+			// DLQ 저장까지 실패한(용량 부족 drop 포함) 배치는 WAL 에 남겨두어 다음 기동 시 replay 되도록 한다.
+			// 용량 부족은 Save 가 샘플링 로그를 남긴다.
+			if !errors.Is(err2, errDLQFull) {
+				log.Error().Err(err2).Msg("local DLQ save failed")
+			}
+		} else {
+			m.WAL.Ack(events...)
 		}
 	} else {
 		// 업로드 성공
-		atomic.AddInt64(&m.metrics.S3EventsStoredTotal, int64(len(job.Events)))
+		atomic.AddInt64(&m.metrics.S3EventsStoredTotal, int64(len(events)))
+		m.WAL.Ack(events...)
 	}
+}
 
-	// --- 3) 이벤트 객체 재사용 가능하도록 Pool 반환 ---
-	m.encoder.RecycleEvents(job.Events)
+// uploadStream 은 uploadBatch 의 streaming 버전이다 (압축 전 크기 ≥ S3_MULTIPART_THRESHOLD).
+//
+// 인코더가 Sink.PutStream 의 writer(S3: part 버퍼)에 직접 쓰므로 gzip 결과 전체가 메모리에 올라가지 않는다.
+// 업로드가 실패하면 같은 배치를 DLQ 파일로 다시 인코딩하여 저장한다 (DLQManager.SaveStream).
+func (m *Manager) uploadStream(ctx context.Context, dst destination, prefix string, events []*model.Event) {
+	name := NewFilename(m.cfg.InstanceID)
+	key := BuildS3Key(prefix, dst.tenant, name)
+
+	var encoded int64
+	write := func(w io.Writer) error {
+		cw := &countingWriter{w: w}
+		err := m.encoder.EncodeBatchJSONLGZTo(cw, events)
+		encoded = cw.n
+		return err
+	}
+
+	encStart := time.Now()
+	err := m.sink.PutStream(ctx, dst.bucket, key, write)
+	m.metrics.EncodeDurationSeconds.Observe(time.Since(encStart).Seconds())
+
+	if err == nil {
+		m.metrics.BatchEncodedBytes.Observe(float64(encoded))
+		atomic.AddInt64(&m.metrics.S3EventsStoredTotal, int64(len(events)))
+		m.WAL.Ack(events...)
+		return
+	}
+
+	// 업로드 실패 → 로컬 DLQ 로 저장 (원래 key 를 함께 기록). 실패 시 WAL 에 남긴다.
+	if err2 := m.dlq.SaveStream(dst.dlqMeta(len(events), prefix, key), write); err2 != nil {
+		if !errors.Is(err2, errDLQFull) {
+			log.Error().Err(err2).Msg("local DLQ save failed")
+		}
+		return
+	}
+	m.WAL.Ack(events...)
+}
+
+// countingWriter 는 w 에 쓰인 바이트 수를 센다.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+)
+
+func TestRouteBots(t *testing.T) {
+	human1, bot1, human2, bot2 := &model.Event{Body: "h1"}, &model.Event{Body: "b1", IsBot: true}, &model.Event{Body: "h2"}, &model.Event{Body: "b2", IsBot: true}
+	events := []*model.Event{human1, bot1, human2, bot2}
+
+	m := &Manager{cfg: config.Config{UABotPolicy: "tag"}}
+	raw, bots := m.routeBots(events)
+	if len(raw) != 4 || bots != nil {
+		t.Fatalf("tag policy split events: raw=%d bots=%d", len(raw), len(bots))
+	}
+
+	m.cfg.UABotPolicy = "route"
+	raw, bots = m.routeBots(events)
+	if len(raw) != 2 || raw[0] != human1 || raw[1] != human2 {
+		t.Fatalf("raw = %v", raw)
+	}
+	if len(bots) != 2 || bots[0] != bot1 || bots[1] != bot2 {
+		t.Fatalf("bots = %v", bots)
+	}
+	if events[1] != bot1 {
+		t.Fatal("routeBots modified the input slice")
+	}
+}
+
+// 배치는 count / bytes / age 중 먼저 도달한 조건으로 flush 되고, 원인별로 센다.
+func TestCollectLoopFlushTriggers(t *testing.T) {
+	m := &Manager{
+		cfg:      config.Config{BatchSize: 3, BatchMaxBytes: 100, FlushInterval: 50 * time.Millisecond},
+		metrics:  metrics.New(),
+		EventCh:  make(chan *model.Event, 10),
+		uploadCh: make(chan model.UploadJob, 10),
+	}
+	m.wg.Add(1)
+	go m.collectLoop()
+
+	recv := func() model.UploadJob {
+		t.Helper()
+		select {
+		case job := <-m.uploadCh:
+			return job
+		case <-time.After(time.Second):
+			t.Fatal("no flush")
+			return model.UploadJob{}
+		}
+	}
+
+	// count: 작은 이벤트 3개
+	for i := 0; i < 3; i++ {
+		m.EventCh <- &model.Event{Body: "a=1"}
+	}
+	if job := recv(); len(job.Events) != 3 {
+		t.Fatalf("count flush: %d events", len(job.Events))
+	}
+
+	// bytes: 60 + 60 >= 100
+	m.EventCh <- &model.Event{Body: strings.Repeat("x", 60)}
+	m.EventCh <- &model.Event{Body: strings.Repeat("x", 60)}
+	if job := recv(); len(job.Events) != 2 {
+		t.Fatalf("bytes flush: %d events", len(job.Events))
+	}
+
+	// age: FlushInterval 경과
+	m.EventCh <- &model.Event{Body: "a=1"}
+	if job := recv(); len(job.Events) != 1 {
+		t.Fatalf("age flush: %d events", len(job.Events))
+	}
+
+	// shutdown: 남은 배치
+	m.EventCh <- &model.Event{Body: "a=1"}
+	close(m.EventCh)
+	if job := recv(); len(job.Events) != 1 {
+		t.Fatalf("shutdown flush: %d events", len(job.Events))
+	}
+
+	mt := m.metrics
+	if mt.BatchFlushCountTotal != 1 || mt.BatchFlushBytesTotal != 1 || mt.BatchFlushAgeTotal != 1 || mt.BatchFlushShutdownTotal != 1 {
+		t.Fatalf("count=%d bytes=%d age=%d shutdown=%d",
+			mt.BatchFlushCountTotal, mt.BatchFlushBytesTotal, mt.BatchFlushAgeTotal, mt.BatchFlushShutdownTotal)
+	}
+}
+
+// 인코딩할 수 없는 배치는 원본 텍스트를 S3 DLQ prefix → 로컬 DLQ 순으로 저장하고,
+// 저장된 경우에만 WAL Ack 한다.
+func TestUploadBatchEncodeFailureFallback(t *testing.T) {
+	f := newFakeS3(t)
+	u, m := newTestMultipartUploader(f, 1024)
+	u.cfg.InstanceID = "test"
+	u.cfg.DLQDir = t.TempDir()
+	u.cfg.DLQMaxSizeBytes = 1 << 20
+
+	walCfg := testWALConfig(t)
+	wal, _, err := OpenWAL(walCfg, m)
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	mgr := &Manager{
+		cfg:     u.cfg,
+		metrics: m,
+		sink:    u,
+		dlq:     NewDLQManager(u.cfg, m, nil),
+		encoder: NewEncoder(u.cfg, m),
+		WAL:     wal,
+	}
+	dst := destination{bucket: "raw", dlq: "dlq"}
+	broken := func() []*model.Event {
+		evs := []*model.Event{{Body: "a=1"}, {Body: "b=2"}}
+		if err := wal.Append(evs...); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		evs[1].BodyJSON = []byte("{broken") // WAL 기록 후 깨뜨린다
+		return evs
+	}
+
+	// 1) S3 저장 성공 → DLQ 로 세지 않는다
+	mgr.uploadBatch(context.Background(), dst, "raw", broken())
+	if m.S3EventsStoredTotal != 2 || m.DLQEventsEnqueuedTotal != 0 {
+		t.Fatalf("stored=%d dlq=%d, want 2 / 0", m.S3EventsStoredTotal, m.DLQEventsEnqueuedTotal)
+	}
+	if objs := f.snapshot(); len(objs) != 1 {
+		t.Fatalf("objects = %d, want 1", len(objs))
+	}
+
+	// 2) S3 실패 → 로컬 DLQ
+	f.setFail(func(*http.Request) int { return http.StatusForbidden })
+	mgr.uploadBatch(context.Background(), dst, "raw", broken())
+	if m.DLQEventsEnqueuedTotal != 2 || m.DLQFilesCurrent != 1 {
+		t.Fatalf("dlq events=%d files=%d, want 2 / 1", m.DLQEventsEnqueuedTotal, m.DLQFilesCurrent)
+	}
+
+	// 3) S3 와 로컬 DLQ 모두 실패 → Ack 하지 않는다 (재시작 시 replay)
+	mgr.dlq = NewDLQManager(config.Config{InstanceID: "test", DLQDir: t.TempDir(), DLQMaxSizeBytes: 1}, m, nil)
+	mgr.uploadBatch(context.Background(), dst, "raw", broken())
+
+	_, recovered, err := OpenWAL(walCfg, metrics.New())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if len(recovered) != 2 {
+		t.Fatalf("recovered %d events, want 2 (only the unsaved batch)", len(recovered))
+	}
+}
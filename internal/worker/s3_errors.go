@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"estat-ingest/internal/metrics"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// ------------------------------------------------------------
+// S3 오류 분류
+//
+// 모든 오류를 같은 방식으로 재시도하면 AccessDenied / NoSuchBucket 처럼 절대 성공하지 않는 오류에도
+// 배치마다 S3AppRetries × S3Timeout 을 소모한다. 오류를 다음과 같이 나눈다.
+//
+//   - retryable     : throttling(SlowDown 등), 5xx, timeout, connection reset → 재시도
+//   - non-retryable : 권한 / credential, 버킷 없음, 잘못된 key·요청 → 즉시 실패 (caller 가 DLQ 로 보냄)
+//   - unknown       : 위 어디에도 해당하지 않음 → 데이터 보호를 위해 retryable 처럼 재시도
+//
+// 판단 순서: SDK(smithy) 오류 코드 → HTTP status → 네트워크 오류.
+// ------------------------------------------------------------
+
+type s3ErrClass int
+
+const (
+	s3ErrUnknown s3ErrClass = iota
+	s3ErrRetryable
+	s3ErrThrottled // retryable 의 일종. backoff 에 jitter 를 준다.
+	s3ErrNonRetryable
+)
+
+func (c s3ErrClass) String() string {
+	switch c {
+	case s3ErrRetryable:
+		return "retryable"
+	case s3ErrThrottled:
+		return "throttled"
+	case s3ErrNonRetryable:
+		return "non_retryable"
+	default:
+		return "unknown"
+	}
+}
+
+// retryable 은 재시도할 오류인지 반환한다 (unknown 포함).
+func (c s3ErrClass) retryable() bool {
+	return c != s3ErrNonRetryable
+}
+
+// s3ErrorCodes 는 S3 오류 코드(smithy.APIError.ErrorCode)별 분류이다.
+var s3ErrorCodes = map[string]s3ErrClass{
+	// throttling
+	"SlowDown":                 s3ErrThrottled,
+	"Throttling":               s3ErrThrottled,
+	"ThrottlingException":      s3ErrThrottled,
+	"RequestThrottled":         s3ErrThrottled,
+	"RequestLimitExceeded":     s3ErrThrottled,
+	"TooManyRequestsException": s3ErrThrottled,
+
+	// 일시 장애
+	"InternalError":        s3ErrRetryable,
+	"ServiceUnavailable":   s3ErrRetryable,
+	"RequestTimeout":       s3ErrRetryable,
+	"RequestTimeTooSkewed": s3ErrRetryable, // SDK 가 clock skew 를 보정한 뒤 다음 시도는 성공한다
+	"ExpiredToken":         s3ErrRetryable, // IRSA / IMDS credential 은 다음 시도 전에 갱신된다
+	"OperationAborted":     s3ErrRetryable,
+
+	// 권한 / credential
+	"AccessDenied":                 s3ErrNonRetryable,
+	"AllAccessDisabled":            s3ErrNonRetryable,
+	"AccountProblem":               s3ErrNonRetryable,
+	"InvalidAccessKeyId":           s3ErrNonRetryable,
+	"SignatureDoesNotMatch":        s3ErrNonRetryable,
+	"AuthorizationHeaderMalformed": s3ErrNonRetryable,
+	"InvalidToken":                 s3ErrNonRetryable,
+
+	// 버킷 / key / 요청 자체의 문제
+	"NoSuchBucket":       s3ErrNonRetryable,
+	"InvalidBucketName":  s3ErrNonRetryable,
+	"PermanentRedirect":  s3ErrNonRetryable,
+	"KeyTooLongError":    s3ErrNonRetryable,
+	"InvalidArgument":    s3ErrNonRetryable,
+	"InvalidRequest":     s3ErrNonRetryable,
+	"InvalidObjectState": s3ErrNonRetryable,
+	"MalformedXML":       s3ErrNonRetryable,
+	"MethodNotAllowed":   s3ErrNonRetryable,
+	"EntityTooLarge":     s3ErrNonRetryable,
+	"EntityTooSmall":     s3ErrNonRetryable,
+	"NoSuchUpload":       s3ErrNonRetryable,
+	"InvalidPart":        s3ErrNonRetryable,
+	"InvalidPartOrder":   s3ErrNonRetryable,
+}
+
+// classifyS3Error 는 S3 호출 오류를 분류한다. err 는 nil 이 아니어야 한다.
+func classifyS3Error(err error) s3ErrClass {
+	// 시도당 timeout(S3Timeout) 초과
+	if errors.Is(err, context.DeadlineExceeded) {
+		return s3ErrRetryable
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if c, ok := s3ErrorCodes[apiErr.ErrorCode()]; ok {
+			return c
+		}
+	}
+
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) {
+		switch code := respErr.HTTPStatusCode(); {
+		case code == http.StatusTooManyRequests:
+			return s3ErrThrottled
+		case code == http.StatusRequestTimeout, code >= 500 && code != http.StatusNotImplemented:
+			return s3ErrRetryable
+		case code >= 400:
+			return s3ErrNonRetryable
+		}
+	}
+
+	// 응답을 받지 못한 경우 (연결 실패 / reset / 끊긴 응답)
+	var sendErr *smithyhttp.RequestSendError
+	var netErr net.Error
+	if errors.As(err, &sendErr) || errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return s3ErrRetryable
+	}
+
+	return s3ErrUnknown
+}
+
+// countS3Error 는 실패한 시도 1회를 분류별 카운터에 반영한다.
+func countS3Error(m *metrics.Metrics, c s3ErrClass) {
+	atomic.AddInt64(&m.S3PutErrorsTotal, 1)
+	switch c {
+	case s3ErrThrottled:
+		atomic.AddInt64(&m.S3ErrorsThrottledTotal, 1)
+		atomic.AddInt64(&m.S3ErrorsRetryableTotal, 1)
+	case s3ErrRetryable:
+		atomic.AddInt64(&m.S3ErrorsRetryableTotal, 1)
+	case s3ErrNonRetryable:
+		atomic.AddInt64(&m.S3ErrorsNonRetryableTotal, 1)
+	default:
+		atomic.AddInt64(&m.S3ErrorsUnknownTotal, 1)
+	}
+}
+
+const (
+	s3BackoffBase = 200 * time.Millisecond
+	s3BackoffMax  = 2 * time.Second
+)
+
+// s3Backoff 는 재시도 간격이다 (200ms → 최대 2s, 2배씩).
+// throttling 이면 [d, 2d) 범위의 jitter 를 준다. 여러 worker / 인스턴스가 같은 순간에
+// 다시 몰려 SlowDown 이 반복되는 것을 막는다.
+type s3Backoff struct {
+	next time.Duration
+}
+
+func (b *s3Backoff) wait(c s3ErrClass) time.Duration {
+	if b.next == 0 {
+		b.next = s3BackoffBase
+	}
+	d := b.next
+	b.next = min(b.next*2, s3BackoffMax)
+
+	if c == s3ErrThrottled {
+		d += rand.N(d)
+	}
+	return d
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func statusError(code int) error {
+	return &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: code}},
+		Err:      errors.New("response error"),
+	}
+}
+
+func TestClassifyS3Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want s3ErrClass
+	}{
+		{"slow down", &smithy.GenericAPIError{Code: "SlowDown"}, s3ErrThrottled},
+		{"internal", &smithy.GenericAPIError{Code: "InternalError"}, s3ErrRetryable},
+		{"access denied", &smithy.GenericAPIError{Code: "AccessDenied"}, s3ErrNonRetryable},
+		{"no such bucket (wrapped)", fmt.Errorf("put: %w", &smithy.GenericAPIError{Code: "NoSuchBucket"}), s3ErrNonRetryable},
+		{"attempt timeout", fmt.Errorf("put: %w", context.DeadlineExceeded), s3ErrRetryable},
+		{"429", statusError(http.StatusTooManyRequests), s3ErrThrottled},
+		{"502", statusError(http.StatusBadGateway), s3ErrRetryable},
+		{"501", statusError(http.StatusNotImplemented), s3ErrNonRetryable},
+		{"400", statusError(http.StatusBadRequest), s3ErrNonRetryable},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, s3ErrRetryable},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), s3ErrRetryable},
+		{"send failed", &smithyhttp.RequestSendError{Err: errors.New("EOF")}, s3ErrRetryable},
+		{"unknown code", &smithy.GenericAPIError{Code: "Weird"}, s3ErrUnknown},
+		{"plain", errors.New("boom"), s3ErrUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := classifyS3Error(tt.err); got != tt.want {
+			t.Errorf("%s: class = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// throttling 이 아니면 200ms 부터 2배씩(최대 2s), throttling 이면 [d, 2d) 범위로 jitter 한다.
+func TestS3BackoffJitter(t *testing.T) {
+	var b s3Backoff
+	for _, want := range []time.Duration{200, 400, 800, 1600, 2000, 2000} {
+		if got := b.wait(s3ErrRetryable); got != want*time.Millisecond {
+			t.Fatalf("wait = %v, want %v", got, want*time.Millisecond)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		b := s3Backoff{next: time.Second}
+		if got := b.wait(s3ErrThrottled); got < time.Second || got >= 2*time.Second {
+			t.Fatalf("throttled wait = %v, want [1s, 2s)", got)
+		}
+	}
+}
+
+// non-retryable 오류(AccessDenied)는 재시도하지 않는다.
+func TestS3UploaderNonRetryableFailsFast(t *testing.T) {
+	f := newFakeS3(t)
+	f.setFail(func(*http.Request) int { return http.StatusForbidden })
+	u, m := newTestMultipartUploader(f, 1024)
+	u.cfg.S3AppRetries = 5
+
+	start := time.Now()
+	if err := u.PutBytes(context.Background(), "raw", "x.jsonl.gz", []byte("x")); err == nil {
+		t.Fatal("PutBytes succeeded, want error")
+	}
+	if m.S3PutErrorsTotal != 1 || m.S3ErrorsNonRetryableTotal != 1 {
+		t.Fatalf("attempts=%d non_retryable=%d, want 1 / 1", m.S3PutErrorsTotal, m.S3ErrorsNonRetryableTotal)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("non-retryable failure took %v", d)
+	}
+}
+
+// throttling(SlowDown) 은 재시도하여 성공하고, 분류별로 센다.
+func TestS3UploaderRetriesThrottled(t *testing.T) {
+	f := newFakeS3(t)
+	var calls atomic.Int32
+	f.setFail(func(*http.Request) int {
+		if calls.Add(1) <= 2 {
+			return http.StatusServiceUnavailable // SlowDown
+		}
+		return 0
+	})
+	u, m := newTestMultipartUploader(f, 1024)
+	u.cfg.S3AppRetries = 3
+
+	if err := u.PutBytes(context.Background(), "raw", "x.jsonl.gz", []byte("x")); err != nil {
+		t.Fatalf("PutBytes: %v", err)
+	}
+	if _, ok := f.object("raw/x.jsonl.gz"); !ok {
+		t.Fatal("object not stored")
+	}
+	if m.S3ErrorsThrottledTotal != 2 || m.S3ErrorsRetryableTotal != 2 || m.S3ErrorsUnknownTotal != 0 {
+		t.Fatalf("throttled=%d retryable=%d unknown=%d", m.S3ErrorsThrottledTotal, m.S3ErrorsRetryableTotal, m.S3ErrorsUnknownTotal)
+	}
+}
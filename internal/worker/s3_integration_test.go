@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+	"estat-ingest/internal/partition"
+)
+
+// newFakeS3Config 는 fake S3 를 S3 호환 endpoint 로 사용하는 Manager 설정이다.
+// (S3_ENDPOINT + S3_FORCE_PATH_STYLE + 정적 credential, secret 은 파일로 준다)
+func newFakeS3Config(t *testing.T, f *fakeS3) config.Config {
+	t.Helper()
+	secret := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secret, []byte("test-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return config.Config{
+		SinkType:              "s3",
+		AWSRegion:             "us-east-1",
+		RawBucket:             "raw-bucket",
+		RawPrefix:             "raw",
+		DLQPrefix:             "dlq",
+		PartitionTimezone:     "UTC",
+		S3KeyTemplate:         partition.DefaultTemplate,
+		S3Endpoint:            f.srv.URL,
+		S3ForcePathStyle:      true,
+		S3AccessKeyID:         "AKIDFAKE",
+		S3SecretAccessKeyFile: secret,
+		S3Timeout:             5 * time.Second,
+		S3AppRetries:          1,
+		InstanceID:            "test",
+		ChannelSize:           10,
+		UploadQueue:           2,
+		UploadWorkers:         1,
+		BatchSize:             3,
+		FlushInterval:         time.Hour,
+		DLQDir:                t.TempDir(),
+		DLQMaxSizeBytes:       1 << 20,
+		DLQRescanInterval:     time.Hour,
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 전체 Manager 를 fake S3 에 붙여 정상 업로드 → 장애 시 DLQ → 복구 후 DLQ 재업로드 → 종료 flush 를 확인한다.
+func TestManagerAgainstFakeS3(t *testing.T) {
+	f := newFakeS3(t)
+	var down atomic.Bool
+	f.setFail(func(*http.Request) int {
+		if down.Load() {
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	})
+
+	m := metrics.New()
+	mgr := NewManager(newFakeS3Config(t, f), m)
+	mgr.Start()
+
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			mgr.EventCh <- &model.Event{Body: "a=1"}
+		}
+	}
+
+	// 1) 정상: BatchSize(3) 도달 → 업로드
+	send(3)
+	waitFor(t, "first upload", func() bool { return atomic.LoadInt64(&m.S3EventsStoredTotal) == 3 })
+
+	// 2) 장애: 업로드 실패 → DLQ
+	down.Store(true)
+	send(3)
+	waitFor(t, "DLQ save", func() bool { return atomic.LoadInt64(&m.DLQEventsEnqueuedTotal) == 3 })
+
+	// 3) 복구: worker 0 의 ProcessOneCtx 가 DLQ 파일을 재업로드
+	down.Store(false)
+	waitFor(t, "DLQ replay", func() bool { return atomic.LoadInt64(&m.DLQFilesCurrent) == 0 })
+
+	// 4) 종료: 남은 배치 flush
+	send(1)
+	mgr.Shutdown()
+
+	objects := f.snapshot()
+	if len(objects) != 3 {
+		t.Fatalf("objects = %d, want 3", len(objects))
+	}
+	lines := 0
+	dir := t.TempDir()
+	for path, body := range objects {
+		if !strings.HasPrefix(path, "raw-bucket/raw/") {
+			t.Fatalf("object %q not under raw-bucket/raw/ (path-style)", path)
+		}
+		tmp := filepath.Join(dir, filepath.Base(path))
+		if err := os.WriteFile(tmp, body, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		lines += countJSONLGZ(t, tmp)
+	}
+	if lines != 7 {
+		t.Fatalf("stored events = %d, want 7", lines)
+	}
+
+	f.mu.Lock()
+	auth := f.auth
+	f.mu.Unlock()
+	if !strings.Contains(auth, "Credential=AKIDFAKE/") {
+		t.Fatalf("Authorization = %q, want static credential", auth)
+	}
+}
@@ -0,0 +1,180 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rs/zerolog/log"
+)
+
+// ------------------------------------------------------------
+// S3 multipart streaming 업로드 (S3_MULTIPART_THRESHOLD 이상 배치)
+//
+// EncodeBatchJSONLGZ 는 gzip 결과 전체를 bytes.Buffer 에 만들기 때문에
+// 큰 배치는 그만큼의 메모리를 잡는다. 큰 배치는 인코더가 multipartWriter 에 직접 쓰고,
+// S3_PART_SIZE 가 찰 때마다 UploadPart 로 내보낸다. (in-flight 배치당 메모리 ≈ part 1개)
+//
+//   - 재시도는 호출(Create / UploadPart / Complete) 단위로 S3_APP_RETRIES 회이다 (S3Uploader.retry).
+//   - 어느 단계든 최종 실패하면 AbortMultipartUpload 로 미완성 part 를 정리하고 오류를 반환한다.
+//     (caller 는 같은 배치를 DLQ 파일로 다시 인코딩한다. DLQManager.SaveStream)
+//   - abort 자체가 실패해도 버킷 lifecycle(AbortIncompleteMultipartUpload)이 정리하도록 설정해 둔다.
+// ------------------------------------------------------------
+
+// PutStream 은 Sink 인터페이스 구현이며, write 가 쓰는 내용을 multipart upload 로 저장한다.
+func (u *S3Uploader) PutStream(ctx context.Context, bucket, key string, write func(io.Writer) error) error {
+	if !u.breaker.allow() {
+		return errS3BreakerOpen
+	}
+	err := u.uploadMultipart(ctx, bucket, key, write)
+	u.recordResult(ctx, err)
+	return err
+}
+
+func (u *S3Uploader) uploadMultipart(ctx context.Context, bucket, key string, write func(io.Writer) error) error {
+	if bucket == "" {
+		bucket = u.cfg.RawBucket
+	}
+
+	var uploadID *string
+	err := u.retry(ctx, key, "S3 create multipart upload", func(ctx context.Context) error {
+		out, err := u.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err == nil {
+			uploadID = out.UploadId
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	w := &multipartWriter{
+		u:        u,
+		ctx:      ctx,
+		bucket:   bucket,
+		key:      key,
+		uploadID: uploadID,
+		buf:      make([]byte, 0, u.cfg.S3PartSize),
+	}
+
+	err = write(w)
+	if err == nil {
+		err = w.flushPart() // 마지막 part (5MB 미만 허용)
+	}
+	if err == nil {
+		err = u.retry(ctx, key, "S3 complete multipart upload", func(ctx context.Context) error {
+			_, err := u.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+				Bucket:          aws.String(bucket),
+				Key:             aws.String(key),
+				UploadId:        uploadID,
+				MultipartUpload: &types.CompletedMultipartUpload{Parts: w.parts},
+			})
+			return err
+		})
+	}
+	if err != nil {
+		u.abortMultipart(ctx, bucket, key, uploadID)
+		return err
+	}
+
+	atomic.AddInt64(&u.metrics.S3MultipartUploadsTotal, 1)
+	return nil
+}
+
+// abortMultipart 는 미완성 multipart upload 를 정리한다.
+// shutdown 으로 ctx 가 취소된 경우에도 abort 는 보내야 하므로 취소를 끊고 S3Timeout 만 적용한다.
+func (u *S3Uploader) abortMultipart(ctx context.Context, bucket, key string, uploadID *string) {
+	atomic.AddInt64(&u.metrics.S3MultipartAbortsTotal, 1)
+
+	ctx2, cancel := context.WithTimeout(context.WithoutCancel(ctx), u.cfg.S3Timeout)
+	defer cancel()
+
+	_, err := u.client.AbortMultipartUpload(ctx2, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	})
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("key", key).
+			Msg("S3 abort multipart upload failed (left to bucket lifecycle)")
+	}
+}
+
+// multipartWriter 는 쓰인 내용을 part 크기만큼 모아 UploadPart 로 보내는 io.Writer 이다.
+type multipartWriter struct {
+	u        *S3Uploader
+	ctx      context.Context
+	bucket   string
+	key      string
+	uploadID *string
+
+	buf   []byte // cap == S3PartSize
+	parts []types.CompletedPart
+	err   error // 첫 part 실패 이후의 Write 는 모두 이 오류를 반환한다
+}
+
+func (w *multipartWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		k := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flushPart(); err != nil {
+				w.err = err
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flushPart 는 모인 내용을 다음 번호의 part 로 업로드한다. 비어 있으면 아무것도 하지 않는다.
+func (w *multipartWriter) flushPart() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) == 0 && len(w.parts) > 0 {
+		return nil
+	}
+
+	num := aws.Int32(int32(len(w.parts) + 1))
+	var etag *string
+	err := w.u.retry(w.ctx, w.key, "S3 upload part", func(ctx context.Context) error {
+		out, err := w.u.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(w.bucket),
+			Key:           aws.String(w.key),
+			UploadId:      w.uploadID,
+			PartNumber:    num,
+			Body:          bytes.NewReader(w.buf),
+			ContentLength: aws.Int64(int64(len(w.buf))),
+		})
+		if err == nil {
+			etag = out.ETag
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	w.parts = append(w.parts, types.CompletedPart{ETag: etag, PartNumber: num})
+	w.buf = w.buf[:0]
+	atomic.AddInt64(&w.u.metrics.S3MultipartPartsTotal, 1)
+	return nil
+}
@@ -0,0 +1,238 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+
+	json "github.com/goccy/go-json"
+	"github.com/klauspost/compress/gzip"
+)
+
+func newTestMultipartUploader(f *fakeS3, partSize int64) (*S3Uploader, *metrics.Metrics) {
+	m := metrics.New()
+	cfg := config.Config{
+		RawBucket:    "raw",
+		S3Timeout:    5 * time.Second,
+		S3AppRetries: 2,
+		S3PartSize:   partSize,
+	}
+	return &S3Uploader{cfg: cfg, metrics: m, client: f.client()}, m
+}
+
+// part 크기를 넘는 내용은 여러 part 로 나뉘어 업로드되고, 완료 후 원본과 같은 객체가 된다.
+func TestS3UploaderPutStreamMultipart(t *testing.T) {
+	f := newFakeS3(t)
+	u, m := newTestMultipartUploader(f, 1024)
+
+	payload := bytes.Repeat([]byte("0123456789"), 300) // 3000 bytes → 1024 + 1024 + 952
+	err := u.PutStream(context.Background(), "", "raw/x.jsonl.gz", func(w io.Writer) error {
+		// 작은 write 여러 번으로 part 경계를 넘긴다
+		for i := 0; i < len(payload); i += 100 {
+			if _, err := w.Write(payload[i : i+100]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("PutStream: %v", err)
+	}
+
+	got, ok := f.object("raw/raw/x.jsonl.gz")
+	if !ok || !bytes.Equal(got, payload) {
+		t.Fatalf("object = %d bytes (ok=%v), want %d", len(got), ok, len(payload))
+	}
+	if m.S3MultipartPartsTotal != 3 || m.S3MultipartUploadsTotal != 1 || m.S3MultipartAbortsTotal != 0 {
+		t.Fatalf("parts=%d uploads=%d aborts=%d", m.S3MultipartPartsTotal, m.S3MultipartUploadsTotal, m.S3MultipartAbortsTotal)
+	}
+}
+
+// part 업로드가 재시도까지 실패하면 multipart upload 를 abort 하고 오류를 반환한다.
+func TestS3UploaderPutStreamAbortsOnFailure(t *testing.T) {
+	f := newFakeS3(t)
+	f.setFail(func(r *http.Request) int {
+		if r.URL.Query().Get("partNumber") == "2" {
+			return http.StatusInternalServerError
+		}
+		return 0
+	})
+	u, m := newTestMultipartUploader(f, 1024)
+
+	err := u.PutStream(context.Background(), "raw", "x.jsonl.gz", func(w io.Writer) error {
+		_, err := w.Write(make([]byte, 3000))
+		return err
+	})
+	if err == nil {
+		t.Fatal("PutStream succeeded, want error")
+	}
+
+	if _, ok := f.object("raw/x.jsonl.gz"); ok {
+		t.Fatal("object stored despite failed part")
+	}
+	if f.aborted != 1 || m.S3MultipartAbortsTotal != 1 {
+		t.Fatalf("aborted=%d S3MultipartAbortsTotal=%d, want 1", f.aborted, m.S3MultipartAbortsTotal)
+	}
+	if m.S3PutErrorsTotal != 2 || m.S3ConsecutiveFailures != 1 {
+		t.Fatalf("S3PutErrorsTotal=%d S3ConsecutiveFailures=%d", m.S3PutErrorsTotal, m.S3ConsecutiveFailures)
+	}
+}
+
+// SaveStream 은 임시 파일 없이 data + meta 를 남기고, 용량이 부족하면 errDLQFull 이다.
+func TestDLQSaveStream(t *testing.T) {
+	cfg := config.Config{DLQDir: t.TempDir(), InstanceID: "test", DLQMaxSizeBytes: 100}
+	m := metrics.New()
+	d := NewDLQManager(cfg, m, nil)
+
+	write := func(n int) func(io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := w.Write(make([]byte, n))
+			return err
+		}
+	}
+
+	if err := d.SaveStream(dlqMeta{NumEvents: 3, Bucket: "b", S3Key: "raw/x.jsonl.gz"}, write(60)); err != nil {
+		t.Fatalf("SaveStream: %v", err)
+	}
+	entries, _ := os.ReadDir(cfg.DLQDir)
+	if len(entries) != 2 {
+		t.Fatalf("DLQ dir has %d entries, want data + meta", len(entries))
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Fatalf("temp file left behind: %s", e.Name())
+		}
+		if strings.HasSuffix(e.Name(), ".meta.json") {
+			if meta := readMeta(filepath.Join(cfg.DLQDir, e.Name())); meta.NumEvents != 3 || meta.Bucket != "b" || meta.S3Key != "raw/x.jsonl.gz" {
+				t.Fatalf("meta = %+v", meta)
+			}
+		}
+	}
+	if m.DLQSizeBytes != 60 || m.DLQFilesCurrent != 1 || m.DLQEventsEnqueuedTotal != 3 {
+		t.Fatalf("size=%d files=%d enqueued=%d", m.DLQSizeBytes, m.DLQFilesCurrent, m.DLQEventsEnqueuedTotal)
+	}
+
+	err := d.SaveStream(dlqMeta{NumEvents: 2}, write(200))
+	if err != errDLQFull || m.DLQEventsDroppedTotal != 2 {
+		t.Fatalf("err=%v dropped=%d, want errDLQFull / 2", err, m.DLQEventsDroppedTotal)
+	}
+	entries, _ = os.ReadDir(cfg.DLQDir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Fatalf("temp file left behind after drop: %s", e.Name())
+		}
+	}
+}
+
+// 임계값 이상 배치는 PutStream 으로 저장되고, 업로드 실패 시 같은 내용이 DLQ 파일로 저장된다.
+func TestUploadBatchStreamsLargeBatch(t *testing.T) {
+	f := newFakeS3(t)
+	u, m := newTestMultipartUploader(f, 1024)
+	u.cfg.InstanceID = "test"
+	u.cfg.DLQDir = t.TempDir()
+	u.cfg.DLQMaxSizeBytes = 1 << 20
+	u.cfg.S3MultipartThreshold = 1000
+
+	mgr := &Manager{
+		cfg:     u.cfg,
+		metrics: m,
+		sink:    u,
+		dlq:     NewDLQManager(u.cfg, m, nil),
+		encoder: NewEncoder(u.cfg, m),
+	}
+	events := func() []*model.Event {
+		var evs []*model.Event
+		for i := 0; i < 20; i++ {
+			evs = append(evs, &model.Event{Body: strings.Repeat("x", 100)})
+		}
+		return evs
+	}
+	dst := destination{bucket: "raw"}
+
+	mgr.uploadBatch(context.Background(), dst, "raw", events())
+	if m.S3MultipartUploadsTotal != 1 || m.S3EventsStoredTotal != 20 {
+		t.Fatalf("uploads=%d stored=%d", m.S3MultipartUploadsTotal, m.S3EventsStoredTotal)
+	}
+
+	f.setFail(func(*http.Request) int { return http.StatusServiceUnavailable })
+	mgr.uploadBatch(context.Background(), dst, "raw", events())
+	if m.DLQEventsEnqueuedTotal != 20 {
+		t.Fatalf("DLQEventsEnqueuedTotal = %d, want 20", m.DLQEventsEnqueuedTotal)
+	}
+
+	entries, _ := os.ReadDir(u.cfg.DLQDir)
+	for _, e := range entries {
+		if !isDLQDataName(e.Name()) {
+			continue
+		}
+		if n := countJSONLGZ(t, filepath.Join(u.cfg.DLQDir, e.Name())); n != 20 {
+			t.Fatalf("DLQ file has %d lines, want 20", n)
+		}
+		return
+	}
+	t.Fatal("no DLQ data file")
+}
+
+// 기본 설정과 같은 모양(S3MultipartThreshold == BatchMaxBytes, BatchSize 는 충분히 큼)이면
+// collectLoop 가 크기 상한으로 flush 한 배치는 streaming 업로드로 간다.
+func TestCollectLoopBytesFlushStreams(t *testing.T) {
+	f := newFakeS3(t)
+	cfg := newFakeS3Config(t, f)
+	cfg.BatchSize = 5000
+	cfg.BatchMaxBytes = 2000
+	cfg.S3MultipartThreshold = cfg.BatchMaxBytes // config.Load 의 기본값
+	cfg.S3PartSize = 1024
+
+	m := metrics.New()
+	mgr := NewManager(cfg, m)
+	mgr.Start()
+
+	for i := 0; i < 20; i++ {
+		mgr.EventCh <- &model.Event{Body: strings.Repeat("x", 100)}
+	}
+	waitFor(t, "multipart upload", func() bool { return atomic.LoadInt64(&m.S3MultipartUploadsTotal) == 1 })
+	mgr.Shutdown()
+
+	if m.BatchFlushBytesTotal != 1 || m.S3EventsStoredTotal != 20 {
+		t.Fatalf("bytes flushes=%d stored=%d, want 1 / 20", m.BatchFlushBytesTotal, m.S3EventsStoredTotal)
+	}
+}
+
+func countJSONLGZ(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	sc := bufio.NewScanner(zr)
+	for sc.Scan() {
+		var ev model.Event
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("line %d: %v", n+1, err)
+		}
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -4,7 +4,10 @@ package worker
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"os"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -13,11 +16,12 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsCfgLib "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/rs/zerolog/log"
 )
 
-// S3Uploader는 S3 업로드 기능을 담당하는 구성 요소이다.
+// S3Uploader는 S3 업로드 기능을 담당하는 구성 요소이다 (Sink 구현체).
 // - JSONL.gz 바이트 업로드 (UploadBytesWithRetryCtx)
 // - 로컬 DLQ 파일 업로드 (UploadFileWithRetryCtx)
 // - 내부적으로 AWS SDK v2 client 사용
@@ -28,6 +32,7 @@ type S3Uploader struct {
 	cfg     config.Config
 	metrics *metrics.Metrics
 	client  *s3.Client
+	breaker *breaker // S3_BREAKER_ENABLED=false 이면 nil
 }
 
 // NewS3Uploader는 AWS SDK Config를 초기화하고 S3 client를 생성한다.
@@ -36,188 +41,246 @@ func NewS3Uploader(cfg config.Config, m *metrics.Metrics) *S3Uploader {
 		cfg:     cfg,
 		metrics: m,
 		client:  newS3Client(cfg),
+		breaker: newBreaker(cfg, m),
 	}
 }
 
 // newS3Client는 AWS 지역(region)과 Retry 설정 등 기본 옵션을 로드한다.
 // 실패 시 fatal 로그 후 즉시 종료한다 (운영 환경에서는 필수).
+//
+// S3 호환 스토리지(MinIO 등)용 설정도 여기서 적용한다:
+//   - S3_ENDPOINT / S3_FORCE_PATH_STYLE : endpoint 와 path-style 주소
+//   - S3_ACCESS_KEY_ID + secret          : AWS credential chain 대신 정적 credential
+//   - S3_CA_BUNDLE                       : 사설 CA PEM
 func newS3Client(cfg config.Config) *s3.Client {
-	awsCfg, err := awsCfgLib.LoadDefaultConfig(
-		context.TODO(),
+	opts := []func(*awsCfgLib.LoadOptions) error{
 		awsCfgLib.WithRegion(cfg.AWSRegion),
-	)
+	}
+
+	if cfg.S3AccessKeyID != "" {
+		secret := cfg.S3SecretAccessKey
+		if cfg.S3SecretAccessKeyFile != "" {
+			b, err := os.ReadFile(cfg.S3SecretAccessKeyFile)
+			if err != nil {
+				log.Fatal().Err(err).Str("path", cfg.S3SecretAccessKeyFile).Msg("failed to read S3 secret access key file")
+			}
+			secret = strings.TrimSpace(string(b))
+			if secret == "" {
+				log.Fatal().Str("path", cfg.S3SecretAccessKeyFile).Msg("S3 secret access key file is empty")
+			}
+		}
+		opts = append(opts, awsCfgLib.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.S3AccessKeyID, secret, cfg.S3SessionToken),
+		))
+	}
+
+	if cfg.S3CABundle != "" {
+		pem, err := os.ReadFile(cfg.S3CABundle)
+		if err != nil {
+			log.Fatal().Err(err).Str("path", cfg.S3CABundle).Msg("failed to read S3 CA bundle")
+		}
+		opts = append(opts, awsCfgLib.WithCustomCABundle(bytes.NewReader(pem)))
+	}
+
+	awsCfg, err := awsCfgLib.LoadDefaultConfig(context.TODO(), opts...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to load AWS config")
 	}
 
 	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
 		o.RetryMaxAttempts = 0
+		if cfg.S3Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.S3Endpoint)
+		}
+		o.UsePathStyle = cfg.S3ForcePathStyle
 	})
 
 	return client
 }
 
+// PutBytes 는 Sink 인터페이스 구현이며, UploadBytesWithRetryCtx 로 위임한다.
+func (u *S3Uploader) PutBytes(ctx context.Context, bucket, key string, body []byte) error {
+	if !u.breaker.allow() {
+		return errS3BreakerOpen
+	}
+	err := u.UploadBytesWithRetryCtx(ctx, bucket, key, body)
+	u.recordResult(ctx, err)
+	return err
+}
+
+// PutReader 는 Sink 인터페이스 구현이며, UploadFileWithRetryCtx 로 위임한다.
+func (u *S3Uploader) PutReader(ctx context.Context, bucket, key string, r io.ReadSeeker, size int64) error {
+	if !u.breaker.allow() {
+		return errS3BreakerOpen
+	}
+	err := u.UploadFileWithRetryCtx(ctx, bucket, key, r, size)
+	u.recordResult(ctx, err)
+	return err
+}
+
+// Available 은 Sink 인터페이스 구현이며, circuit breaker 가 S3 호출을 허용할 상태인지 반환한다.
+func (u *S3Uploader) Available() bool {
+	return u.breaker.ready()
+}
+
+// recordResult 는 readiness 판단용 연속 실패 gauge(S3ConsecutiveFailures)와 circuit breaker 를 갱신한다.
+//
+//   - 재시도까지 모두 실패한 업로드 1건(배치 또는 DLQ 파일)을 1 로 센다. (시도 횟수가 아니다)
+//   - shutdown 등으로 ctx 가 취소되어 중단된 업로드는 S3 상태와 무관하므로 세지 않는다.
+//   - 성공 1건으로 0 이 된다.
+func (u *S3Uploader) recordResult(ctx context.Context, err error) {
+	u.breaker.record(ctx, err)
+
+	switch {
+	case err == nil:
+		atomic.StoreInt64(&u.metrics.S3ConsecutiveFailures, 0)
+	case errors.Is(err, context.Canceled), ctx.Err() != nil:
+	default:
+		atomic.AddInt64(&u.metrics.S3ConsecutiveFailures, 1)
+	}
+}
+
 // UploadBytesWithRetryCtx
 //
 // 메모리에 이미 존재하는 gzip+JSONL 바이트 배열을 S3로 업로드한다.
-// - 각 업로드는 5초 timeout
-// - retry + exponential backoff 포함
-// - shutdown-safe: ctx.Done() 시 즉시 중단
-//
-// [최적화] 타이머 리소스 관리 (NewTimer)
+// 재시도 / backoff / 오류 분류 / shutdown 처리는 retry 가 담당한다.
 func (u *S3Uploader) UploadBytesWithRetryCtx(
 	ctx context.Context,
+	bucket string,
 	key string,
 	body []byte,
 ) error {
-
-	var lastErr error
-	backoff := 200 * time.Millisecond
-
-	for attempt := 1; attempt <= u.cfg.S3AppRetries; attempt++ {
-
-		// 1. Shutdown 신호 감지 (Fast check)
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		// 2. 업로드 시도 (Reader 생성 비용은 매우 저렴)
-		reader := bytes.NewReader(body)
-		if err := u.putObject(ctx, key, reader, int64(len(body))); err == nil {
-			return nil // 성공
-		} else {
-			lastErr = err
-			atomic.AddInt64(&u.metrics.S3PutErrorsTotal, 1)
-
-			log.Warn().
-				Err(err).
-				Str("key", key).
-				Int("attempt", attempt).
-				Msg("S3 upload failed, will retry")
-		}
-
-		// 3. Backoff 대기 (Timer 최적화)
-		// time.After()는 함수가 리턴될 때까지 GC되지 않는 메모리 누수 가능성이 있음.
-		// NewTimer + Stop 패턴으로 리소스를 즉시 해제한다.
-		timer := time.NewTimer(backoff)
-		select {
-		case <-ctx.Done():
-			timer.Stop() // 컨텍스트 취소 시 타이머 즉시 해제
-			return ctx.Err()
-		case <-timer.C:
-			// 대기 완료, 다음 루프 진행 (timer는 GC됨)
-		}
-
-		// Backoff 증가 (최대 2초)
-		backoff *= 2
-		if backoff > 2*time.Second {
-			backoff = 2 * time.Second
-		}
-	}
-
-	if lastErr != nil {
-		log.Error().
-			Err(lastErr).
-			Str("key", key).
-			Int("retries", u.cfg.S3AppRetries).
-			Msg("S3 upload failed after all retries")
-	}
-
-	return lastErr
+	return u.retry(ctx, key, "S3 upload", func(ctx context.Context) error {
+		// Reader 생성 비용은 매우 저렴하므로 시도마다 새로 만든다.
+		return u.putObject(ctx, bucket, key, bytes.NewReader(body), int64(len(body)))
+	})
 }
 
 // UploadFileWithRetryCtx
 // -----------------------
 // 로컬 DLQ에 저장된 파일을 그대로 S3로 업로드할 때 사용한다.
-// - io.ReadSeeker를 사용하여 retry 시 Seek(0)으로 rewind 가능
-// - shutdown-safe + retry/backoff 동일 적용
+// - io.ReadSeeker를 사용하여 시도마다 Seek(0)으로 rewind 한다
 // - 파일 크기는 caller에서 받아 전달한다.
 func (u *S3Uploader) UploadFileWithRetryCtx(
 	ctx context.Context,
+	bucket string,
 	key string,
 	f io.ReadSeeker,
 	size int64,
 ) error {
+	return u.retry(ctx, key, "DLQ reupload", func(ctx context.Context) error {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return u.putObject(ctx, bucket, key, f, size)
+	})
+}
 
-	var lastErr error
-	backoff := 200 * time.Millisecond
+// retry 는 fn 을 S3AppRetries 회까지 시도한다.
+// PutObject 와 multipart 의 각 단계(Create / UploadPart / Complete)가 모두 이 loop 를 사용한다.
+//
+//   - 시도당 S3Timeout 을 적용하고 소요 시간을 s3_put_duration_seconds 에 기록한다.
+//   - 실패한 시도는 classifyS3Error 로 분류해 센다. non-retryable 이면 재시도 없이 바로 반환한다
+//     (caller 는 곧바로 DLQ 로 보낸다).
+//   - 재시도 간격은 s3Backoff 이다 (throttling 이면 jitter). 마지막 시도 뒤에는 기다리지 않는다.
+//   - shutdown-safe: ctx 가 취소되면 즉시 ctx.Err() 를 반환한다.
+//
+// [최적화] 타이머 리소스 관리 (NewTimer)
+// time.After()는 타이머가 만료될 때까지 GC되지 않으므로, NewTimer + Stop 패턴으로 즉시 해제한다.
+func (u *S3Uploader) retry(ctx context.Context, key, op string, fn func(context.Context) error) error {
+	var (
+		lastErr error
+		backoff s3Backoff
+	)
 
 	for attempt := 1; attempt <= u.cfg.S3AppRetries; attempt++ {
+		// 1. Shutdown 신호 감지 (Fast check)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-		// shutdown 체크
-		select {
-		case <-ctx.Done():
+		// 2. 시도 (1회 시도당 timeout 적용)
+		ctx2, cancel := context.WithTimeout(ctx, u.cfg.S3Timeout)
+		start := time.Now()
+		err := fn(ctx2)
+		u.metrics.S3PutDurationSeconds.Observe(time.Since(start).Seconds())
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			// shutdown 으로 중단된 시도는 S3 오류가 아니다.
 			return ctx.Err()
-		default:
 		}
 
-		if err := u.putObject(ctx, key, f, size); err == nil {
-			return nil
-		} else {
-			lastErr = err
-			atomic.AddInt64(&u.metrics.S3PutErrorsTotal, 1)
+		// 3. 오류 분류
+		lastErr = err
+		class := classifyS3Error(err)
+		countS3Error(u.metrics, class)
 
-			log.Warn().
+		if !class.retryable() {
+			log.Error().
 				Err(err).
 				Str("key", key).
+				Str("class", class.String()).
 				Int("attempt", attempt).
-				Msg("DLQ reupload failed, will retry")
+				Msg(op + " failed (non-retryable)")
+			return err
 		}
+		if attempt == u.cfg.S3AppRetries {
+			break
+		}
+
+		log.Warn().
+			Err(err).
+			Str("key", key).
+			Str("class", class.String()).
+			Int("attempt", attempt).
+			Msg(op + " failed, will retry")
 
-		// backoff 적용
+		// 4. Backoff 대기
+		timer := time.NewTimer(backoff.wait(class))
 		select {
 		case <-ctx.Done():
+			timer.Stop() // 컨텍스트 취소 시 타이머 즉시 해제
 			return ctx.Err()
-		case <-time.After(backoff):
-			backoff *= 2
-			if backoff > 2*time.Second {
-				backoff = 2 * time.Second
-			}
+		case <-timer.C:
 		}
-
-		// retry 시 파일 포인터 초기화
-		f.Seek(0, io.SeekStart)
 	}
 
-	// 모든 재시도 실패
 	if lastErr != nil {
 		log.Error().
 			Err(lastErr).
 			Str("key", key).
 			Int("retries", u.cfg.S3AppRetries).
-			Msg("DLQ reupload failed after all retries")
+			Msg(op + " failed after all retries")
 	}
-
 	return lastErr
 }
 
 // putObject
 // ---------
 // 실제 AWS S3 PutObject 호출을 수행한다.
-// - retries는 caller에서 제어하며 여기서는 1회 호출만 담당
-// - 각 호출은 컨텍스트 기반 5초 timeout을 가진다
+// - retries / timeout 은 retry 에서 제어하며 여기서는 1회 호출만 담당
 //
-// bucket은 RawBucket 또는 DLQPrefix에 따라 달라지며,
+// bucket은 tenant 버킷이며 빈 값이면 RawBucket 을 사용한다.
 // key는 caller가 완성하여 전달한다.
 func (u *S3Uploader) putObject(
 	ctx context.Context,
+	bucket string,
 	key string,
 	body io.Reader,
 	size int64,
 ) error {
+	if bucket == "" {
+		bucket = u.cfg.RawBucket
+	}
 
-	// 1회 시도당 timeout 적용
-	ctx2, cancel := context.WithTimeout(ctx, u.cfg.S3Timeout)
-	defer cancel()
-
-	_, err := u.client.PutObject(ctx2, &s3.PutObjectInput{
-		Bucket:        aws.String(u.cfg.RawBucket),
+	_, err := u.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
 		Key:           aws.String(key),
 		Body:          body,
 		ContentLength: aws.Int64(size),
 	})
-
 	return err
 }
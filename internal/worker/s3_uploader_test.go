@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"estat-ingest/internal/metrics"
+)
+
+// readiness gauge 는 재시도 소진 업로드만 세고, shutdown 취소는 세지 않는다.
+func TestS3UploaderRecordResult(t *testing.T) {
+	m := metrics.New()
+	u := &S3Uploader{metrics: m}
+	ctx := context.Background()
+
+	u.recordResult(ctx, errors.New("500"))
+	u.recordResult(ctx, errors.New("500"))
+	if m.S3ConsecutiveFailures != 2 {
+		t.Fatalf("S3ConsecutiveFailures = %d, want 2", m.S3ConsecutiveFailures)
+	}
+
+	u.recordResult(ctx, fmt.Errorf("put: %w", context.Canceled))
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	u.recordResult(cancelled, errors.New("request send failed"))
+	if m.S3ConsecutiveFailures != 2 {
+		t.Fatalf("cancelled uploads counted: %d", m.S3ConsecutiveFailures)
+	}
+
+	u.recordResult(ctx, nil)
+	if m.S3ConsecutiveFailures != 0 {
+		t.Fatalf("S3ConsecutiveFailures = %d after success, want 0", m.S3ConsecutiveFailures)
+	}
+}
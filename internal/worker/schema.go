@@ -0,0 +1,244 @@
+package worker
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+
+	"github.com/rs/zerolog/log"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// ------------------------------------------------------------
+// JSON Schema 검증 (SCHEMA_MODE=report|enforce)
+//
+// SCHEMA_DIR 의 <이벤트 타입>.json 을 이벤트 타입별 schema 로 읽고,
+// 업로드 worker 가 인코딩 직전에 이벤트 body 를 검증한다.
+//
+//   - body 가 JSON 이면 그대로, 아니면 QueryString 으로 디코딩한 객체(값은 문자열)를 검증한다.
+//   - 이벤트 타입은 그 객체의 SCHEMA_TYPE_FIELD 값이다 (기본 "e").
+//   - 타입을 읽지 못했거나 schema 가 없으면 검증하지 않는다 (schema_unmatched_total).
+//
+// 모드:
+//   - report  : schema 별 valid / invalid 만 센다. 저장 위치와 내용은 바뀌지 않는다.
+//   - enforce : invalid 이벤트는 validation_error 를 채워 INVALID_PREFIX 에 따로 저장한다.
+//
+// schema 는 시작 시 한 번만 읽는다 (변경 시 재배포).
+// $ref 는 SCHEMA_DIR 안의 파일만 사용할 수 있다 (원격 schema 를 가져오지 않는다).
+// ------------------------------------------------------------
+
+// schemaNameRe 는 schema 파일 이름(= 이벤트 타입, 지표 label)으로 허용하는 문자이다.
+var schemaNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// maxValidationErrorLen 은 validation_error 의 최대 길이이다 (오류가 많은 이벤트가 객체를 키우지 않도록).
+const maxValidationErrorLen = 1024
+
+var errSchemaUnmatched = errors.New("schema: unmatched")
+
+type schemaEntry struct {
+	schema *jsonschema.Schema
+	result *metrics.SchemaResult
+}
+
+// SchemaValidator 는 이벤트 타입별 schema 이다. SCHEMA_MODE=off 이면 nil 이다.
+type SchemaValidator struct {
+	enforce   bool
+	typeField string
+	maxKeys   int
+	schemas   map[string]schemaEntry
+	metrics   *metrics.Metrics
+}
+
+// NewSchemaValidator 는 SCHEMA_DIR 의 schema 를 컴파일한다.
+// SCHEMA_MODE=off 이면 nil 을 반환하고, schema 오류는 즉시 종료한다.
+func NewSchemaValidator(cfg config.Config, m *metrics.Metrics) *SchemaValidator {
+	if cfg.SchemaMode == "off" || cfg.SchemaMode == "" {
+		return nil
+	}
+
+	schemas, names, err := loadSchemas(cfg.SchemaDir)
+	if err != nil {
+		log.Fatal().Err(err).Str("dir", cfg.SchemaDir).Msg("failed to load JSON schemas")
+	}
+
+	v := &SchemaValidator{
+		enforce:   cfg.SchemaMode == "enforce",
+		typeField: cfg.SchemaTypeField,
+		maxKeys:   cfg.StructuredMaxKeys,
+		schemas:   make(map[string]schemaEntry, len(names)),
+		metrics:   m,
+	}
+	results := m.InitSchemas(names)
+	for i, name := range names {
+		v.schemas[name] = schemaEntry{schema: schemas[name], result: &results[i]}
+	}
+
+	log.Info().
+		Str("dir", cfg.SchemaDir).
+		Str("mode", cfg.SchemaMode).
+		Str("type_field", cfg.SchemaTypeField).
+		Strs("schemas", names).
+		Msg("JSON schemas loaded")
+	return v
+}
+
+// loadSchemas 는 dir 의 *.json 을 컴파일하고 이름 순으로 정렬된 schema 이름 목록을 반환한다.
+func loadSchemas(dir string) (map[string]*jsonschema.Schema, []string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no *.json schema in %s", dir)
+	}
+
+	c := jsonschema.NewCompiler()
+	c.LoadURL = func(s string) (io.ReadCloser, error) {
+		if !strings.HasPrefix(s, "file://") {
+			return nil, fmt.Errorf("remote schema not allowed: %s", s)
+		}
+		return jsonschema.LoadURL(s)
+	}
+
+	schemas := make(map[string]*jsonschema.Schema, len(paths))
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		name := strings.TrimSuffix(filepath.Base(p), ".json")
+		if !schemaNameRe.MatchString(name) {
+			return nil, nil, fmt.Errorf("invalid schema name %q (expected [A-Za-z0-9_-])", name)
+		}
+		s, err := c.Compile(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", filepath.Base(p), err)
+		}
+		schemas[name] = s
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return schemas, names, nil
+}
+
+// Split 은 events 를 검증해 schema 별로 센다.
+// enforce 모드이면 invalid 이벤트를 분리해 ValidationError 를 채워 반환한다 (순서 유지).
+// report 모드이거나 invalid 가 없으면 events 를 그대로 반환한다 (추가 할당 없음).
+func (v *SchemaValidator) Split(events []*model.Event) (valid, invalid []*model.Event) {
+	if v == nil {
+		return events, nil
+	}
+	if !v.enforce {
+		for _, ev := range events {
+			_ = v.validate(ev)
+		}
+		return events, nil
+	}
+
+	for i, ev := range events {
+		err := v.validate(ev)
+		switch {
+		case err != nil:
+			// 첫 번째 invalid 를 만난 시점에만 분리용 slice 를 만든다.
+			if invalid == nil {
+				valid = append(make([]*model.Event, 0, len(events)), events[:i]...)
+			}
+			ev.ValidationError = err.Error()
+			invalid = append(invalid, ev)
+		case invalid != nil:
+			valid = append(valid, ev)
+		}
+	}
+	if invalid == nil {
+		return events, nil
+	}
+	return valid, invalid
+}
+
+// validate 는 ev 를 해당 타입의 schema 로 검증한다.
+// schema 가 없으면 nil (schema_unmatched_total), invalid 이면 "<schema>: ..." 형태의 오류를 반환한다.
+func (v *SchemaValidator) validate(ev *model.Event) error {
+	doc, name, err := v.decode(ev.Body)
+	entry, ok := v.schemas[name]
+	if err != nil || !ok {
+		atomic.AddInt64(&v.metrics.SchemaUnmatchedTotal, 1)
+		return nil
+	}
+
+	if err := entry.schema.Validate(doc); err != nil {
+		atomic.AddInt64(&entry.result.Invalid, 1)
+		return fmt.Errorf("%s: %s", name, validationMessage(err))
+	}
+	atomic.AddInt64(&entry.result.Valid, 1)
+	return nil
+}
+
+// decode 는 body 를 검증할 값으로 디코딩하고 이벤트 타입을 읽는다.
+func (v *SchemaValidator) decode(body string) (any, string, error) {
+	var obj map[string]any
+
+	if trimmed := strings.TrimSpace(body); strings.HasPrefix(trimmed, "{") {
+		// 숫자는 json.Number 로 받아 정수/실수 구분(integer, multipleOf 등)을 잃지 않는다.
+		dec := stdjson.NewDecoder(strings.NewReader(trimmed))
+		dec.UseNumber()
+		if err := dec.Decode(&obj); err != nil {
+			return nil, "", err
+		}
+	} else {
+		params, err := decodeParams(body, v.maxKeys)
+		if err != nil {
+			return nil, "", err
+		}
+		obj = params
+	}
+
+	name, _ := obj[v.typeField].(string)
+	if name == "" {
+		return nil, "", errSchemaUnmatched
+	}
+	return obj, name, nil
+}
+
+// validationMessage 는 검증 오류의 leaf 원인들을 "<instance 위치>: <오류>; ..." 로 요약한다.
+// (ValidationError.Error() 는 서버의 schema 파일 경로를 포함하므로 그대로 저장하지 않는다)
+func validationMessage(err error) string {
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err.Error()
+	}
+
+	var b bytes.Buffer
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, c := range e.Causes {
+				walk(c)
+			}
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteString("; ")
+		}
+		loc := e.InstanceLocation
+		if loc == "" {
+			loc = "/"
+		}
+		b.WriteString(loc)
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	}
+	walk(ve)
+
+	if b.Len() > maxValidationErrorLen {
+		return strings.ToValidUTF8(string(b.Bytes()[:maxValidationErrorLen]), "") + "..."
+	}
+	return b.String()
+}
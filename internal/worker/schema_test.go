@@ -0,0 +1,133 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+)
+
+func newTestSchemaValidator(t *testing.T, mode string) (*SchemaValidator, *metrics.Metrics) {
+	t.Helper()
+	m := metrics.New()
+	v := NewSchemaValidator(config.Config{
+		SchemaMode:        mode,
+		SchemaDir:         "../../docs/schemas",
+		SchemaTypeField:   "e",
+		StructuredMaxKeys: 100,
+	}, m)
+	return v, m
+}
+
+func schemaResult(m *metrics.Metrics, name string) metrics.SchemaResult {
+	for _, r := range m.SchemaResults {
+		if r.Name == name {
+			return r
+		}
+	}
+	return metrics.SchemaResult{}
+}
+
+func TestSchemaSplitEnforce(t *testing.T) {
+	v, m := newTestSchemaValidator(t, "enforce")
+
+	okPV := &model.Event{Body: "e=pv&url=https%3A%2F%2Fex.com%2F"}
+	badPV := &model.Event{Body: "e=pv&sid=XYZ"}
+	okClick := &model.Event{Body: `{"e":"click","target":"#buy","x":10}`}
+	badClick := &model.Event{Body: `{"e":"click","target":"#buy","x":1.5}`}
+	unknown := &model.Event{Body: `{"e":"scroll"}`}
+	notJSON := &model.Event{Body: "{broken"}
+
+	events := []*model.Event{okPV, badPV, okClick, badClick, unknown, notJSON}
+	valid, invalid := v.Split(events)
+
+	wantValid := []*model.Event{okPV, okClick, unknown, notJSON}
+	if len(valid) != len(wantValid) {
+		t.Fatalf("valid = %d events, want %d", len(valid), len(wantValid))
+	}
+	for i := range wantValid {
+		if valid[i] != wantValid[i] {
+			t.Fatalf("valid[%d] = %q, want %q", i, valid[i].Body, wantValid[i].Body)
+		}
+	}
+	if len(invalid) != 2 || invalid[0] != badPV || invalid[1] != badClick {
+		t.Fatalf("invalid = %v", invalid)
+	}
+
+	if !strings.HasPrefix(badPV.ValidationError, "pv: ") ||
+		!strings.Contains(badPV.ValidationError, "url") ||
+		!strings.Contains(badPV.ValidationError, "/sid") {
+		t.Fatalf("badPV.ValidationError = %q", badPV.ValidationError)
+	}
+	if !strings.HasPrefix(badClick.ValidationError, "click: /x: ") {
+		t.Fatalf("badClick.ValidationError = %q", badClick.ValidationError)
+	}
+	if strings.Contains(badPV.ValidationError, "file://") {
+		t.Fatalf("ValidationError leaks schema path: %q", badPV.ValidationError)
+	}
+	if okPV.ValidationError != "" || okClick.ValidationError != "" {
+		t.Fatal("valid events should not carry ValidationError")
+	}
+
+	if r := schemaResult(m, "pv"); r.Valid != 1 || r.Invalid != 1 {
+		t.Fatalf("pv result = %+v", r)
+	}
+	if r := schemaResult(m, "click"); r.Valid != 1 || r.Invalid != 1 {
+		t.Fatalf("click result = %+v", r)
+	}
+	if m.SchemaUnmatchedTotal != 2 {
+		t.Fatalf("SchemaUnmatchedTotal = %d, want 2", m.SchemaUnmatchedTotal)
+	}
+}
+
+func TestSchemaSplitReport(t *testing.T) {
+	v, m := newTestSchemaValidator(t, "report")
+
+	events := []*model.Event{{Body: "e=pv"}, {Body: "e=pv&url=x"}}
+	valid, invalid := v.Split(events)
+	if len(valid) != 2 || invalid != nil {
+		t.Fatalf("report mode split events: valid=%d invalid=%d", len(valid), len(invalid))
+	}
+	if events[0].ValidationError != "" {
+		t.Fatal("report mode should not modify events")
+	}
+	if r := schemaResult(m, "pv"); r.Valid != 1 || r.Invalid != 1 {
+		t.Fatalf("pv result = %+v", r)
+	}
+}
+
+func TestSchemaValidatorOff(t *testing.T) {
+	v, _ := newTestSchemaValidator(t, "off")
+	if v != nil {
+		t.Fatal("SCHEMA_MODE=off should return nil")
+	}
+	events := []*model.Event{{Body: "e=pv"}}
+	if valid, invalid := v.Split(events); len(valid) != 1 || invalid != nil {
+		t.Fatal("nil validator should pass events through")
+	}
+}
+
+func TestLoadSchemasRejectsRemoteRef(t *testing.T) {
+	dir := t.TempDir()
+	schema := `{"$ref": "https://example.com/schema.json"}`
+	if err := os.WriteFile(filepath.Join(dir, "pv.json"), []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadSchemas(dir); err == nil {
+		t.Fatal("remote $ref should fail")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "pv.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bad name.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadSchemas(dir); err == nil || !strings.Contains(err.Error(), "invalid schema name") {
+		t.Fatalf("loadSchemas err = %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+// internal/worker/sink.go
+package worker
+
+import (
+	"context"
+	"io"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Sink 는 인코딩이 끝난 배치(JSONL.gz)를 최종 저장소에 기록하는 추상화이다.
+//
+// Manager(정상 업로드)와 DLQManager(재업로드)는 이 인터페이스만 알고,
+// 실제 목적지가 S3 인지 로컬 파일시스템인지는 알지 못한다.
+//
+// 구현체:
+//   - S3Uploader : AWS S3 PutObject / multipart upload (운영 기본값)
+//   - LocalSink  : 로컬 디렉토리에 key 경로 그대로 저장 (개발/CI 용)
+//
+// 구현 규칙:
+//   - key 는 BuildS3Key 로 만든 "<prefix>/dt=.../hr=.../<file>" 형태이다. (S3_KEY_TEMPLATE 에 따라 다름)
+//   - bucket 은 tenant 별 버킷(TENANTS_FILE)이며, 빈 값이면 RAW_BUCKET 이다.
+//   - 재시도가 필요한 구현은 메서드 내부에서 재시도까지 끝내고 최종 결과만 반환한다.
+//   - ctx 취소 시 가능한 한 빨리 ctx.Err() 를 반환해야 한다 (shutdown-safe).
+type Sink interface {
+	// PutBytes 는 메모리에 있는 바이트 배열을 bucket/key 로 저장한다.
+	PutBytes(ctx context.Context, bucket, key string, body []byte) error
+
+	// PutReader 는 r 의 내용을 bucket/key 로 저장한다.
+	// 재시도 시 rewind 할 수 있도록 io.ReadSeeker 를 받는다.
+	PutReader(ctx context.Context, bucket, key string, r io.ReadSeeker, size int64) error
+
+	// PutStream 은 write 가 w 에 쓰는 내용을 bucket/key 로 저장한다.
+	// 크기를 미리 알 수 없고 전체를 메모리에 두지 않는 큰 배치용이다 (S3: multipart upload).
+	// 재시도 시 처음부터 다시 쓸 수 없으므로, 재시도는 구현체 내부(part 단위)에서만 한다.
+	PutStream(ctx context.Context, bucket, key string, write func(w io.Writer) error) error
+
+	// Available 은 지금 저장을 시도해도 되는지 반환한다.
+	// S3Uploader 는 circuit breaker 가 open 이면 false 이며, DLQ 재업로드는 이때 멈춘다.
+	Available() bool
+}
+
+// NewSink 는 cfg.SinkType 에 맞는 Sink 구현체를 생성한다.
+// config.Load 에서 SinkType 을 검증하므로 여기서 알 수 없는 값은 들어오지 않는다.
+func NewSink(cfg config.Config, m *metrics.Metrics) Sink {
+	switch cfg.SinkType {
+	case "local":
+		log.Info().
+			Str("dir", cfg.LocalSinkDir).
+			Msg("using local filesystem sink")
+		return NewLocalSink(cfg)
+	default:
+		return NewS3Uploader(cfg, m)
+	}
+}
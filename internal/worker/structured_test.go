@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+
+	json "github.com/goccy/go-json"
+	"github.com/klauspost/compress/gzip"
+)
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr error
+	}{
+		{"e=pv&url=https%3A%2F%2Fex.com%2F%3Fa%3D1", `{"e":"pv","url":"https://ex.com/?a=1"}`, nil},
+		{"tag=a&tag=b&tag=c&q=%ED%95%9C+%EA%B8%80", `{"q":"한 글","tag":["a","b","c"]}`, nil},
+		{"flag&&empty=", `{"empty":"","flag":""}`, nil},
+		{"a=%zz", "", errParamsInvalid},
+		{"q=%C7%D1", "", errParamsInvalid}, // EUC-KR "한"
+		{"a=1&b=2&c=3&d=4&e=5", "", errParamsTooManyKeys},
+	}
+
+	for _, tt := range tests {
+		got, err := parseParams(tt.query, 4)
+		if err != tt.wantErr {
+			t.Errorf("parseParams(%q) err = %v, want %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("parseParams(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestEncoderStructuredBody(t *testing.T) {
+	m := metrics.New()
+	e := NewEncoder(config.Config{BodyFormat: "structured", StructuredMaxKeys: 10, StructuredMaxBytes: 64}, m)
+
+	events := []*model.Event{
+		{Body: "e=pv&id=1", BodyType: "query"},
+		{Body: `{"e":"click","n":[1,2]}`, BodyType: "post"},
+		{Body: "not json", BodyType: "post"},
+		{Body: "a=%zz", BodyType: "query"},
+		{Body: "e=" + string(bytes.Repeat([]byte("x"), 100)), BodyType: "query"},
+		{Body: "e=pv"}, // BODY_FORMAT=raw 시절 WAL 에서 복구된 이벤트
+	}
+
+	buf, err := e.EncodeBatchJSONLGZ(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(out), []byte("\n"))
+	if len(lines) != len(events) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(events))
+	}
+
+	var first struct {
+		Body   string            `json:"body"`
+		Params map[string]string `json:"params"`
+	}
+	if err := json.Unmarshal(lines[0], &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Body != "e=pv&id=1" || first.Params["e"] != "pv" || first.Params["id"] != "1" {
+		t.Fatalf("line 0 = %s", lines[0])
+	}
+	if !bytes.Contains(lines[1], []byte(`"body_json":{"e":"click","n":[1,2]}`)) {
+		t.Fatalf("line 1 = %s", lines[1])
+	}
+	for _, i := range []int{2, 3, 4, 5} {
+		if bytes.Contains(lines[i], []byte(`"params"`)) || bytes.Contains(lines[i], []byte(`"body_json"`)) {
+			t.Fatalf("line %d should not be structured: %s", i, lines[i])
+		}
+	}
+
+	if m.BodyParamsTotal != 1 || m.BodyJSONTotal != 1 || m.BodyJSONInvalidTotal != 1 ||
+		m.BodyParamsInvalidTotal != 1 || m.BodyStructuredTooLargeTotal != 1 {
+		t.Fatalf("metrics params=%d json=%d json_invalid=%d params_invalid=%d too_large=%d",
+			m.BodyParamsTotal, m.BodyJSONTotal, m.BodyJSONInvalidTotal,
+			m.BodyParamsInvalidTotal, m.BodyStructuredTooLargeTotal)
+	}
+}
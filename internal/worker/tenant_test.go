@@ -0,0 +1,117 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+	"estat-ingest/internal/partition"
+	"estat-ingest/internal/tenant"
+)
+
+func loadTestTenants(t *testing.T, body string) *tenant.Table {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tb, err := tenant.Load(path, tenant.Defaults{Bucket: "raw-bucket", Prefix: "raw", MaxBodySize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tb
+}
+
+// collectLoop 는 tenant 별로 배치를 모으므로 한 UploadJob 에 여러 tenant 가 섞이지 않는다.
+func TestCollectLoopBatchesPerTenant(t *testing.T) {
+	m := &Manager{
+		cfg:      config.Config{BatchSize: 2, FlushInterval: time.Hour},
+		metrics:  metrics.New(),
+		EventCh:  make(chan *model.Event, 10),
+		uploadCh: make(chan model.UploadJob, 10),
+	}
+	m.wg.Add(1)
+	go m.collectLoop()
+
+	for _, tn := range []string{"a", "b", "a", "", "b", "a"} {
+		m.EventCh <- &model.Event{Tenant: tn}
+	}
+	close(m.EventCh)
+
+	counts := make(map[string]int)
+	var jobs []model.UploadJob
+	for job := range m.uploadCh {
+		jobs = append(jobs, job)
+		for _, ev := range job.Events {
+			if ev.Tenant != job.Tenant {
+				t.Fatalf("job for %q contains event of %q", job.Tenant, ev.Tenant)
+			}
+		}
+		counts[job.Tenant] += len(job.Events)
+	}
+
+	// a: 2개로 count flush + 종료 시 1개, b: 2개로 count flush, "": 종료 시 1개
+	if len(jobs) != 4 {
+		t.Fatalf("jobs = %d, want 4", len(jobs))
+	}
+	if counts["a"] != 3 || counts["b"] != 2 || counts[""] != 1 {
+		t.Fatalf("counts = %v", counts)
+	}
+}
+
+func TestDestination(t *testing.T) {
+	m := &Manager{cfg: config.Config{
+		RawPrefix:     "raw",
+		BotPrefix:     "bot",
+		InvalidPrefix: "invalid",
+		DLQPrefix:     "dlq",
+		Tenants:       loadTestTenants(t, `{"tenants": [{"id": "shop", "bucket": "shop-raw", "prefix": "shop/events"}, {"id": "blog"}]}`),
+	}}
+
+	tests := []struct {
+		tenant string
+		want   destination
+	}{
+		{"", destination{raw: "raw", bot: "bot", invalid: "invalid", dlq: "dlq"}},
+		{"shop", destination{tenant: "shop", bucket: "shop-raw", raw: "shop/events", bot: "bot/shop", invalid: "invalid/shop", dlq: "dlq/shop"}},
+		{"blog", destination{tenant: "blog", bucket: "raw-bucket", raw: "raw/blog", bot: "bot/blog", invalid: "invalid/blog", dlq: "dlq/blog"}},
+		{"gone", destination{tenant: "gone", raw: "raw/gone", bot: "bot/gone", invalid: "invalid/gone", dlq: "dlq/gone"}},
+	}
+	for _, tt := range tests {
+		if got := m.destination(tt.tenant); got != tt.want {
+			t.Errorf("destination(%q) = %+v, want %+v", tt.tenant, got, tt.want)
+		}
+	}
+}
+
+// S3_KEY_TEMPLATE 의 {tenant} 는 업로드 key 와 DLQ 재업로드 key 모두에 tenant id 로 채워진다.
+func TestKeyTemplateTenant(t *testing.T) {
+	loc := Location()
+	SetLocation(time.UTC)
+	SetKeyTemplate(partition.MustParse("{prefix}/tenant={tenant}/dt={yyyy}-{mm}-{dd}/{file}"))
+	t.Cleanup(func() {
+		SetLocation(loc)
+		SetKeyTemplate(partition.MustParse(partition.DefaultTemplate))
+	})
+
+	m := &Manager{cfg: config.Config{
+		RawPrefix: "raw",
+		DLQPrefix: "dlq",
+		Tenants:   loadTestTenants(t, `{"tenants": [{"id": "shop", "prefix": "events"}]}`),
+	}}
+	dst := m.destination("shop")
+	if key := BuildS3Key(dst.raw, dst.tenant, "f.jsonl.gz"); !strings.HasPrefix(key, "events/tenant=shop/dt=") {
+		t.Fatalf("upload key = %q", key)
+	}
+
+	d := &DLQManager{cfg: m.cfg}
+	meta := dst.dlqMeta(1, dst.raw, "")
+	if key := d.replayKey("1700000000_test_1.jsonl.gz", meta, false); key != "dlq/shop/tenant=shop/dt=2023-11-14/1700000000_test_1.jsonl.gz" {
+		t.Fatalf("replay key = %q", key)
+	}
+}
@@ -4,37 +4,44 @@ package worker
 import (
 	"sync/atomic"
 	"time"
+
+	"estat-ingest/internal/partition"
 )
 
 //
 // timecache.go
 // ------------------------------------------------------------
 // 매초(time.Now 호출 비용을 줄이기 위해) 현재 UTC epoch seconds,
-// 그리고 KST 기준 날짜/시간 파티션을 캐싱하는 모듈.
+// 그리고 파티션 타임존 기준 날짜/시간 파티션 값을 캐싱하는 모듈.
 //
 // ingest 서버는 초당 수천~수만 개의 이벤트를 처리하므로,
 // 매 이벤트마다 time.Now() 호출하면 불필요한 시스템 콜 증가.
 // 따라서 1초 ticker로 캐싱 후 초단위 정밀도만 유지한다.
 //
+// 파티션 타임존은 기본 KST(Asia/Seoul)이며,
+// SetLocation 으로 변경할 수 있다 (NewManager 에서 PARTITION_TZ 적용).
+//
 // 사용처:
 //   - Event.Ts (수집시각)
-//   - S3 파티션 prefix (dt=YYYY-MM-DD / hr=HH)
+//   - S3 파티션 prefix (dt=YYYY-MM-DD / hr=HH 등, BuildS3Key)
 // ------------------------------------------------------------
 
 var (
 	// 이벤트 timestamp(UTC epoch seconds)
 	unixSec atomic.Int64
 
-	// 날짜/시간 파티션(KST 기준)
-	dtVal atomic.Value // "YYYY-MM-DD"
-	hrVal atomic.Value // "HH"
+	// 파티션 타임존 및 현재 시각의 파티션 값
+	partLoc atomic.Pointer[time.Location]
+	partVal atomic.Pointer[partition.Parts]
 )
 
-const kstOffset = 9 * time.Hour
+// 기본 파티션 타임존 (기존 동작과 동일한 KST 고정)
+var defaultLoc = time.FixedZone("KST", 9*60*60)
 
 func init() {
 	// 최초 seed
-	seed()
+	partLoc.Store(defaultLoc)
+	update()
 
 	// 1초마다 갱신
 	go func() {
@@ -47,41 +54,47 @@ func init() {
 	}()
 }
 
-// 최초 시점 초기화
-func seed() {
-	now := time.Now()
-	unixSec.Store(now.Unix())
-
-	kst := now.Add(kstOffset)
-	dtVal.Store(kst.Format("2006-01-02"))
-	hrVal.Store(kst.Format("15"))
-}
-
 // 매초 업데이트
 func update() {
 	now := time.Now()
 	unixSec.Store(now.Unix())
 
-	kst := now.Add(kstOffset)
-	dtVal.Store(kst.Format("2006-01-02"))
-	hrVal.Store(kst.Format("15"))
+	p := partition.PartsOf(now, partLoc.Load())
+	partVal.Store(&p)
 }
 
 // ------------------------------------------------------------
 // Public API
 // ------------------------------------------------------------
 
+// SetLocation 은 파티션 계산 타임존을 변경하고 캐시를 즉시 갱신한다.
+func SetLocation(loc *time.Location) {
+	partLoc.Store(loc)
+	update()
+}
+
+// Location 은 현재 파티션 타임존을 반환한다.
+func Location() *time.Location {
+	return partLoc.Load()
+}
+
 // Unix returns current UTC epoch seconds (cached, 1-second precision).
 func Unix() int64 {
 	return unixSec.Load()
 }
 
-// DT returns "YYYY-MM-DD" (KST 기준).
+// Parts 는 현재 시각의 파티션 값(파티션 타임존 기준, 캐시)을 반환한다.
+func Parts() partition.Parts {
+	return *partVal.Load()
+}
+
+// DT returns "YYYY-MM-DD" (파티션 타임존 기준).
 func DT() string {
-	return dtVal.Load().(string)
+	p := Parts()
+	return p.Year + "-" + p.Month + "-" + p.Day
 }
 
-// HR returns "HH" (KST 기준).
+// HR returns "HH" (파티션 타임존 기준).
 func HR() string {
-	return hrVal.Load().(string)
+	return Parts().Hour
 }
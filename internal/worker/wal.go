@@ -0,0 +1,583 @@
+// internal/worker/wal.go
+package worker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+	"estat-ingest/internal/pool"
+
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+// WAL 은 HTTP 로 수락한 이벤트를 S3/DLQ 에 저장되기 전까지 디스크에 보관하는
+// segment 기반 Write-Ahead Log 이다.
+//
+// 흐름:
+//  1. Handler 가 Append → 레코드를 현재 세그먼트에 쓰고, group commit fsync 완료까지 대기
+//  2. fsync 가 끝나면 EventCh 로 push 후 200 반환
+//  3. 배치가 S3 또는 로컬 DLQ 에 저장되면 Manager 가 Ack
+//     → 레코드 오프셋을 세그먼트별 ack 파일(<id>.ack)에 append
+//  4. rotate 된(sealed) 세그먼트의 모든 이벤트가 Ack 되면 세그먼트/ack 파일 삭제
+//  5. 비정상 종료 후 재시작 시, 남아있는 세그먼트에서 ack 파일에 없는 이벤트만 NewManager 가 replay
+//
+// 레코드 포맷 (little endian):
+//
+//	[4 bytes payload length][4 bytes CRC32-C][payload: Event JSON]
+//
+// ack 파일 포맷: [8 bytes 레코드 시작 오프셋] 의 나열
+//
+// 마지막 레코드가 중간에 잘린 경우(torn write)는 CRC/길이 검사로 감지하고
+// 그 지점부터는 무시한다. ack 파일의 잘린 마지막 항목도 무시한다.
+//
+// 전달 보장은 at-least-once 이며, replay 시 중복될 수 있는 범위는 다음과 같다.
+//   - 저장(S3/DLQ)은 끝났지만 Ack 기록 전에 crash 한 배치 (동시에 처리 중인 최대 UploadWorkers 개 배치)
+//   - ack 파일은 fsync 하지 않는다. 프로세스 crash 에서는 page cache 에 남으므로 안전하지만,
+//     OS crash / 전원 손실 시에는 마지막으로 기록한 ack 가 사라져 해당 이벤트가 다시 업로드될 수 있다.
+//
+// 큐 full 등으로 거절된 이벤트도 Ack 로 기록되므로 replay 되지 않는다.
+//
+// 모든 메서드는 nil receiver 에서 no-op 이므로, WAL 비활성화 시 호출부에서 분기할 필요가 없다.
+type WAL struct {
+	metrics *metrics.Metrics
+
+	dir          string
+	segmentSize  int64
+	syncInterval time.Duration
+
+	mu     sync.Mutex
+	cur    *walSegment            // append 대상 세그먼트
+	bw     *bufio.Writer          // cur.f 에 대한 write buffer
+	segs   map[uint64]*walSegment // 디스크에 존재하는 모든 세그먼트
+	gen    *walSyncGen            // 다음 fsync 를 기다리는 append 들
+	dirty  bool                   // 마지막 fsync 이후 append 여부
+	closed bool
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// walSegment 는 세그먼트 파일 1개의 상태이다.
+type walSegment struct {
+	id      uint64
+	path    string
+	f       *os.File // sealed 이후에는 nil
+	ack     *os.File // ack 파일 (첫 Ack 시 lazy open)
+	size    int64
+	pending int64 // 아직 Ack 되지 않은 이벤트 수
+	sealed  bool  // rotate 완료 (더 이상 append 되지 않음)
+}
+
+// walSyncGen 은 한 번의 group commit 에 묶이는 append 들이 공유하는 완료 신호이다.
+type walSyncGen struct {
+	done chan struct{}
+	err  error
+}
+
+const (
+	walExt          = ".wal"
+	walAckExt       = ".ack"
+	walAckSize      = 8
+	walHeaderSize   = 8
+	walMaxRecordLen = 64 << 20 // 손상된 length 필드로 인한 대용량 할당 방지
+)
+
+var (
+	walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errWALClosed = errors.New("wal closed")
+)
+
+func newWALSyncGen() *walSyncGen {
+	return &walSyncGen{done: make(chan struct{})}
+}
+
+// OpenWAL 은 WAL 디렉토리를 스캔하여 이전 프로세스가 남긴 세그먼트의 이벤트를 복구하고,
+// 새 세그먼트를 열어 append 를 받을 준비를 한다.
+//
+// 복구된 이벤트는 원래 세그먼트 ID 를 WALSeg 로 가지므로,
+// 파이프라인에서 저장이 끝나 Ack 되면 기존 세그먼트도 자연스럽게 정리된다.
+func OpenWAL(cfg config.Config, m *metrics.Metrics) (*WAL, []*model.Event, error) {
+	if err := os.MkdirAll(cfg.WALDir, 0o755); err != nil {
+		return nil, nil, err
+	}
+
+	w := &WAL{
+		metrics:      m,
+		dir:          cfg.WALDir,
+		segmentSize:  cfg.WALSegmentSize,
+		syncInterval: cfg.WALSyncInterval,
+		segs:         make(map[uint64]*walSegment),
+		gen:          newWALSyncGen(),
+		stopCh:       make(chan struct{}),
+		doneCh:       make(chan struct{}),
+	}
+
+	ids, err := listWALSegments(cfg.WALDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var recovered []*model.Event
+	var lastID uint64
+
+	for _, id := range ids {
+		lastID = id
+		path := w.segmentPath(id)
+
+		acked, err := readWALAcks(w.ackPath(id))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			// ack 를 못 읽으면 전체를 replay 한다 (중복은 허용, 유실은 불가)
+			log.Warn().
+				Err(err).
+				Str("path", w.ackPath(id)).
+				Msg("WAL ack file unreadable → replaying whole segment")
+		}
+
+		evs, err := readWALSegment(path, id, acked)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("path", path).
+				Int("recovered", len(evs)).
+				Msg("WAL segment partially corrupted → recovered valid prefix")
+		}
+
+		if len(evs) == 0 {
+			_ = os.Remove(path)
+			_ = os.Remove(w.ackPath(id))
+			continue
+		}
+
+		w.segs[id] = &walSegment{
+			id:      id,
+			path:    path,
+			pending: int64(len(evs)),
+			sealed:  true,
+		}
+		atomic.AddInt64(&m.WALSegmentsCurrent, 1)
+		recovered = append(recovered, evs...)
+	}
+
+	w.removeOrphanAcks()
+
+	if err := w.openSegmentLocked(lastID + 1); err != nil {
+		return nil, nil, err
+	}
+
+	go w.syncLoop()
+
+	return w, recovered, nil
+}
+
+// Append 는 이벤트들을 현재 세그먼트에 기록하고, 이를 포함하는 fsync 가 끝날 때까지 대기한다.
+//
+// 성공 시 각 이벤트의 WALSeg 가 설정된다.
+// 실패 시 이번 호출에서 기록한 이벤트의 WALSeg 는 모두 되돌려지며,
+// 호출자는 이벤트를 EventCh 에 넣지 않고 거절해야 한다.
+func (w *WAL) Append(evs ...*model.Event) error {
+	if w == nil || len(evs) == 0 {
+		return nil
+	}
+
+	gen, err := w.appendLocked(evs)
+	if err == nil {
+		<-gen.done
+		err = gen.err
+	}
+
+	if err != nil {
+		atomic.AddInt64(&w.metrics.WALAppendErrorsTotal, int64(len(evs)))
+		// fsync 실패 시에도 레코드는 이미 써졌으므로 pending 만 되돌린다.
+		w.Ack(evs...)
+	}
+	return err
+}
+
+// appendLocked 는 레코드를 write buffer 에 쓰고, 대기해야 할 sync generation 을 반환한다.
+func (w *WAL) appendLocked(evs []*model.Event) (*walSyncGen, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil, errWALClosed
+	}
+
+	var hdr [walHeaderSize]byte
+
+	for _, ev := range evs {
+		payload, err := json.Marshal(ev)
+		if err == nil && w.cur.size >= w.segmentSize {
+			err = w.rotateLocked()
+		}
+		if err == nil {
+			binary.LittleEndian.PutUint32(hdr[0:4], uint32(len(payload)))
+			binary.LittleEndian.PutUint32(hdr[4:8], crc32.Checksum(payload, walCRCTable))
+			if _, err = w.bw.Write(hdr[:]); err == nil {
+				_, err = w.bw.Write(payload)
+			}
+		}
+
+		if err != nil {
+			// 이번 호출에서 이미 기록한 이벤트(WALSeg 설정됨)는 Append 에서 Ack 로 되돌린다.
+			return nil, err
+		}
+
+		ev.WALSeg = w.cur.id
+		ev.WALOff = w.cur.size
+		w.cur.size += int64(walHeaderSize + len(payload))
+		w.cur.pending++
+	}
+
+	w.dirty = true
+	return w.gen, nil
+}
+
+// Ack 는 S3 또는 로컬 DLQ 에 저장이 끝난 이벤트들을 WAL 에서 해제한다.
+// 레코드 오프셋을 세그먼트의 ack 파일에 기록하여 replay 대상에서 제외하고,
+// sealed 세그먼트의 모든 이벤트가 해제되면 세그먼트 파일을 삭제한다.
+//
+// 호출 후 이벤트의 WALSeg 는 0 으로 초기화되므로 중복 호출해도 안전하다.
+func (w *WAL) Ack(evs ...*model.Event) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// 배치는 보통 1~2개 세그먼트에 걸쳐 있으므로, 세그먼트별로 모아 한 번에 write 한다.
+	acks := make(map[*walSegment][]byte, 2)
+	for _, ev := range evs {
+		if ev.WALSeg == 0 {
+			continue
+		}
+
+		seg := w.segs[ev.WALSeg]
+		off := ev.WALOff
+		ev.WALSeg, ev.WALOff = 0, 0
+		if seg == nil {
+			continue
+		}
+
+		seg.pending--
+		acks[seg] = binary.LittleEndian.AppendUint64(acks[seg], uint64(off))
+	}
+
+	for seg, offs := range acks {
+		if seg.sealed && seg.pending <= 0 {
+			w.removeSegmentLocked(seg)
+			continue
+		}
+		w.writeAcksLocked(seg, offs)
+	}
+}
+
+// writeAcksLocked 는 Ack 된 레코드 오프셋을 세그먼트의 ack 파일에 append 한다.
+// 실패해도 해당 이벤트가 replay 시 중복될 뿐이므로 경고만 남긴다.
+func (w *WAL) writeAcksLocked(seg *walSegment, offs []byte) {
+	var err error
+	if seg.ack == nil {
+		seg.ack, err = os.OpenFile(w.ackPath(seg.id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	}
+	if err == nil {
+		_, err = seg.ack.Write(offs)
+	}
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Uint64("segment", seg.id).
+			Msg("WAL ack write failed (events may be replayed again)")
+	}
+}
+
+// Close 는 syncLoop 를 멈추고 마지막 fsync 후 현재 세그먼트를 닫는다.
+// Manager.Shutdown 에서 모든 goroutine 이 종료된 뒤 호출된다.
+// 모든 이벤트가 Ack 된 상태라면 현재 세그먼트도 삭제되어 WAL 디렉토리가 비워진다.
+func (w *WAL) Close() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.stopCh)
+	<-w.doneCh
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.sealLocked()
+	w.gen.err = err
+	close(w.gen.done)
+
+	for _, seg := range w.segs {
+		if seg.ack != nil {
+			_ = seg.ack.Close()
+			seg.ack = nil
+		}
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("WAL close failed")
+	}
+}
+
+// syncLoop 는 syncInterval 마다 write buffer 를 flush + fsync 하여
+// 그 사이에 들어온 append 들을 한 번에 commit 한다 (group commit).
+func (w *WAL) syncLoop() {
+	defer close(w.doneCh)
+
+	ticker := time.NewTicker(w.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+			w.syncOnce()
+		}
+	}
+}
+
+func (w *WAL) syncOnce() {
+	w.mu.Lock()
+	if !w.dirty {
+		w.mu.Unlock()
+		return
+	}
+
+	gen := w.gen
+	w.gen = newWALSyncGen()
+	w.dirty = false
+
+	err := w.bw.Flush()
+	f := w.cur.f
+	w.mu.Unlock()
+
+	// fsync 는 lock 밖에서 수행하여 다음 group 의 append 를 막지 않는다.
+	// 그 사이 rotate 로 파일이 닫혔다면, rotate 가 이미 fsync 를 끝낸 상태이다.
+	if err == nil {
+		if err = f.Sync(); errors.Is(err, os.ErrClosed) {
+			err = nil
+		}
+	}
+
+	gen.err = err
+	close(gen.done)
+}
+
+// rotateLocked 는 현재 세그먼트를 sealed 처리하고 다음 ID 의 세그먼트를 연다.
+func (w *WAL) rotateLocked() error {
+	next := w.cur.id + 1
+	if err := w.sealLocked(); err != nil {
+		return err
+	}
+	return w.openSegmentLocked(next)
+}
+
+// sealLocked 는 현재 세그먼트를 flush + fsync 후 닫는다.
+// 남은 이벤트가 없으면 바로 삭제한다.
+func (w *WAL) sealLocked() error {
+	seg := w.cur
+	if seg == nil || seg.f == nil {
+		return nil
+	}
+
+	err := w.bw.Flush()
+	if err == nil {
+		err = seg.f.Sync()
+	}
+	if cerr := seg.f.Close(); err == nil {
+		err = cerr
+	}
+
+	seg.f = nil
+	seg.sealed = true
+	if seg.pending <= 0 {
+		w.removeSegmentLocked(seg)
+	}
+	return err
+}
+
+func (w *WAL) openSegmentLocked(id uint64) error {
+	path := w.segmentPath(id)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	seg := &walSegment{id: id, path: path, f: f}
+	w.cur = seg
+	w.segs[id] = seg
+	atomic.AddInt64(&w.metrics.WALSegmentsCurrent, 1)
+
+	if w.bw == nil {
+		w.bw = bufio.NewWriterSize(f, 64*1024)
+	} else {
+		w.bw.Reset(f)
+	}
+	return nil
+}
+
+func (w *WAL) removeSegmentLocked(seg *walSegment) {
+	if _, ok := w.segs[seg.id]; !ok {
+		return
+	}
+	delete(w.segs, seg.id)
+	_ = os.Remove(seg.path)
+	if seg.ack != nil {
+		_ = seg.ack.Close()
+		seg.ack = nil
+	}
+	_ = os.Remove(w.ackPath(seg.id))
+	atomic.AddInt64(&w.metrics.WALSegmentsCurrent, -1)
+}
+
+// removeOrphanAcks 는 세그먼트 파일 없이 남은 ack 파일을 삭제한다.
+// (세그먼트 삭제 직후 ack 파일 삭제 전에 crash 한 경우)
+func (w *WAL) removeOrphanAcks() {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, walAckExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, walAckExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := w.segs[id]; !ok {
+			_ = os.Remove(filepath.Join(w.dir, name))
+		}
+	}
+}
+
+func (w *WAL) segmentPath(id uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%016d%s", id, walExt))
+}
+
+func (w *WAL) ackPath(id uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%016d%s", id, walAckExt))
+}
+
+// listWALSegments 는 디렉토리의 세그먼트 ID 를 오름차순으로 반환한다.
+func listWALSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, walExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, walExt), 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// readWALAcks 는 ack 파일에서 Ack 된 레코드 오프셋 집합을 읽는다.
+// 잘린 마지막 항목(torn write)은 무시한다.
+func readWALAcks(path string) (map[int64]struct{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	acked := make(map[int64]struct{}, len(b)/walAckSize)
+	for ; len(b) >= walAckSize; b = b[walAckSize:] {
+		acked[int64(binary.LittleEndian.Uint64(b))] = struct{}{}
+	}
+	return acked, nil
+}
+
+// readWALSegment 는 세그먼트 파일의 레코드를 읽어 Event 로 복원한다.
+// acked 에 오프셋이 있는 레코드(이미 저장된 이벤트)는 건너뛴다.
+// 손상된 레코드를 만나면 그때까지 읽은 이벤트와 함께 에러를 반환한다.
+func readWALSegment(path string, id uint64, acked map[int64]struct{}) ([]*model.Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 64*1024)
+
+	var evs []*model.Event
+	var hdr [walHeaderSize]byte
+	var off int64
+
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err == io.EOF {
+				return evs, nil
+			}
+			return evs, err
+		}
+
+		n := binary.LittleEndian.Uint32(hdr[0:4])
+		sum := binary.LittleEndian.Uint32(hdr[4:8])
+		if n == 0 || n > walMaxRecordLen {
+			return evs, fmt.Errorf("invalid record length %d", n)
+		}
+
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return evs, err
+		}
+		if crc32.Checksum(payload, walCRCTable) != sum {
+			return evs, errors.New("record checksum mismatch")
+		}
+
+		recOff := off
+		off += int64(walHeaderSize) + int64(n)
+		if _, ok := acked[recOff]; ok {
+			continue
+		}
+
+		ev := pool.EventPool.Get().(*model.Event)
+		pool.ResetEvent(ev)
+		if err := json.Unmarshal(payload, ev); err != nil {
+			pool.EventPool.Put(ev)
+			return evs, err
+		}
+		ev.WALSeg = id
+		ev.WALOff = recOff
+		evs = append(evs, ev)
+	}
+}
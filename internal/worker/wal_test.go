@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"estat-ingest/internal/config"
+	"estat-ingest/internal/metrics"
+	"estat-ingest/internal/model"
+)
+
+func testWALConfig(t *testing.T) config.Config {
+	t.Helper()
+	return config.Config{
+		WALDir:          t.TempDir(),
+		WALSegmentSize:  64 << 20,
+		WALSyncInterval: time.Millisecond,
+	}
+}
+
+func appendEvents(t *testing.T, w *WAL, n int) []*model.Event {
+	t.Helper()
+	evs := make([]*model.Event, n)
+	for i := range evs {
+		evs[i] = &model.Event{Ts: int64(i), Body: "e" + strconv.Itoa(i)}
+	}
+	if err := w.Append(evs...); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	return evs
+}
+
+// crash 후 재시작 시 Ack 된 이벤트는 replay 되지 않아야 한다.
+func TestWALReplaySkipsAckedEvents(t *testing.T) {
+	cfg := testWALConfig(t)
+
+	w, recovered, err := OpenWAL(cfg, metrics.New())
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	if len(recovered) != 0 {
+		t.Fatalf("recovered %d events from empty dir", len(recovered))
+	}
+
+	evs := appendEvents(t, w, 100)
+	w.Ack(evs[:99]...)
+
+	// Close 없이 다시 연다 (crash)
+	_, recovered, err = OpenWAL(cfg, metrics.New())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if len(recovered) != 1 {
+		t.Fatalf("recovered %d events, want 1", len(recovered))
+	}
+	if recovered[0].Body != "e99" {
+		t.Fatalf("recovered body = %q, want e99", recovered[0].Body)
+	}
+}
+
+// replay 된 이벤트를 Ack 하면 다음 재시작 때는 아무것도 복구되지 않아야 한다.
+func TestWALReplayedEventsCanBeAcked(t *testing.T) {
+	cfg := testWALConfig(t)
+
+	w, _, err := OpenWAL(cfg, metrics.New())
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	evs := appendEvents(t, w, 10)
+	w.Ack(evs[:4]...)
+
+	w2, recovered, err := OpenWAL(cfg, metrics.New())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if len(recovered) != 6 {
+		t.Fatalf("recovered %d events, want 6", len(recovered))
+	}
+	w2.Ack(recovered...)
+	w2.Close()
+
+	_, recovered, err = OpenWAL(cfg, metrics.New())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if len(recovered) != 0 {
+		t.Fatalf("recovered %d events after ack, want 0", len(recovered))
+	}
+}
+
+// 정상 종료 시 모든 이벤트가 Ack 되었다면 WAL 디렉토리는 비워져야 한다.
+func TestWALCloseRemovesAckedSegments(t *testing.T) {
+	cfg := testWALConfig(t)
+
+	w, _, err := OpenWAL(cfg, metrics.New())
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	w.Ack(appendEvents(t, w, 5)...)
+	w.Close()
+
+	entries, err := os.ReadDir(cfg.WALDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("WAL dir not empty: %v", entries)
+	}
+}
+
+// ack 파일 마지막 항목이 잘려도 나머지 ack 는 유효하다.
+func TestWALTornAckFile(t *testing.T) {
+	cfg := testWALConfig(t)
+
+	w, _, err := OpenWAL(cfg, metrics.New())
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	evs := appendEvents(t, w, 3)
+	seg := evs[0].WALSeg
+	w.Ack(evs[:2]...)
+
+	f, err := os.OpenFile(w.ackPath(seg), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{1, 2, 3})
+	_ = f.Close()
+
+	_, recovered, err := OpenWAL(cfg, metrics.New())
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if len(recovered) != 1 || recovered[0].Body != "e2" {
+		t.Fatalf("recovered = %v, want [e2]", recovered)
+	}
+}